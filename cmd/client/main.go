@@ -4,16 +4,24 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings" // 新增导入
 	"syscall"
 	"time"
 
 	"MicroService/internal/client"
 	"MicroService/internal/client/config"
+	"MicroService/pkg/discovery"
 	"MicroService/pkg/httpclient"
+	"MicroService/pkg/logging"
+	"MicroService/pkg/metrics"
+	"MicroService/pkg/model"
+	"MicroService/pkg/tlsutil"
+	"MicroService/pkg/tracing"
 	"MicroService/pkg/util"
 
 	"github.com/gin-gonic/gin"
@@ -24,7 +32,7 @@ func main() {
 	// 1. 定义命令行参数
 	portFlag := flag.Int("port", 0, "The port for the client service to listen on. Overrides config.")
 	// 新增一个支持逗号分隔地址列表的参数
-	registryAddrsFlag := flag.String("registry-addrs", "", "Comma-separated list of registry addresses (e.g., 'http://127.0.0.1:8180,http://127.0.0.1:8181'). Overrides config.")
+	registryAddrsFlag := flag.String("registry-addrs", "", "Comma-separated list of registry addresses (e.g., 'http://127.0.0.1:8180,http://127.0.0.1:8181'; append '/ns/<namespace>' to use a namespace). Overrides config.")
 	// 保留旧参数
 	registryAddrFlag := flag.String("registry-addr", "", "The address of the registry. Deprecated. Use --registry-addrs instead.")
 	serviceIPFlag := flag.String("ip", "", "The service's IP address. Overrides config.")
@@ -56,23 +64,39 @@ func main() {
 	}
 	logrus.Infof("Registry addresses: %v", registryAddrs)
 
-	// 5. 初始化日志
-	logrus.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
-	if cfg.Debug {
-		logrus.SetLevel(logrus.DebugLevel)
-	} else {
-		logrus.SetLevel(logrus.InfoLevel)
+	// 5. 初始化日志，未设置 LOG_LEVEL 时兼容旧的 CLIENT_DEBUG
+	logConfig := logging.LoadConfig()
+	if cfg.Debug && os.Getenv("LOG_LEVEL") == "" {
+		logConfig.Level = logrus.DebugLevel
 	}
+	logging.Setup(logConfig, cfg.ServiceName)
 
 	logrus.Infof("Client service starting with config: %+v", cfg)
 
+	// 初始化 TLS，配置证书后以 HTTPS 监听，调用注册中心和 time-service 时使用 CA 和客户端证书
+	tlsConfig := tlsutil.LoadConfig()
+	serverTLS, err := tlsutil.Setup(tlsConfig)
+	if err != nil {
+		logrus.Fatalf("Failed to set up TLS: %v", err)
+	}
+
+	// 初始化链路追踪，serviceId 在注册成功后设置
+	tracer, err := tracing.Setup(tracing.LoadConfig(), cfg.ServiceName)
+	if err != nil {
+		logrus.Fatalf("Failed to set up tracing: %v", err)
+	}
+
 	// 6. 初始化 HTTP 客户端
+	breakerConfig := httpclient.DefaultBreakerConfig()
+	breakerConfig.Enabled = cfg.BreakerEnabled
+	breakerConfig.FailureRateThreshold = cfg.BreakerFailureRate
+	breakerConfig.CoolDown = cfg.BreakerCoolDown
 	httpClientConfig := httpclient.Config{
 		Timeout:    cfg.HTTPClientTimeout,
 		MaxRetries: cfg.HTTPClientMaxRetries,
 		RetryDelay: cfg.HTTPClientRetryDelay,
+		Breaker:    breakerConfig,
+		TLS:        httpclient.DefaultConfig().TLS,
 	}
 	httpClient := httpclient.NewClient(httpClientConfig)
 
@@ -86,61 +110,105 @@ func main() {
 		clientIP = localIP
 	}
 
+	// 配置了身份文件时，重启后沿用同一 ServiceId，注册中心不会出现同一地址的重复实例
+	identity, err := util.LoadInstanceIdentity(cfg.InstanceIDFile)
+	if err != nil {
+		logrus.Fatalf("Failed to load instance identity: %v", err)
+	}
+
 	// 传入地址列表
-	serviceID, err := client.RegisterService(
+	serviceID, leaseToken, err := client.RegisterService(
+		context.Background(),
 		registryAddrs,
 		cfg.ServiceName,
+		identity,
 		clientIP,
 		cfg.Port,
+		tlsConfig.Scheme(),
+		cfg.Metadata,
+		cfg.RegistryToken,
 	)
 	if err != nil {
-		logrus.Fatalf("Failed to register client service to registry: %v", err)
+		// 注册中心全部不可达时不退出，依靠快照中的最后已知目录继续提供服务
+		logrus.Warnf("Failed to register client service to registry, continuing unregistered: %v", err)
+	} else {
+		logrus.Infof("Client service registered with ID: %s", serviceID)
 	}
-	logrus.Infof("Client service registered with ID: %s", serviceID)
+	tracer.SetServiceID(serviceID)
+	logging.SetServiceID(serviceID)
 
 	// 8. 启动心跳
 	// 传入地址列表
 	heartbeatStopChan := client.StartHeartbeat(
 		registryAddrs,
+		cfg.ServiceName,
 		serviceID,
 		clientIP,
 		cfg.Port,
+		tlsConfig.Scheme(),
+		cfg.Metadata,
 		cfg.HeartbeatInterval,
+		cfg.RegistryToken,
+		leaseToken,
 	)
 
-	// 9. 配置 Gin 路由
-	router := gin.Default()
+	// 9. 初始化服务发现客户端，后台轮询刷新 time-service 实例缓存
+	discoveryConfig := discovery.DefaultConfig()
+	discoveryConfig.RegistryAddrs = registryAddrs
+	discoveryConfig.RefreshInterval = cfg.DiscoveryRefresh
+	discoveryConfig.Balancer = cfg.DiscoveryBalancer
+	discoveryConfig.SnapshotPath = cfg.DiscoverySnapshot
+	discoveryConfig.MaxStaleness = cfg.DiscoveryMaxStale
+	discoveryConfig.Hedge.Enabled = cfg.HedgeEnabled
+	discoveryConfig.Hedge.Delay = cfg.HedgeDelay
+	discoveryConfig.Hedge.Percentile = cfg.HedgePercentile
+	// 跳过熔断器打开的实例
+	discoveryConfig.InstanceFilter = func(s model.Service) bool {
+		return httpClient.Available(net.JoinHostPort(s.IpAddress, strconv.Itoa(s.Port)))
+	}
+	discoveryClient := discovery.NewClient(discoveryConfig, httpClient)
+	discoveryClient.Watch(client.TimeServiceName)
+	discoveryClient.Start()
+
+	// 10. 配置 Gin 路由
+	router := logging.NewRouter()
+	router.Use(tracing.GinMiddleware())
+	router.Use(metrics.GinMiddleware())
 	router.Use(func(c *gin.Context) {
 		c.Set("serviceId", serviceID)
-		// 将地址列表传递给上下文，InfoHandler需要用它来做服务发现
-		c.Set("registryAddrs", registryAddrs)
+		// 将服务发现客户端传递给上下文，InfoHandler需要用它来选择实例
+		c.Set("discovery", discoveryClient)
 		c.Set("httpClient", httpClient)
 		c.Next()
 	})
 
 	router.GET("/api/getInfo", client.InfoHandler)
+	router.GET("/metrics", metrics.Handler())
+	client.RegisterMetrics(httpClient, discoveryClient)
 
-	// 10. 启动 HTTP 服务器
+	// 11. 启动 HTTP 服务器
 	addr := fmt.Sprintf(":%d", cfg.Port)
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: router,
+		Addr:      addr,
+		Handler:   router,
+		TLSConfig: serverTLS,
 	}
 
 	go func() {
-		logrus.Infof("Client service listening on %s", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logrus.Infof("Client service listening on %s (%s)", addr, tlsConfig.Scheme())
+		if err := tlsutil.ListenAndServe(srv); err != nil && err != http.ErrServerClosed {
 			logrus.Fatalf("Client service listen error: %s", err)
 		}
 	}()
 
-	// 11. 优雅关闭
+	// 12. 优雅关闭
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logrus.Info("Shutting down client service...")
 	close(heartbeatStopChan)
+	discoveryClient.Stop()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -151,14 +219,19 @@ func main() {
 		logrus.Info("Client service stopped gracefully.")
 	}
 
-	// 12. 服务注销
+	// 13. 服务注销
 	// 传入地址列表
+	unregisterCtx, unregisterCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer unregisterCancel()
 	err = client.UnregisterService(
+		unregisterCtx,
 		registryAddrs,
 		cfg.ServiceName,
 		serviceID,
 		clientIP,
 		cfg.Port,
+		cfg.RegistryToken,
+		leaseToken,
 	)
 	if err != nil {
 		logrus.Errorf("Failed to unregister client service: %v", err)
@@ -166,5 +239,9 @@ func main() {
 		logrus.Infof("Client service %s unregistered successfully.", serviceID)
 	}
 
+	if err := tracer.Shutdown(ctx); err != nil {
+		logrus.Errorf("Failed to flush traces: %v", err)
+	}
+
 	logrus.Info("Client service exited.")
 }
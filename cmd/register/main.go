@@ -16,6 +16,10 @@ import (
 
 	"MicroService/internal/register"
 	config2 "MicroService/internal/register/config" // 你的配置包
+	"MicroService/pkg/logging"
+	"MicroService/pkg/metrics"
+	"MicroService/pkg/tlsutil"
+	"MicroService/pkg/tracing"
 )
 
 type RegistryConfig struct {
@@ -30,12 +34,14 @@ func main() {
 	flag.Parse()
 
 	// 2. 加载配置
+	logging.Setup(logging.LoadConfig(), "registry")
 	config := config2.LoadConfig()
 
 	// 3. 使用命令行参数覆盖配置
 	if *portFlag != 0 {
 		config.Port = *portFlag
 	}
+	config.ExpandPort()
 
 	// 4. 解析对等节点地址
 	var peers []string
@@ -43,36 +49,128 @@ func main() {
 		peers = strings.Split(*peersFlag, ",")
 	}
 
-	// 5. 初始化注册中心
+	// 初始化 TLS，配置证书后以 HTTPS 监听；向对等节点同步时使用 CA 和客户端证书
+	tlsConfig := tlsutil.LoadConfig()
+	serverTLS, err := tlsutil.Setup(tlsConfig)
+	if err != nil {
+		logrus.Fatalf("Failed to set up TLS: %v", err)
+	}
+
+	// 5. 初始化注册中心，注册中心没有 serviceId，使用 主机名:端口 标识节点
+	hostname, _ := os.Hostname()
+	instanceID := fmt.Sprintf("%s:%d", hostname, config.Port)
 	regConfig := register.Config{
 		HeartbeatTTL:  config.HeartbeatTTL,
 		CleanupPeriod: config.CleanupPeriod,
+		Auth: register.AuthConfig{
+			PeerToken:  config.PeerToken,
+			AdminToken: config.AdminToken,
+			TokensFile: config.TokensFile,
+		},
+		ACLFile: config.ACLFile,
+		Audit: register.AuditConfig{
+			File:       config.AuditFile,
+			MaxSize:    config.AuditMaxSize,
+			MaxBackups: config.AuditMaxBackups,
+		},
+		NodeID: instanceID,
+		Namespaces: register.NamespaceConfig{
+			DefaultQuota: config.DefaultNamespaceQuota,
+			Quotas:       config.NamespaceQuotas,
+		},
+		AdmissionFile:    config.AdmissionFile,
+		DuplicateAddress: config.DuplicateAddress,
+		Webhooks: register.WebhookConfig{
+			File:        config.WebhookFile,
+			MaxAttempts: config.WebhookMaxAttempts,
+			Timeout:     config.WebhookTimeout,
+		},
+		ThresholdsFile: config.ThresholdsFile,
+		AlertInterval:  config.AlertInterval,
+		History: register.HistoryConfig{
+			MaxEvents: config.HistoryMaxEvents,
+			Retention: config.HistoryRetention,
+		},
+		MaintenanceFile: config.MaintenanceFile,
+	}
+	for _, t := range config.APITokens {
+		regConfig.Auth.Tokens = append(regConfig.Auth.Tokens, register.APIToken{Name: t.Name, Secret: t.Secret, Services: t.Services})
 	}
 	// 将解析出的对等节点地址传递给NewRegister
 	reg := register.NewRegister(regConfig, peers)
 
-	// 6. 初始化 Gin 路由
-	r := gin.Default()
+	// 初始化链路追踪
+	tracer, err := tracing.Setup(tracing.LoadConfig(), "registry")
+	if err != nil {
+		logrus.Fatalf("Failed to set up tracing: %v", err)
+	}
+	tracer.SetServiceID(instanceID)
+	logging.SetServiceID(instanceID)
 
-	// 7. 注册 API 端点
-	r.POST("/api/register", reg.RegisterHandler)
-	r.POST("/api/unregister", reg.UnregisterHandler)
-	r.POST("/api/heartbeat", reg.HeartbeatHandler)
-	r.GET("/api/discovery", reg.DiscoveryHandler)
+	// 6. 初始化 Gin 路由
+	r := logging.NewRouter()
+	r.Use(tracing.GinMiddleware())
+	r.Use(metrics.GinMiddleware())
+
+	// 7. 注册 API 端点，写接口需要服务令牌
+	// 命名空间通过 X-Namespace 请求头或 /ns/:namespace 路径前缀指定，未指定时使用 default
+	serviceAuth := reg.RequireAuth(register.IdentityService)
+	for _, api := range []*gin.RouterGroup{r.Group("", reg.Namespace()), r.Group("/ns/:namespace", reg.Namespace())} {
+		api.POST("/api/register", serviceAuth, reg.RegisterHandler)
+		api.POST("/api/unregister", serviceAuth, reg.UnregisterHandler)
+		api.POST("/api/heartbeat", serviceAuth, reg.HeartbeatHandler)
+		api.POST("/api/status", serviceAuth, reg.StatusHandler)
+		api.GET("/api/discovery", reg.OptionalAuth(), reg.DiscoveryHandler)
+		api.GET("/api/timeline", reg.OptionalAuth(), reg.TimelineHandler)
+	}
 
-	// 新增：注册内部同步端点
-	r.POST("/api/internal/sync", reg.SyncHandler)
+	// 新增：注册内部同步端点，只接受对等节点令牌
+	r.POST("/api/internal/sync", reg.RequireAuth(register.IdentityPeer), reg.SyncHandler)
+	r.GET("/api/internal/status", reg.RequireAuth(register.IdentityPeer), reg.NodeStatusHandler)
+
+	// 管理接口：签发和吊销服务令牌，管理 ACL 策略，查询审计日志
+	admin := r.Group("/api/admin", reg.RequireAdmin())
+	admin.POST("/tokens", reg.IssueTokenHandler)
+	admin.GET("/tokens", reg.ListTokensHandler)
+	admin.DELETE("/tokens/:name", reg.RevokeTokenHandler)
+	admin.GET("/acl", reg.GetACLHandler)
+	admin.PUT("/acl", reg.PutACLHandler)
+	admin.DELETE("/acl", reg.DeleteACLHandler)
+	admin.GET("/audit", reg.AuditHandler)
+	admin.GET("/namespaces", reg.NamespacesHandler)
+	admin.GET("/admission", reg.AdmissionHandler)
+	admin.POST("/webhooks", reg.CreateWebhookHandler)
+	admin.GET("/webhooks", reg.ListWebhooksHandler)
+	admin.GET("/webhooks/deliveries", reg.WebhookDeliveriesHandler)
+	admin.DELETE("/webhooks/:id", reg.DeleteWebhookHandler)
+	admin.POST("/webhooks/:id/test", reg.TestWebhookHandler)
+	admin.GET("/alerts", reg.AlertsHandler)
+	admin.GET("/slo", reg.SLOHandler)
+	admin.GET("/thresholds", reg.ThresholdsHandler)
+	admin.DELETE("/instances/:id", reg.EvictInstanceHandler)
+	admin.DELETE("/services/:name/instances", reg.EvictServiceHandler)
+	admin.PUT("/services/:name/maintenance", reg.StartMaintenanceHandler)
+	admin.DELETE("/services/:name/maintenance", reg.StopMaintenanceHandler)
+	admin.GET("/maintenance", reg.MaintenanceHandler)
+	admin.POST("/cleanup", reg.CleanupHandler)
+	admin.GET("/export", reg.ExportHandler)
+	admin.POST("/import", reg.ImportHandler)
+	admin.GET("/cluster", reg.ClusterHandler)
+
+	// Prometheus 指标
+	r.GET("/metrics", metrics.Handler())
 
 	// 8. 启动服务
 	addr := fmt.Sprintf(":%d", config.Port)
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: r,
+		Addr:      addr,
+		Handler:   r,
+		TLSConfig: serverTLS,
 	}
 
 	go func() {
-		logrus.Infof("Starting registry service on %s", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logrus.Infof("Starting registry service on %s (%s)", addr, tlsConfig.Scheme())
+		if err := tlsutil.ListenAndServe(srv); err != nil && err != http.ErrServerClosed {
 			logrus.Fatalf("Failed to start registry service: %v", err)
 		}
 	}()
@@ -91,5 +189,13 @@ func main() {
 		logrus.Errorf("Registry service forced to shutdown: %v", err)
 	}
 
+	if err := reg.Close(); err != nil {
+		logrus.Errorf("Failed to close audit log: %v", err)
+	}
+
+	if err := tracer.Shutdown(ctx); err != nil {
+		logrus.Errorf("Failed to flush traces: %v", err)
+	}
+
 	logrus.Info("Registry service stopped gracefully.")
 }
@@ -13,6 +13,10 @@ import (
 
 	"MicroService/internal/time-service"
 	"MicroService/internal/time-service/config"
+	"MicroService/pkg/logging"
+	"MicroService/pkg/metrics"
+	"MicroService/pkg/tlsutil"
+	"MicroService/pkg/tracing"
 	"MicroService/pkg/util"
 
 	"github.com/gin-gonic/gin"
@@ -23,13 +27,15 @@ func main() {
 	// 1. 定义命令行参数
 	portFlag := flag.Int("port", 0, "The port for the time-service to listen on. Overrides config.")
 	// 新增一个支持逗号分隔地址列表的参数
-	registryAddrsFlag := flag.String("registry-addrs", "", "Comma-separated list of registry addresses (e.g., 'http://127.0.0.1:8180,http://127.0.0.1:8181'). Overrides config.")
+	registryAddrsFlag := flag.String("registry-addrs", "", "Comma-separated list of registry addresses (e.g., 'http://127.0.0.1:8180,http://127.0.0.1:8181'; append '/ns/<namespace>' to use a namespace). Overrides config.")
 	// 保留旧参数，但明确说明它将被覆盖
 	registryAddrFlag := flag.String("registry-addr", "", "The address of the registry. Deprecated. Use --registry-addrs instead.")
 	serviceIPFlag := flag.String("ip", "", "The service's IP address. Overrides config.")
 	flag.Parse()
 
 	// 2. 加载配置
+	serviceName := "time-service"
+	logging.Setup(logging.LoadConfig(), serviceName)
 	cfg := config.LoadTimeServiceConfig()
 
 	// 3. 使用命令行参数覆盖配置
@@ -71,19 +77,39 @@ func main() {
 		logrus.Infof("Auto-detected IP address: %s", currentIPAddress)
 	}
 
-	// 6. 服务注册，传入地址列表
-	serviceName := "time-service"
-	serviceId, err := timeservice.RegisterService(registryAddrs, serviceName, currentIPAddress, cfg.Port)
+	// 6. 初始化 TLS，配置证书后以 HTTPS 监听并以 https 注册
+	tlsConfig := tlsutil.LoadConfig()
+	serverTLS, err := tlsutil.Setup(tlsConfig)
+	if err != nil {
+		logrus.Fatalf("Failed to set up TLS: %v", err)
+	}
+
+	// 初始化链路追踪，serviceId 在注册成功后设置
+	tracer, err := tracing.Setup(tracing.LoadConfig(), serviceName)
+	if err != nil {
+		logrus.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	// 服务注册，传入地址列表
+	identity, err := util.LoadInstanceIdentity(cfg.InstanceIDFile)
+	if err != nil {
+		logrus.Fatalf("Failed to load instance identity: %v", err)
+	}
+	serviceId, leaseToken, err := timeservice.RegisterService(context.Background(), registryAddrs, serviceName, identity, currentIPAddress, cfg.Port, tlsConfig.Scheme(), cfg.Metadata, cfg.RegistryToken)
 	if err != nil {
 		logrus.Fatalf("Failed to register service: %v", err)
 	}
 	logrus.Infof("Service '%s' (ID: %s) registered successfully.", serviceName, serviceId)
+	tracer.SetServiceID(serviceId)
+	logging.SetServiceID(serviceId)
 
 	// 7. 启动心跳，传入地址列表
-	stopHeartbeatChan := timeservice.StartHeartbeat(registryAddrs, serviceId, currentIPAddress, cfg.Port, cfg.HeartbeatInterval)
+	stopHeartbeatChan := timeservice.StartHeartbeat(registryAddrs, serviceName, serviceId, currentIPAddress, cfg.Port, tlsConfig.Scheme(), cfg.Metadata, cfg.HeartbeatInterval, cfg.RegistryToken, leaseToken)
 
 	// 8. 初始化 Gin 路由
-	router := gin.Default()
+	router := logging.NewRouter()
+	router.Use(tracing.GinMiddleware())
+	router.Use(metrics.GinMiddleware())
 
 	router.Use(func(c *gin.Context) {
 		c.Set("serviceId", serviceId)
@@ -91,15 +117,17 @@ func main() {
 	})
 
 	router.GET("/api/getDateTime", timeservice.DateTimeHandler)
+	router.GET("/metrics", metrics.Handler())
 
 	// 9. 启动 HTTP 服务器
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: router,
+		Addr:      fmt.Sprintf(":%d", cfg.Port),
+		Handler:   router,
+		TLSConfig: serverTLS,
 	}
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := tlsutil.ListenAndServe(srv); err != nil && err != http.ErrServerClosed {
 			logrus.Fatalf("Gin server error: %v", err)
 		}
 	}()
@@ -119,10 +147,16 @@ func main() {
 	}
 
 	// 11. 服务注销，传入地址列表
-	err = timeservice.UnregisterService(registryAddrs, serviceName, serviceId, currentIPAddress, cfg.Port)
+	unregisterCtx, unregisterCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer unregisterCancel()
+	err = timeservice.UnregisterService(unregisterCtx, registryAddrs, serviceName, serviceId, currentIPAddress, cfg.Port, cfg.RegistryToken, leaseToken)
 	if err != nil {
 		logrus.Errorf("Failed to unregister service during shutdown: %v", err)
 	}
 
+	if err := tracer.Shutdown(ctx); err != nil {
+		logrus.Errorf("Failed to flush traces: %v", err)
+	}
+
 	logrus.Info("Time-Service stopped gracefully.")
 }
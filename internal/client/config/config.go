@@ -1,12 +1,15 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/util"
 )
 
 // Config 包含了客户端服务的所有配置
@@ -16,12 +19,34 @@ type Config struct {
 	IPAddress            string // 可以为空，表示自动检测
 	HeartbeatInterval    time.Duration
 	RegistryAddress      string
+	RegistryToken        string            // 访问注册中心写接口的服务令牌
+	Metadata             map[string]string // 注册时上报的实例元数据
+	InstanceIDFile       string            // 持久化实例身份的文件，为空时每次启动使用新的 ServiceId
 	HTTPClientTimeout    time.Duration
 	HTTPClientMaxRetries int
 	HTTPClientRetryDelay time.Duration
+	BreakerEnabled       bool          // 是否启用按主机熔断
+	BreakerFailureRate   float64       // 熔断失败率阈值
+	BreakerCoolDown      time.Duration // 熔断打开后的冷却时间
+	DiscoveryRefresh     time.Duration // 服务发现缓存刷新间隔
+	DiscoveryBalancer    string        // 客户端负载均衡策略
+	DiscoverySnapshot    string        // 最后已知目录的快照文件路径，为空时不持久化
+	DiscoveryMaxStale    time.Duration // 陈旧实例允许使用的最长时间，0 表示不限制
+	HedgeEnabled         bool          // 调用 time-service 时是否启用对冲请求
+	HedgeDelay           time.Duration // 对冲等待时间（延迟样本不足时使用）
+	HedgePercentile      float64       // 自适应对冲等待时间的延迟百分位，0 表示只使用固定等待时间
 	Debug                bool
 }
 
+// String 返回用于日志输出的配置，隐藏令牌
+func (c Config) String() string {
+	if c.RegistryToken != "" {
+		c.RegistryToken = "******"
+	}
+	type plain Config // 去掉 String 方法，避免递归
+	return fmt.Sprintf("%+v", plain(c))
+}
+
 // LoadConfig 从环境变量加载客户端配置
 func LoadConfig() Config {
 	config := Config{
@@ -33,6 +58,16 @@ func LoadConfig() Config {
 		HTTPClientTimeout:    10 * time.Second,        // 默认 HTTP 客户端超时
 		HTTPClientMaxRetries: 3,                       // 默认 HTTP 客户端重试次数
 		HTTPClientRetryDelay: 1 * time.Second,         // 默认 HTTP 客户端重试间隔
+		BreakerEnabled:       true,                    // 默认启用熔断
+		BreakerFailureRate:   0.5,                     // 默认失败率 50% 触发熔断
+		BreakerCoolDown:      10 * time.Second,        // 默认冷却 10 秒
+		DiscoveryRefresh:     10 * time.Second,        // 默认服务发现缓存刷新间隔
+		DiscoveryBalancer:    "round-robin",           // 默认轮询负载均衡
+		DiscoverySnapshot:    "discovery-cache.json",  // 默认快照文件
+		DiscoveryMaxStale:    24 * time.Hour,          // 默认陈旧实例最多使用 24 小时
+		HedgeEnabled:         false,                   // 默认关闭对冲请求
+		HedgeDelay:           100 * time.Millisecond,  // 默认对冲等待 100 毫秒
+		HedgePercentile:      0.95,                    // 默认按 p95 延迟对冲
 		Debug:                false,                   // 默认关闭调试模式
 	}
 
@@ -96,6 +131,107 @@ func LoadConfig() Config {
 		}
 	}
 
+	// 加载 HTTP_CLIENT_BREAKER_ENABLED
+	if enabledStr := os.Getenv("HTTP_CLIENT_BREAKER_ENABLED"); enabledStr != "" {
+		config.BreakerEnabled = strings.ToLower(enabledStr) == "true"
+	}
+
+	// 加载 HTTP_CLIENT_BREAKER_FAILURE_RATE
+	if rateStr := os.Getenv("HTTP_CLIENT_BREAKER_FAILURE_RATE"); rateStr != "" {
+		if rate, err := strconv.ParseFloat(rateStr, 64); err == nil && rate > 0 && rate <= 1 {
+			config.BreakerFailureRate = rate
+		} else {
+			logrus.Warnf("Invalid HTTP_CLIENT_BREAKER_FAILURE_RATE: %s, using default: %v", rateStr, config.BreakerFailureRate)
+		}
+	}
+
+	// 加载 HTTP_CLIENT_BREAKER_COOLDOWN_SECONDS
+	if coolDownStr := os.Getenv("HTTP_CLIENT_BREAKER_COOLDOWN_SECONDS"); coolDownStr != "" {
+		if coolDown, err := strconv.Atoi(coolDownStr); err == nil && coolDown > 0 {
+			config.BreakerCoolDown = time.Duration(coolDown) * time.Second
+		} else {
+			logrus.Warnf("Invalid HTTP_CLIENT_BREAKER_COOLDOWN_SECONDS: %s, using default: %v", coolDownStr, config.BreakerCoolDown)
+		}
+	}
+
+	// 加载 DISCOVERY_REFRESH_SECONDS
+	if refreshStr := os.Getenv("DISCOVERY_REFRESH_SECONDS"); refreshStr != "" {
+		if refresh, err := strconv.Atoi(refreshStr); err == nil && refresh > 0 {
+			config.DiscoveryRefresh = time.Duration(refresh) * time.Second
+		} else {
+			logrus.Warnf("Invalid DISCOVERY_REFRESH_SECONDS: %s, using default: %v", refreshStr, config.DiscoveryRefresh)
+		}
+	}
+
+	// 加载 DISCOVERY_BALANCER
+	if balancer := os.Getenv("DISCOVERY_BALANCER"); balancer != "" {
+		if balancer == "round-robin" || balancer == "random" {
+			config.DiscoveryBalancer = balancer
+		} else {
+			logrus.Warnf("Invalid DISCOVERY_BALANCER: %s, using default: %s", balancer, config.DiscoveryBalancer)
+		}
+	}
+
+	// 加载 DISCOVERY_SNAPSHOT_PATH，设置为 "none" 时关闭持久化
+	if snapshot, ok := os.LookupEnv("DISCOVERY_SNAPSHOT_PATH"); ok {
+		if snapshot == "none" {
+			config.DiscoverySnapshot = ""
+		} else if snapshot != "" {
+			config.DiscoverySnapshot = snapshot
+		}
+	}
+
+	// 加载 DISCOVERY_MAX_STALENESS_SECONDS
+	if staleStr := os.Getenv("DISCOVERY_MAX_STALENESS_SECONDS"); staleStr != "" {
+		if stale, err := strconv.Atoi(staleStr); err == nil && stale >= 0 { // 0 表示不限制
+			config.DiscoveryMaxStale = time.Duration(stale) * time.Second
+		} else {
+			logrus.Warnf("Invalid DISCOVERY_MAX_STALENESS_SECONDS: %s, using default: %v", staleStr, config.DiscoveryMaxStale)
+		}
+	}
+
+	// 加载 DISCOVERY_HEDGE_ENABLED
+	if hedgeStr := os.Getenv("DISCOVERY_HEDGE_ENABLED"); hedgeStr != "" {
+		config.HedgeEnabled = strings.ToLower(hedgeStr) == "true"
+	}
+
+	// 加载 DISCOVERY_HEDGE_DELAY_MS
+	if delayStr := os.Getenv("DISCOVERY_HEDGE_DELAY_MS"); delayStr != "" {
+		if delay, err := strconv.Atoi(delayStr); err == nil && delay > 0 {
+			config.HedgeDelay = time.Duration(delay) * time.Millisecond
+		} else {
+			logrus.Warnf("Invalid DISCOVERY_HEDGE_DELAY_MS: %s, using default: %v", delayStr, config.HedgeDelay)
+		}
+	}
+
+	// 加载 DISCOVERY_HEDGE_PERCENTILE
+	if pStr := os.Getenv("DISCOVERY_HEDGE_PERCENTILE"); pStr != "" {
+		if p, err := strconv.ParseFloat(pStr, 64); err == nil && p >= 0 && p < 1 {
+			config.HedgePercentile = p
+		} else {
+			logrus.Warnf("Invalid DISCOVERY_HEDGE_PERCENTILE: %s, using default: %v", pStr, config.HedgePercentile)
+		}
+	}
+
+	// 加载 REGISTRY_TOKEN
+	if token := os.Getenv("REGISTRY_TOKEN"); token != "" {
+		config.RegistryToken = token
+	}
+
+	// 加载 SERVICE_METADATA，格式：version=1.2.0,zone=a
+	if metadataStr := os.Getenv("SERVICE_METADATA"); metadataStr != "" {
+		if metadata, err := util.ParseKeyValues(metadataStr); err == nil {
+			config.Metadata = metadata
+		} else {
+			logrus.Warnf("Invalid SERVICE_METADATA: %s, ignoring: %v", metadataStr, err)
+		}
+	}
+
+	// 加载 SERVICE_INSTANCE_ID_FILE
+	if idFile := os.Getenv("SERVICE_INSTANCE_ID_FILE"); idFile != "" {
+		config.InstanceIDFile = idFile
+	}
+
 	// 加载 DEBUG
 	if debugStr := os.Getenv("CLIENT_DEBUG"); debugStr != "" {
 		config.Debug = strings.ToLower(debugStr) == "true"
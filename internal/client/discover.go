@@ -1,29 +1,34 @@
 package client
 
-//调用/api/discovery？name 获取时间服务实例
+//调用/api/discovery？name 获取服务实例
 import (
 	"MicroService/pkg/httpclient"
 	"MicroService/pkg/model"
+	"context"
 	"fmt"
 )
 
-// DiscoverTimeService 从注册中心发现一个 time-service 实例
+// TimeServiceName 是时间服务在注册中心中的服务名
+const TimeServiceName = "time-service"
+
+// DiscoverService 从注册中心发现一个指定服务名的实例
+// ctx: 控制请求的取消和超时
 // registryAddr: 注册中心的地址，例如 "http://localhost:8180"
+// serviceName: 要发现的服务名
 // httpClient: 用于发送 HTTP 请求的客户端实例
-func DiscoverTimeService(registryAddr string, httpClient *httpclient.Client) (model.Service, error) {
-	discoveryURL := fmt.Sprintf("%s/api/discovery?name=time-service", registryAddr)
+func DiscoverService(ctx context.Context, registryAddr, serviceName string, httpClient *httpclient.Client) (model.Service, error) {
+	discoveryURL := fmt.Sprintf("%s/api/discovery", registryAddr)
 
 	var discoveryResp model.DiscoveryResponse // 预期返回单个服务实例 (负载均衡)
 
-	clientConfig := httpclient.DefaultConfig() // 使用默认 HTTP 客户端配置进行服务发现
-	err := httpClient.Get(discoveryURL, &discoveryResp, clientConfig)
+	err := httpClient.GetJSON(ctx, discoveryURL, &discoveryResp, httpclient.WithQuery("name", serviceName))
 	if err != nil {
-		return model.Service{}, fmt.Errorf("failed to discover 'time-service': %v", err)
+		return model.Service{}, fmt.Errorf("failed to discover '%s': %v", serviceName, err)
 	}
 
 	// 验证发现到的服务信息
 	if discoveryResp.ServiceId == "" || discoveryResp.IpAddress == "" || discoveryResp.Port <= 0 {
-		return model.Service{}, fmt.Errorf("discovered 'time-service' instance has invalid information: %+v", discoveryResp)
+		return model.Service{}, fmt.Errorf("discovered '%s' instance has invalid information: %+v", serviceName, discoveryResp)
 	}
 
 	return model.Service{
@@ -33,3 +38,8 @@ func DiscoverTimeService(registryAddr string, httpClient *httpclient.Client) (mo
 		Port:        discoveryResp.Port,
 	}, nil
 }
+
+// DiscoverTimeService 从注册中心发现一个 time-service 实例
+func DiscoverTimeService(ctx context.Context, registryAddr string, httpClient *httpclient.Client) (model.Service, error) {
+	return DiscoverService(ctx, registryAddr, TimeServiceName, httpClient)
+}
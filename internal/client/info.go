@@ -2,8 +2,10 @@ package client
 
 //实现api/getInfo 调用api/getDateTime？style
 import (
+	"MicroService/pkg/discovery"
 	"MicroService/pkg/httpclient"
 	"MicroService/pkg/model"
+	"context"
 	"time"
 
 	//"MicroService/internal/client"
@@ -15,17 +17,19 @@ import (
 
 // InfoHandler 处理获取客户端信息请求
 // 此函数将负责：
-// 1. 从注册中心发现一个可用的 time-service 实例。
+// 1. 通过服务发现客户端选择一个可用的 time-service 实例。
 // 2. 调用 time-service 的 /api/getDateTime?style=full 接口。
 // 3. 拼接返回结果并响应。
 // 4. 处理 time-service 不可用的情况。
 // InfoHandler handles the client's /api/getInfo request
 func InfoHandler(c *gin.Context) {
+	logger := logrus.WithContext(c.Request.Context())
+
 	// 从 Gin 上下文获取客户端 ID
 	clientID, exists := c.Get("serviceId")
 	if !exists {
 		errMsg := "Client Service ID not found in context."
-		logrus.Error(errMsg)
+		logger.Error(errMsg)
 		c.JSON(http.StatusInternalServerError, model.GetInfoResponse{
 			Error:  &errMsg,
 			Result: nil,
@@ -34,14 +38,14 @@ func InfoHandler(c *gin.Context) {
 	}
 	currentClientID := clientID.(string)
 
-	// 从 Gin 上下文获取注册中心地址列表和 HTTP 客户端
-	registryAddrs, registryAddrsExists := c.Get("registryAddrs")
+	// 从 Gin 上下文获取服务发现客户端和 HTTP 客户端
+	discoveryClient, discoveryExists := c.Get("discovery")
 	httpClient, httpClientExists := c.Get("httpClient")
 
-	// 检查是否获取到注册中心地址列表和 HTTP 客户端
-	if !registryAddrsExists || !httpClientExists {
-		errMsg := "Missing registry address or HTTP client in Gin context."
-		logrus.Error(errMsg)
+	// 检查是否获取到服务发现客户端和 HTTP 客户端
+	if !discoveryExists || !httpClientExists {
+		errMsg := "Missing discovery client or HTTP client in Gin context."
+		logger.Error(errMsg)
 		c.JSON(http.StatusInternalServerError, model.GetInfoResponse{
 			Error:  &errMsg,
 			Result: nil,
@@ -50,75 +54,46 @@ func InfoHandler(c *gin.Context) {
 	}
 
 	// 类型断言，确保获取到的是我们期望的类型
-	registryAddrsSlice := registryAddrs.([]string)
+	disc := discoveryClient.(*discovery.Client)
 	client := httpClient.(*httpclient.Client)
 
-	// 遍历注册中心，尝试发现 time-service
-	var timeServiceInstance *model.Service
-	var discoveryErr error
-
-	// 循环，直到找到一个可用的服务实例，或遍历完所有注册中心
-	// 这种方法提供了简单的故障转移
-	for _, addr := range registryAddrsSlice {
-		discoveryURL := fmt.Sprintf("%s/api/discovery?name=time-service", addr)
-		var discoveryResp model.DiscoveryResponse
-
-		err := client.Get(discoveryURL, &discoveryResp, httpclient.DefaultConfig())
-		if err != nil {
-			logrus.Warnf("Failed to discover 'time-service' from registry %s: %v", addr, err)
-			discoveryErr = err
-			continue // 尝试下一个注册中心
+	// 通过本地缓存选择 time-service 实例并调用 /api/getDateTime?style=full
+	// 注册中心全部不可用时使用最后已知实例；启用对冲时慢请求会被发往另一个实例
+	ctx := c.Request.Context()
+	value, timeServiceInstance, err := disc.Call(ctx, TimeServiceName, func(ctx context.Context, instance discovery.Selection) (interface{}, error) {
+		timeServiceURL := instance.URL("/api/getDateTime")
+		var timeServiceResp model.GetDateTimeResponse
+		if err := client.GetJSON(ctx, timeServiceURL, &timeServiceResp, httpclient.WithQuery("style", "full")); err != nil {
+			return nil, err
 		}
-
-		if discoveryResp.ServiceId != "" {
-			// 将发现的实例信息直接赋值给 timeServiceInstance
-			timeServiceInstance = &model.Service{
-				ServiceName: discoveryResp.ServiceName,
-				ServiceId:   discoveryResp.ServiceId,
-				IpAddress:   discoveryResp.IpAddress,
-				Port:        discoveryResp.Port,
-			}
-			break
-		}
-	}
-
-	// 如果遍历完所有注册中心都找不到服务，则返回错误
-	if timeServiceInstance == nil {
-		var errMsg string
-		if discoveryErr != nil {
-			errMsg = fmt.Sprintf("Time service unavailable. All registries failed to discover: %v", discoveryErr)
-		} else {
-			errMsg = "Time service unavailable. Could not find a healthy instance from any registry."
-		}
-		logrus.Error(errMsg)
+		return timeServiceResp, nil
+	})
+	if err != nil && timeServiceInstance.ServiceId == "" {
+		// 未能选出实例：服务发现失败
+		errMsg := fmt.Sprintf("Time service unavailable: %v", err)
+		logger.Error(errMsg)
 		c.JSON(http.StatusInternalServerError, model.GetInfoResponse{
 			Error:  &errMsg,
 			Result: nil,
 		})
 		return
 	}
-
-	// 构造调用时间服务的 URL
-	timeServiceURL := fmt.Sprintf("http://%s:%d/api/getDateTime?style=full", timeServiceInstance.IpAddress, timeServiceInstance.Port)
-
-	// 调用时间服务获取 GMT 时间
-	var timeServiceResp model.GetDateTimeResponse
-	err := client.Get(timeServiceURL, &timeServiceResp, httpclient.DefaultConfig())
 	if err != nil {
 		errMsg := "Failed to call time-service."
-		logrus.Errorf("%s: %v", errMsg, err)
+		logger.Errorf("%s: %v", errMsg, err)
 		c.JSON(http.StatusInternalServerError, model.GetInfoResponse{
 			Error:  &errMsg,
 			Result: nil,
 		})
 		return
 	}
+	timeServiceResp := value.(model.GetDateTimeResponse)
 
 	// 将 GMT 时间转换为北京时间
 	gmtTime, err := time.Parse("2006-01-02 15:04:05", timeServiceResp.Result)
 	if err != nil {
 		errMsg := "Failed to parse GMT time from time-service."
-		logrus.Errorf("%s: %v", errMsg, err)
+		logger.Errorf("%s: %v", errMsg, err)
 		c.JSON(http.StatusInternalServerError, model.GetInfoResponse{
 			Error:  &errMsg,
 			Result: nil,
@@ -128,10 +103,17 @@ func InfoHandler(c *gin.Context) {
 	beijingLocation, _ := time.LoadLocation("Asia/Shanghai")
 	beijingTime := gmtTime.In(beijingLocation)
 
+	// 实例来自陈旧目录时在响应中明确标注
+	if timeServiceInstance.Stale {
+		logger.Warnf("Served time-service instance %s from last known catalog (age %v)", timeServiceInstance.ServiceId, timeServiceInstance.Age.Round(time.Second))
+		c.Header("Warning", `110 - "Response is Stale"`)
+	}
+
 	// 构建最终响应
 	result := fmt.Sprintf("Hello Kingsoft Cloud Star Camp - %s - %s", currentClientID, beijingTime.Format("2006-01-02 15:04:05"))
 	c.JSON(http.StatusOK, model.GetInfoResponse{
 		Error:  nil,
 		Result: &result,
+		Stale:  timeServiceInstance.Stale,
 	})
 }
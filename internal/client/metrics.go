@@ -0,0 +1,35 @@
+package client
+
+import (
+	"MicroService/pkg/discovery"
+	"MicroService/pkg/httpclient"
+	"MicroService/pkg/metrics"
+)
+
+// RegisterMetrics 注册客户端服务的熔断器状态和对冲请求指标
+func RegisterMetrics(httpClient *httpclient.Client, disc *discovery.Client) {
+	metrics.NewGaugeFunc("httpclient_circuit_state",
+		"Circuit breaker state per target host (0 = closed, 1 = open, 2 = half-open).",
+		[]string{"host"},
+		func(emit func(value float64, labelValues ...string)) {
+			for host, state := range httpClient.BreakerStates() {
+				emit(float64(state), host)
+			}
+		})
+
+	metrics.NewCounterFunc("discovery_calls_total",
+		"Total number of discovery-based calls.", nil,
+		func(emit func(value float64, labelValues ...string)) {
+			emit(float64(disc.HedgeStats().Calls))
+		})
+	metrics.NewCounterFunc("discovery_hedged_calls_total",
+		"Total number of calls that sent a hedged request.", nil,
+		func(emit func(value float64, labelValues ...string)) {
+			emit(float64(disc.HedgeStats().Hedged))
+		})
+	metrics.NewCounterFunc("discovery_hedge_wins_total",
+		"Total number of calls won by the hedged request.", nil,
+		func(emit func(value float64, labelValues ...string)) {
+			emit(float64(disc.HedgeStats().HedgeWins))
+		})
+}
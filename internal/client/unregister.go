@@ -2,6 +2,7 @@ package client
 
 //调用api/unregister
 import (
+	"context"
 	"fmt"
 
 	"github.com/sirupsen/logrus"
@@ -10,23 +11,23 @@ import (
 	"MicroService/pkg/model"
 )
 
-func UnregisterService(registryAddrs []string, serviceName, serviceId, ipAddress string, port int) error {
+func UnregisterService(ctx context.Context, registryAddrs []string, serviceName, serviceId, ipAddress string, port int, token, leaseToken string) error {
 	unregisterReq := model.RegisterServiceRequest{
 		ServiceName: serviceName,
 		ServiceId:   serviceId,
 		IpAddress:   ipAddress,
 		Port:        port,
+		LeaseToken:  leaseToken,
 	}
 
-	clientConfig := httpclient.DefaultConfig()
-	httpClient := httpclient.NewClient(clientConfig)
+	httpClient := httpclient.NewClient(httpclient.DefaultConfig())
 
 	// 遍历所有注册中心地址，向每个地址发送注销请求
 	for _, registryAddr := range registryAddrs {
 		unregisterURL := fmt.Sprintf("%s/api/unregister", registryAddr)
 		var unregisterResp model.RegisterServiceResponse
 
-		err := httpClient.Post(unregisterURL, unregisterReq, &unregisterResp, clientConfig)
+		err := httpClient.PostJSON(ctx, unregisterURL, unregisterReq, &unregisterResp, httpclient.WithIdempotent(), httpclient.WithBearerToken(token))
 		if err != nil {
 			// 注意：这里可以选择返回第一个遇到的错误，或者记录所有错误并继续
 			logrus.Errorf("Failed to unregister client service %s-%s from registry %s: %v", serviceName, serviceId, registryAddr, err)
@@ -0,0 +1,372 @@
+package register
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+)
+
+// ACL 动作
+const (
+	ActionRegister   = "register"
+	ActionUnregister = "unregister"
+	ActionHeartbeat  = "heartbeat"
+	ActionStatus     = "status"
+	ActionDiscover   = "discover"
+	ActionSync       = "sync"
+	ActionAdmin      = "admin"
+)
+
+// ACL 规则效果
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// principalAnonymous 匹配没有携带令牌的调用方（未启用认证或服务发现等公开接口）
+const principalAnonymous = "anonymous"
+
+var validActions = map[string]bool{
+	ActionRegister: true, ActionUnregister: true, ActionHeartbeat: true, ActionStatus: true,
+	ActionDiscover: true, ActionSync: true, ActionAdmin: true, "*": true,
+}
+
+// ACLRule 是一条访问控制规则
+// Principals 支持：身份名称、"kind:<service|peer|admin>"、"anonymous" 和 "*"
+// Services 和 Namespaces 支持 path.Match 通配符，例如 "payment-*"；Namespaces 为空时适用于所有命名空间
+type ACLRule struct {
+	ID         string   `json:"id"`
+	Effect     string   `json:"effect"`
+	Principals []string `json:"principals"`
+	Actions    []string `json:"actions"`
+	Services   []string `json:"services"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// ACLPolicy 是完整的访问控制策略：任意 deny 规则命中即拒绝，否则任意 allow 规则命中即允许，都不命中时使用 DefaultEffect
+type ACLPolicy struct {
+	DefaultEffect string    `json:"defaultEffect"`
+	Rules         []ACLRule `json:"rules"`
+}
+
+// Validate 校验策略并补全默认值
+func (p *ACLPolicy) Validate() error {
+	if p.DefaultEffect == "" {
+		p.DefaultEffect = EffectDeny
+	}
+	if p.DefaultEffect != EffectAllow && p.DefaultEffect != EffectDeny {
+		return fmt.Errorf("invalid defaultEffect %q", p.DefaultEffect)
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return fmt.Errorf("rule %s: invalid effect %q", rule.ID, rule.Effect)
+		}
+		if len(rule.Principals) == 0 || len(rule.Actions) == 0 || len(rule.Services) == 0 {
+			return fmt.Errorf("rule %s: principals, actions and services are required", rule.ID)
+		}
+		for _, action := range rule.Actions {
+			if !validActions[action] {
+				return fmt.Errorf("rule %s: unknown action %q", rule.ID, action)
+			}
+		}
+		for _, pattern := range rule.Services {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %s: invalid service pattern %q", rule.ID, pattern)
+			}
+		}
+		for _, pattern := range rule.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %s: invalid namespace pattern %q", rule.ID, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// matches 判断规则是否适用于指定身份、动作、命名空间和服务名
+func (rule ACLRule) matches(identity Identity, action, namespace, service string) bool {
+	return matchPrincipal(rule.Principals, identity) && matchAny(rule.Actions, action) &&
+		(len(rule.Namespaces) == 0 || matchService(rule.Namespaces, namespace)) && matchService(rule.Services, service)
+}
+
+func matchPrincipal(principals []string, identity Identity) bool {
+	for _, p := range principals {
+		switch {
+		case p == "*":
+			return true
+		case p == principalAnonymous:
+			if identity.Kind == "" {
+				return true
+			}
+		case strings.HasPrefix(p, "kind:"):
+			if identity.Kind != "" && strings.TrimPrefix(p, "kind:") == identity.Kind {
+				return true
+			}
+		default:
+			if identity.Kind != "" && p == identity.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAny(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchService(patterns []string, service string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, service); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// aclEngine 保存当前策略；未加载策略时不做 ACL 检查，只使用令牌范围
+type aclEngine struct {
+	mu     sync.RWMutex
+	policy *ACLPolicy
+	file   string // 策略文件，通过管理接口修改后写回
+}
+
+// newACLEngine 从策略文件加载 ACL，文件未配置或不存在时不启用
+func newACLEngine(file string) *aclEngine {
+	e := &aclEngine{file: file}
+	if file == "" {
+		return e
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		logrus.Warnf("ACL file %s does not exist, ACL is disabled until a policy is set via the admin API", file)
+		return e
+	}
+	if err != nil {
+		logrus.Fatalf("Failed to read ACL file %s: %v", file, err)
+	}
+	var policy ACLPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		logrus.Fatalf("Failed to parse ACL file %s: %v", file, err)
+	}
+	if err := policy.Validate(); err != nil {
+		logrus.Fatalf("Invalid ACL file %s: %v", file, err)
+	}
+	e.policy = &policy
+	logrus.Infof("Loaded ACL policy with %d rules from %s (default %s)", len(policy.Rules), file, policy.DefaultEffect)
+	return e
+}
+
+// enabled 判断是否已加载策略
+func (e *aclEngine) enabled() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.policy != nil
+}
+
+// get 返回当前策略的副本，未启用时返回 nil
+func (e *aclEngine) get() *ACLPolicy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.policy == nil {
+		return nil
+	}
+	policy := *e.policy
+	policy.Rules = append([]ACLRule(nil), e.policy.Rules...)
+	return &policy
+}
+
+// set 写回策略文件后替换当前策略，policy 为 nil 时关闭 ACL
+// 写入失败时保留原策略，避免生效的策略与文件不一致
+func (e *aclEngine) set(policy *ACLPolicy) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.persist(policy); err != nil {
+		return err
+	}
+	e.policy = policy
+	return nil
+}
+
+// persist 将策略原子地写入策略文件，policy 为 nil 时删除文件
+func (e *aclEngine) persist(policy *ACLPolicy) error {
+	if e.file == "" {
+		return nil
+	}
+	if policy == nil {
+		if err := os.Remove(e.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(e.file, data)
+}
+
+// evaluate 判断是否允许，返回命中的规则 ID（使用默认效果时为 "default"）
+func (e *aclEngine) evaluate(identity Identity, action, namespace, service string) (bool, string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.policy == nil {
+		return true, ""
+	}
+	allowedBy := ""
+	for _, rule := range e.policy.Rules {
+		if !rule.matches(identity, action, namespace, service) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return false, rule.ID
+		}
+		if allowedBy == "" {
+			allowedBy = rule.ID
+		}
+	}
+	if allowedBy != "" {
+		return true, allowedBy
+	}
+	return e.policy.DefaultEffect == EffectAllow, "default"
+}
+
+// aclSummary 返回用于审计记录的策略摘要
+func aclSummary(policy *ACLPolicy) string {
+	if policy == nil {
+		return "ACL disabled"
+	}
+	return fmt.Sprintf("%d rules, default %s", len(policy.Rules), policy.DefaultEffect)
+}
+
+// identityOf 返回 gin 上下文中认证通过的身份，未认证时返回空身份（匿名）
+func identityOf(c *gin.Context) Identity {
+	value, _ := c.Get(identityKey)
+	identity, _ := value.(Identity)
+	return identity
+}
+
+// principalName 返回用于日志的调用方名称
+func principalName(identity Identity) string {
+	if identity.Kind == "" {
+		return principalAnonymous
+	}
+	return identity.Name
+}
+
+// aclPermits 按 ACL 检查身份能否对服务执行动作，管理员身份不受 ACL 限制；不记录日志，用于过滤列表
+func (r *Register) aclPermits(identity Identity, action, namespace, service string) bool {
+	if identity.Kind == IdentityAdmin {
+		return true
+	}
+	allowed, _ := r.acl.evaluate(identity, action, namespace, service)
+	return allowed
+}
+
+// aclAllows 按 ACL 检查调用方能否对请求命名空间中的服务执行动作，管理员身份不受 ACL 限制；拒绝时记录审计事件
+func (r *Register) aclAllows(c *gin.Context, action, service string) bool {
+	identity := identityOf(c)
+	if identity.Kind == IdentityAdmin {
+		return true
+	}
+	namespace := namespaceOf(c)
+	allowed, ruleID := r.acl.evaluate(identity, action, namespace, service)
+	if !allowed {
+		logrus.WithContext(c.Request.Context()).Warnf("ACL denied %s %s/%s for %s by rule %s",
+			action, namespace, service, principalName(identity), ruleID)
+		r.auditRequest(c, AuditACLDeny, model.Service{Namespace: namespace, ServiceName: service}, action+" denied by rule "+ruleID)
+	}
+	return allowed
+}
+
+// authorizeACL 按 ACL 检查调用方，拒绝时写入 403 响应并返回 false
+func (r *Register) authorizeACL(c *gin.Context, action, service string) bool {
+	if r.aclAllows(c, action, service) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, model.ErrorResponse{
+		Code:  http.StatusForbidden,
+		Error: fmt.Sprintf("Access denied: %s is not allowed to %s %s", principalName(identityOf(c)), action, service),
+	})
+	return false
+}
+
+// GetACLHandler 返回当前 ACL 策略
+func (r *Register) GetACLHandler(c *gin.Context) {
+	policy := r.acl.get()
+	if policy == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "policy": policy})
+}
+
+// PutACLHandler 替换 ACL 策略，写回策略文件并同步到对等节点
+func (r *Register) PutACLHandler(c *gin.Context) {
+	var policy ACLPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "Invalid ACL policy: " + err.Error(),
+		})
+		return
+	}
+	if err := r.acl.set(&policy); err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to persist ACL policy: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to persist ACL policy",
+		})
+		return
+	}
+	r.auditRequest(c, AuditACLUpdate, model.Service{}, aclSummary(&policy))
+	r.syncACLToPeers(c.Request.Context(), &policy)
+
+	logrus.WithContext(c.Request.Context()).Infof("ACL policy updated by %s: %d rules, default %s",
+		principalName(identityOf(c)), len(policy.Rules), policy.DefaultEffect)
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "policy": policy})
+}
+
+// DeleteACLHandler 关闭 ACL，只保留令牌范围检查，并同步到对等节点
+func (r *Register) DeleteACLHandler(c *gin.Context) {
+	if err := r.acl.set(nil); err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to remove ACL file: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to remove ACL policy",
+		})
+		return
+	}
+	r.auditRequest(c, AuditACLUpdate, model.Service{}, aclSummary(nil))
+	r.syncACLToPeers(c.Request.Context(), nil)
+
+	logrus.WithContext(c.Request.Context()).Infof("ACL disabled by %s", principalName(identityOf(c)))
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
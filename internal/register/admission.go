@@ -0,0 +1,277 @@
+package register
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/model"
+)
+
+// 准入拒绝原因码，随 ErrorResponse.Reason 返回
+const (
+	ReasonNamePattern      = "SERVICE_NAME_NOT_ALLOWED"
+	ReasonIPNotAllowed     = "IP_NOT_ALLOWED"
+	ReasonPortNotAllowed   = "PORT_NOT_ALLOWED"
+	ReasonMaxInstances     = "MAX_INSTANCES_EXCEEDED"
+	ReasonMissingMetadata  = "MISSING_METADATA"
+	ReasonDuplicateAddress = "DUPLICATE_ADDRESS"
+	ReasonNamespaceQuota   = "NAMESPACE_QUOTA_EXCEEDED"
+)
+
+// 重复地址策略：同一 ip:port 已被其他 ServiceId 注册时的处理方式
+const (
+	DuplicateAllow   = "allow"   // 允许（默认，与之前的行为一致）
+	DuplicateReject  = "reject"  // 拒绝新的注册
+	DuplicateReplace = "replace" // 视为同一服务重启后的新实例，驱逐已失效或租约相同的旧实例；旧实例仍然健康或地址被其他服务占用时拒绝
+)
+
+// PortRange 是闭区间端口范围
+type PortRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// AdmissionRule 是一组准入条件，零值字段不做限制
+type AdmissionRule struct {
+	AllowedCIDRs     []string    `json:"allowedCIDRs,omitempty"`
+	PortRanges       []PortRange `json:"portRanges,omitempty"`
+	MaxInstances     int         `json:"maxInstances,omitempty"` // 每个命名空间中该服务的最大实例数
+	RequiredMetadata []string    `json:"requiredMetadata,omitempty"`
+
+	nets []*net.IPNet
+}
+
+// AdmissionPolicy 是注册准入策略
+// Services 中按服务名配置的规则逐字段覆盖 Default，未配置的字段沿用 Default
+type AdmissionPolicy struct {
+	ServiceNamePattern string                   `json:"serviceNamePattern,omitempty"`
+	DuplicateAddress   string                   `json:"duplicateAddress,omitempty"`
+	Default            AdmissionRule            `json:"default"`
+	Services           map[string]AdmissionRule `json:"services,omitempty"`
+
+	namePattern *regexp.Regexp
+}
+
+// compile 校验规则并解析 CIDR
+func (rule *AdmissionRule) compile() error {
+	rule.nets = nil
+	for _, cidr := range rule.AllowedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		rule.nets = append(rule.nets, ipNet)
+	}
+	for _, pr := range rule.PortRanges {
+		if pr.Min < 1 || pr.Max > 65535 || pr.Min > pr.Max {
+			return fmt.Errorf("invalid port range %d-%d", pr.Min, pr.Max)
+		}
+	}
+	if rule.MaxInstances < 0 {
+		return fmt.Errorf("invalid maxInstances %d", rule.MaxInstances)
+	}
+	return nil
+}
+
+// Validate 校验策略并编译正则和 CIDR
+func (p *AdmissionPolicy) Validate() error {
+	if p.DuplicateAddress == "" {
+		p.DuplicateAddress = DuplicateAllow
+	}
+	switch p.DuplicateAddress {
+	case DuplicateAllow, DuplicateReject, DuplicateReplace:
+	default:
+		return fmt.Errorf("invalid duplicateAddress %q", p.DuplicateAddress)
+	}
+	p.namePattern = nil
+	if p.ServiceNamePattern != "" {
+		re, err := regexp.Compile(p.ServiceNamePattern)
+		if err != nil {
+			return fmt.Errorf("invalid serviceNamePattern: %v", err)
+		}
+		p.namePattern = re
+	}
+	if err := p.Default.compile(); err != nil {
+		return fmt.Errorf("default: %v", err)
+	}
+	for name, rule := range p.Services {
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("service %s: %v", name, err)
+		}
+		p.Services[name] = rule
+	}
+	return nil
+}
+
+// ruleFor 返回服务的有效规则
+func (p *AdmissionPolicy) ruleFor(serviceName string) AdmissionRule {
+	rule := p.Default
+	override, ok := p.Services[serviceName]
+	if !ok {
+		return rule
+	}
+	if len(override.AllowedCIDRs) > 0 {
+		rule.AllowedCIDRs, rule.nets = override.AllowedCIDRs, override.nets
+	}
+	if len(override.PortRanges) > 0 {
+		rule.PortRanges = override.PortRanges
+	}
+	if override.MaxInstances > 0 {
+		rule.MaxInstances = override.MaxInstances
+	}
+	if len(override.RequiredMetadata) > 0 {
+		rule.RequiredMetadata = override.RequiredMetadata
+	}
+	return rule
+}
+
+// loadAdmissionPolicy 从策略文件加载准入策略，未配置文件时只做基本校验
+// duplicateAddress 不为空时覆盖策略文件中的重复地址策略
+func loadAdmissionPolicy(file, duplicateAddress string) *AdmissionPolicy {
+	policy := &AdmissionPolicy{}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			logrus.Fatalf("Failed to read admission policy file %s: %v", file, err)
+		}
+		if err := json.Unmarshal(data, policy); err != nil {
+			logrus.Fatalf("Failed to parse admission policy file %s: %v", file, err)
+		}
+	}
+	if duplicateAddress != "" {
+		policy.DuplicateAddress = duplicateAddress
+	}
+	if err := policy.Validate(); err != nil {
+		logrus.Fatalf("Invalid admission policy %s: %v", file, err)
+	}
+	if file != "" {
+		logrus.Infof("Loaded admission policy from %s (%d service rules)", file, len(policy.Services))
+	}
+	return policy
+}
+
+// admissionError 是准入拒绝
+type admissionError struct {
+	status  int
+	reason  string
+	message string
+}
+
+func reject(status int, reason, format string, args ...interface{}) *admissionError {
+	return &admissionError{status: status, reason: reason, message: fmt.Sprintf(format, args...)}
+}
+
+// admit 检查实例能否注册，返回 nil 表示允许；调用方需持有 quotaMu
+// 重复地址策略为 replace 时，返回同一地址上需要被新实例取代的旧实例，由调用方删除
+// fromPeer 为 true 时是对等节点同步的注册：重复地址已由发起节点按策略处理，取代的旧实例另行同步，不再检查
+func (r *Register) admit(service model.Service, fromPeer bool) ([]model.Service, *admissionError) {
+	p := r.admission
+	if p.namePattern != nil && !p.namePattern.MatchString(service.ServiceName) {
+		return nil, reject(http.StatusUnprocessableEntity, ReasonNamePattern,
+			"Service name %s does not match pattern %s", service.ServiceName, p.ServiceNamePattern)
+	}
+
+	rule := p.ruleFor(service.ServiceName)
+	if len(rule.nets) > 0 {
+		ip := net.ParseIP(service.IpAddress)
+		allowed := false
+		for _, ipNet := range rule.nets {
+			if ip != nil && ipNet.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, reject(http.StatusUnprocessableEntity, ReasonIPNotAllowed,
+				"IP address %s is not in the allowed ranges %v for %s", service.IpAddress, rule.AllowedCIDRs, service.ServiceName)
+		}
+	}
+	if len(rule.PortRanges) > 0 {
+		allowed := false
+		for _, pr := range rule.PortRanges {
+			if service.Port >= pr.Min && service.Port <= pr.Max {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, reject(http.StatusUnprocessableEntity, ReasonPortNotAllowed,
+				"Port %d is not in the allowed ranges for %s", service.Port, service.ServiceName)
+		}
+	}
+	var missing []string
+	for _, key := range rule.RequiredMetadata {
+		if service.Metadata[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, reject(http.StatusUnprocessableEntity, ReasonMissingMetadata,
+			"Missing required metadata for %s: %s", service.ServiceName, strings.Join(missing, ", "))
+	}
+
+	// 实例数和重复地址都需要扫描已有实例，同一 ServiceId 的重复注册不计入
+	// replace 策略下同一命名空间、同一服务的旧实例将被取代，不计入实例数
+	// 只取代已经失效的旧实例，仍在发送心跳的实例受租约保护，除非新实例出示旧实例的租约
+	now := time.Now()
+	count := 0
+	var duplicate *model.Service
+	var stale []model.Service
+	r.services.Range(func(_, value interface{}) bool {
+		s := value.(model.Service)
+		if s.ServiceId == service.ServiceId && s.Namespace == service.Namespace {
+			return true
+		}
+		sameService := s.Namespace == service.Namespace && s.ServiceName == service.ServiceName
+		if !fromPeer && s.IpAddress == service.IpAddress && s.Port == service.Port {
+			if sameService && p.DuplicateAddress == DuplicateReplace && r.replaceable(s, service, now) {
+				stale = append(stale, s)
+				return true
+			}
+			if duplicate == nil {
+				duplicate = &s
+			}
+		}
+		if sameService {
+			count++
+		}
+		return true
+	})
+	if rule.MaxInstances > 0 && count >= rule.MaxInstances {
+		return nil, reject(http.StatusUnprocessableEntity, ReasonMaxInstances,
+			"Service %s has reached its maximum of %d instances", service.ServiceName, rule.MaxInstances)
+	}
+	if duplicate != nil {
+		if p.DuplicateAddress == DuplicateAllow {
+			logrus.Warnf("Address %s:%d of %s-%s is already registered by %s-%s",
+				service.IpAddress, service.Port, service.ServiceName, service.ServiceId, duplicate.ServiceName, duplicate.ServiceId)
+		} else {
+			return nil, reject(http.StatusConflict, ReasonDuplicateAddress,
+				"Address %s:%d is already registered by %s-%s", service.IpAddress, service.Port, duplicate.ServiceName, duplicate.ServiceId)
+		}
+	}
+	return stale, nil
+}
+
+// replaceable 判断 replace 策略下旧实例能否被同一地址上的新实例取代：
+// 旧实例心跳已超时、状态不是 UP，或新实例出示了旧实例的租约（例如实例重启后使用持久化的租约）
+func (r *Register) replaceable(old, service model.Service, now time.Time) bool {
+	if !r.isHealthy(old, now) {
+		return true
+	}
+	return old.LeaseToken != "" && service.LeaseToken != "" && leaseMatches(old, service.LeaseToken)
+}
+
+// AdmissionHandler 返回当前的准入策略
+func (r *Register) AdmissionHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, r.admission)
+}
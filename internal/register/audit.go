@@ -0,0 +1,378 @@
+package register
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/logging"
+	"MicroService/pkg/model"
+)
+
+// 审计事件来源
+const (
+	AuditSourceAPI     = "api"     // 实例直接调用写接口
+	AuditSourceSync    = "sync"    // 应用对等节点推送的变更
+	AuditSourceCleanup = "cleanup" // 心跳超时被清理
+	AuditSourceAdmin   = "admin"   // 管理接口操作
+)
+
+// 审计动作，实例变更之外还包括令牌、ACL 变更、ACL 拒绝、准入拒绝、webhook 订阅变更和管理操作
+const (
+	AuditRegister       = "register"
+	AuditUnregister     = "unregister"
+	AuditStatus         = "status"
+	AuditExpire         = "expire"
+	AuditReplace        = "replace" // 同一地址上的旧实例被新注册的实例取代
+	AuditTokenIssue     = "token-issue"
+	AuditTokenRevoke    = "token-revoke"
+	AuditACLUpdate      = "acl-update"
+	AuditACLDeny        = "acl-deny"
+	AuditAdmission      = "admission-reject"
+	AuditWebhookCreate  = "webhook-create"
+	AuditWebhookDelete  = "webhook-delete"
+	AuditEvict          = "evict" // 管理员强制移除实例
+	AuditMaintenanceOn  = "maintenance-on"
+	AuditMaintenanceOff = "maintenance-off"
+	AuditCleanup        = "cleanup" // 管理员触发的立即清理，被清理的实例另有 expire 记录
+	AuditImport         = "import"  // 目录导入，变更的实例另有 register、unregister 记录
+)
+
+// AuditEvent 是一条审计记录
+type AuditEvent struct {
+	Time        time.Time `json:"time"`
+	Node        string    `json:"node"`             // 记录事件的注册中心节点
+	Origin      string    `json:"origin,omitempty"` // 同步事件的发起节点
+	Source      string    `json:"source"`
+	Action      string    `json:"action"`
+	Principal   string    `json:"principal,omitempty"` // 调用方身份，清理事件为空
+	Namespace   string    `json:"namespace,omitempty"`
+	ServiceName string    `json:"serviceName,omitempty"`
+	ServiceId   string    `json:"serviceId,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	ClientIP    string    `json:"clientIp,omitempty"`
+	RequestID   string    `json:"requestId,omitempty"`
+}
+
+// AuditConfig 定义审计日志的配置
+type AuditConfig struct {
+	File       string // 审计日志文件（JSON Lines）
+	MaxSize    int64  // 单个文件的最大字节数，超过后轮转
+	MaxBackups int    // 保留的轮转文件数（file.1 最新）
+}
+
+// auditLog 是只追加的审计日志，按大小轮转
+type auditLog struct {
+	mu     sync.Mutex
+	config AuditConfig
+	file   *os.File
+	size   int64
+}
+
+// newAuditLog 打开审计日志文件，File 为空时只输出到日志
+func newAuditLog(config AuditConfig) *auditLog {
+	a := &auditLog{config: config}
+	if config.File == "" {
+		return a
+	}
+	if err := a.open(); err != nil {
+		logrus.Fatalf("Failed to open audit log %s: %v", config.File, err)
+	}
+	return a
+}
+
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.file = f
+	a.size = info.Size()
+	return nil
+}
+
+// backupName 返回第 n 个轮转文件的路径
+func (a *auditLog) backupName(n int) string {
+	return a.config.File + "." + strconv.Itoa(n)
+}
+
+// rotate 关闭当前文件并依次后移轮转文件，超出 MaxBackups 的最旧文件被删除
+func (a *auditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	a.file = nil
+	if a.config.MaxBackups <= 0 {
+		if err := os.Remove(a.config.File); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return a.open()
+	}
+	os.Remove(a.backupName(a.config.MaxBackups))
+	for n := a.config.MaxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(a.backupName(n), a.backupName(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(a.config.File, a.backupName(1)); err != nil {
+		return err
+	}
+	return a.open()
+}
+
+// record 追加一条审计记录
+func (a *auditLog) record(event AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	logrus.WithFields(logrus.Fields{
+		"audit":       event.Action,
+		"source":      event.Source,
+		"principal":   event.Principal,
+		"serviceName": event.ServiceName,
+		"serviceId":   event.ServiceId,
+	}).Debug("Audit event")
+
+	if a.config.File == "" {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		logrus.Errorf("Failed to encode audit event: %v", err)
+		return
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		// 之前的轮转失败，重新尝试打开
+		if err := a.open(); err != nil {
+			logrus.Errorf("Failed to reopen audit log: %v", err)
+			return
+		}
+	}
+	if a.config.MaxSize > 0 && a.size > 0 && a.size+int64(len(data)) > a.config.MaxSize {
+		if err := a.rotate(); err != nil {
+			logrus.Errorf("Failed to rotate audit log: %v", err)
+			if a.file == nil {
+				return
+			}
+		}
+	}
+	n, err := a.file.Write(data)
+	a.size += int64(n)
+	if err != nil {
+		logrus.Errorf("Failed to write audit event: %v", err)
+	}
+}
+
+// AuditQuery 是审计日志的查询条件，零值字段不过滤
+type AuditQuery struct {
+	Namespace string
+	Service   string
+	Since     time.Time
+	Action    string
+	Source    string
+	Limit     int
+}
+
+func (q AuditQuery) matches(event AuditEvent) bool {
+	if q.Namespace != "" && event.Namespace != q.Namespace {
+		return false
+	}
+	if q.Service != "" && event.ServiceName != q.Service && event.ServiceId != q.Service {
+		return false
+	}
+	if !q.Since.IsZero() && event.Time.Before(q.Since) {
+		return false
+	}
+	if q.Action != "" && event.Action != q.Action {
+		return false
+	}
+	if q.Source != "" && event.Source != q.Source {
+		return false
+	}
+	return true
+}
+
+// query 按时间顺序读取轮转文件和当前文件，返回最近的 Limit 条匹配记录
+func (a *auditLog) query(q AuditQuery) ([]AuditEvent, error) {
+	if a.config.File == "" {
+		return nil, nil
+	}
+	readers, closeAll, err := a.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll()
+
+	// 扫描在锁外进行，不阻塞审计记录的写入
+	var events []AuditEvent
+	for _, r := range readers {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var event AuditEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				continue // 跳过损坏的行（例如进程崩溃时写了一半）
+			}
+			if q.matches(event) {
+				events = append(events, event)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[len(events)-q.Limit:]
+	}
+	return events, nil
+}
+
+// snapshot 在锁内按时间顺序打开轮转文件和当前文件；打开的文件不受之后轮转的影响，
+// 当前文件只读到快照时的大小
+func (a *auditLog) snapshot() ([]io.Reader, func(), error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var files []*os.File
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	names := make([]string, 0, a.config.MaxBackups+1)
+	for n := a.config.MaxBackups; n >= 1; n-- {
+		names = append(names, a.backupName(n))
+	}
+	names = append(names, a.config.File)
+	readers := make([]io.Reader, 0, len(names))
+	for i, name := range names {
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		if i == len(names)-1 && a.file != nil {
+			readers = append(readers, io.LimitReader(f, a.size))
+		} else {
+			readers = append(readers, f)
+		}
+	}
+	return readers, closeAll, nil
+}
+
+// close 关闭审计日志文件
+func (a *auditLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// auditRequest 记录由 HTTP 请求触发的事件，来源根据调用方身份判断
+func (r *Register) auditRequest(c *gin.Context, action string, service model.Service, reason string) {
+	r.audit.record(r.requestEvent(c, action, service, reason))
+}
+
+// requestEvent 创建由 HTTP 请求触发的审计事件，调用方身份、客户端 IP 和请求 ID 取自 gin 上下文
+func (r *Register) requestEvent(c *gin.Context, action string, service model.Service, reason string) AuditEvent {
+	return AuditEvent{
+		Node:        r.nodeID,
+		Source:      auditSource(c),
+		Action:      action,
+		Principal:   principalName(identityOf(c)),
+		Namespace:   service.Namespace,
+		ServiceName: service.ServiceName,
+		ServiceId:   service.ServiceId,
+		Reason:      reason,
+		ClientIP:    c.ClientIP(),
+		RequestID:   logging.RequestIDFromContext(c.Request.Context()),
+	}
+}
+
+// auditSourceKey 是 gin 上下文中显式指定的事件来源，同步接口在未启用认证时也能记录为 sync
+const auditSourceKey = "auditSource"
+
+// auditSource 判断事件来源：显式指定的来源优先，其次管理员为 admin，对等节点为 sync，其他为 api
+func auditSource(c *gin.Context) string {
+	if source := c.GetString(auditSourceKey); source != "" {
+		return source
+	}
+	switch identityOf(c).Kind {
+	case IdentityAdmin:
+		return AuditSourceAdmin
+	case IdentityPeer:
+		return AuditSourceSync
+	default:
+		return AuditSourceAPI
+	}
+}
+
+const (
+	defaultAuditLimit = 1000
+	maxAuditLimit     = 10000
+)
+
+// AuditHandler 查询审计日志
+// 参数：namespace、service（服务名或 serviceId）、since（RFC3339 时间或相对时长，例如 1h）、action、source、limit
+func (r *Register) AuditHandler(c *gin.Context) {
+	q := AuditQuery{
+		Namespace: c.Query("namespace"),
+		Service:   c.Query("service"),
+		Action:    c.Query("action"),
+		Source:    c.Query("source"),
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := parseTimeParam(since, time.Now())
+		if err != nil {
+			badRequest(c, "Invalid since parameter, "+err.Error())
+			return
+		}
+		q.Since = t
+	}
+	limit, err := parsePositiveInt(c.Query("limit"), defaultAuditLimit)
+	if err != nil || limit > maxAuditLimit {
+		badRequest(c, fmt.Sprintf("Invalid limit parameter, must be between 1 and %d", maxAuditLimit))
+		return
+	}
+	q.Limit = limit
+
+	events, err := r.audit.query(q)
+	if err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to read audit log: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to read audit log",
+		})
+		return
+	}
+	if events == nil {
+		events = []AuditEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "count": len(events)})
+}
@@ -0,0 +1,476 @@
+package register
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+)
+
+// 身份类型
+const (
+	IdentityService = "service" // 服务令牌，只能操作令牌范围内的服务名
+	IdentityPeer    = "peer"    // 对等注册中心，只能调用内部同步接口
+	IdentityAdmin   = "admin"   // 管理员，可以调用所有接口
+)
+
+// identityKey 是认证通过后身份在 gin 上下文中的键
+const identityKey = "identity"
+
+// APIToken 是预共享的服务令牌
+type APIToken struct {
+	Name     string   // 令牌名称，用于日志和审计
+	Secret   string   // 令牌明文
+	Services []string // 允许操作的服务，格式见 Identity.Allows
+}
+
+// AuthConfig 定义写接口的认证配置
+// 未配置任何凭据时不启用认证，保持与旧部署兼容
+type AuthConfig struct {
+	Tokens     []APIToken // 预共享的服务令牌
+	PeerToken  string     // 对等节点之间同步使用的令牌
+	AdminToken string     // 管理接口令牌，也可用于签发服务令牌
+	TokensFile string     // 签发令牌文件（只保存哈希），为空时签发的令牌只保存在内存中，重启后需要重新签发
+}
+
+// Identity 是认证通过的调用方
+type Identity struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`
+	Services []string `json:"services,omitempty"`
+}
+
+// Allows 判断身份是否可以操作命名空间中的服务
+// 令牌范围的格式："命名空间/服务名"，命名空间或服务名为 "*" 时匹配全部；
+// 不带命名空间的服务名只匹配默认命名空间，单独的 "*" 匹配所有命名空间的所有服务
+func (id Identity) Allows(namespace, serviceName string) bool {
+	if id.Kind == IdentityAdmin {
+		return true
+	}
+	if id.Kind != IdentityService {
+		return false
+	}
+	namespace = normalizeNamespace(namespace)
+	for _, scope := range id.Services {
+		if scope == "*" {
+			return true
+		}
+		ns, name, ok := strings.Cut(scope, "/")
+		if !ok {
+			ns, name = DefaultNamespace, scope
+		}
+		if (ns == "*" || ns == namespace) && (name == "*" || name == serviceName) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenRecord 是令牌的存储形式，只保存哈希；签发的令牌通过对等同步传播到其他节点
+type TokenRecord struct {
+	Name     string   `json:"name"`
+	Hash     string   `json:"hash,omitempty"`
+	Kind     string   `json:"kind"`
+	Services []string `json:"services,omitempty"`
+	Issued   bool     `json:"issued"` // 是否由管理接口签发（预共享令牌不可吊销）
+}
+
+// errTokenConflict 表示签发的令牌与预共享、对等节点或管理员令牌的哈希相同
+var errTokenConflict = errors.New("token conflicts with a configured token")
+
+// tokenStore 按令牌哈希保存身份
+type tokenStore struct {
+	mu      sync.RWMutex
+	enabled bool
+	file    string
+	byHash  map[string]TokenRecord
+}
+
+// hashToken 返回令牌的 SHA-256 十六进制哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newTokenStore(config AuthConfig) *tokenStore {
+	s := &tokenStore{file: config.TokensFile, byHash: make(map[string]TokenRecord)}
+	for _, t := range config.Tokens {
+		s.put(TokenRecord{Name: t.Name, Hash: hashToken(t.Secret), Kind: IdentityService, Services: t.Services})
+	}
+	if config.PeerToken != "" {
+		s.put(TokenRecord{Name: "peer", Hash: hashToken(config.PeerToken), Kind: IdentityPeer})
+	}
+	if config.AdminToken != "" {
+		s.put(TokenRecord{Name: "admin", Hash: hashToken(config.AdminToken), Kind: IdentityAdmin})
+	}
+	s.load()
+	s.enabled = len(s.byHash) > 0
+	if !s.enabled {
+		logrus.Warn("Registry authentication is disabled: no API, peer or admin tokens configured")
+	} else if config.PeerToken == "" {
+		logrus.Warn("Registry authentication is enabled but no peer token is configured; peer sync will be rejected")
+	}
+	return s
+}
+
+func (s *tokenStore) put(record TokenRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHash[record.Hash] = record
+}
+
+// validateIssuedToken 检查签发令牌记录：签发令牌只能是服务令牌，哈希为 SHA-256 十六进制
+func validateIssuedToken(record TokenRecord) error {
+	if record.Name == "" {
+		return errors.New("token name is required")
+	}
+	if record.Kind != IdentityService {
+		return fmt.Errorf("issued token %s must be a %s token, got %q", record.Name, IdentityService, record.Kind)
+	}
+	if len(record.Hash) != sha256.Size*2 {
+		return fmt.Errorf("token %s has an invalid hash", record.Name)
+	}
+	if _, err := hex.DecodeString(record.Hash); err != nil {
+		return fmt.Errorf("token %s has an invalid hash", record.Name)
+	}
+	if len(record.Services) == 0 {
+		return fmt.Errorf("token %s has no services", record.Name)
+	}
+	for _, scope := range record.Services {
+		if ns, name, ok := strings.Cut(scope, "/"); scope == "" || (ok && (ns == "" || name == "" || strings.Contains(name, "/"))) {
+			return fmt.Errorf("token %s has an invalid service scope %q", record.Name, scope)
+		}
+	}
+	return nil
+}
+
+// issue 保存签发的令牌并持久化；不能覆盖预共享、对等节点或管理员令牌
+// 持久化失败时撤销本次修改
+func (s *tokenStore) issue(record TokenRecord) error {
+	record.Issued = true
+	if err := validateIssuedToken(record); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, existed := s.byHash[record.Hash]
+	if existed && !previous.Issued {
+		return fmt.Errorf("token %s: %w", record.Name, errTokenConflict)
+	}
+	s.byHash[record.Hash] = record
+	if err := s.saveLocked(); err != nil {
+		if existed {
+			s.byHash[record.Hash] = previous
+		} else {
+			delete(s.byHash, record.Hash)
+		}
+		return err
+	}
+	return nil
+}
+
+// revoke 删除指定名称的签发令牌并持久化，返回是否存在；持久化失败时撤销本次修改
+func (s *tokenStore) revoke(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := make(map[string]TokenRecord)
+	for hash, record := range s.byHash {
+		if record.Issued && record.Name == name {
+			removed[hash] = record
+			delete(s.byHash, hash)
+		}
+	}
+	if len(removed) == 0 {
+		return false, nil
+	}
+	if err := s.saveLocked(); err != nil {
+		for hash, record := range removed {
+			s.byHash[hash] = record
+		}
+		return true, err
+	}
+	return true, nil
+}
+
+// load 从签发令牌文件加载令牌，文件不存在时没有签发令牌
+func (s *tokenStore) load() {
+	if s.file == "" {
+		return
+	}
+	data, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		logrus.Fatalf("Failed to read tokens file %s: %v", s.file, err)
+	}
+	var records []TokenRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		logrus.Fatalf("Failed to parse tokens file %s: %v", s.file, err)
+	}
+	for _, record := range records {
+		record.Issued = true
+		if err := validateIssuedToken(record); err != nil {
+			logrus.Fatalf("Invalid token in %s: %v", s.file, err)
+		}
+		if previous, ok := s.byHash[record.Hash]; ok && !previous.Issued {
+			logrus.Fatalf("Invalid token in %s: token %s conflicts with a configured token", s.file, record.Name)
+		}
+		s.byHash[record.Hash] = record
+	}
+	logrus.Infof("Loaded %d issued tokens from %s", len(records), s.file)
+}
+
+// saveLocked 将签发的令牌写回签发令牌文件，调用方需持有 mu
+func (s *tokenStore) saveLocked() error {
+	if s.file == "" {
+		return nil
+	}
+	records := make([]TokenRecord, 0, len(s.byHash))
+	for _, record := range s.byHash {
+		if record.Issued {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 文件包含令牌哈希，只允许当前用户读写
+	return util.WriteFileAtomic(s.file, data)
+}
+
+// lookup 根据令牌明文查找身份
+func (s *tokenStore) lookup(token string) (Identity, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.byHash[hashToken(token)]
+	if !ok {
+		return Identity{}, false
+	}
+	return Identity{Name: record.Name, Kind: record.Kind, Services: record.Services}, true
+}
+
+// list 返回所有令牌的名称和范围（不含哈希）
+func (s *tokenStore) list() []TokenRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]TokenRecord, 0, len(s.byHash))
+	for _, record := range s.byHash {
+		record.Hash = ""
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+	return records
+}
+
+// bearerToken 从 Authorization 头解析 Bearer 令牌
+func bearerToken(c *gin.Context) string {
+	auth := c.GetHeader("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// RequireAuth 返回认证中间件，只允许指定类型的身份访问（管理员总是允许）
+// 未启用认证时直接放行
+func (r *Register) RequireAuth(kinds ...string) gin.HandlerFunc {
+	return r.authenticate(false, kinds)
+}
+
+// RequireAdmin 返回只允许管理员访问的中间件；未配置管理员令牌时管理接口不可用
+// 启用 ACL 时，被授予 admin 动作的其他身份也可以访问
+func (r *Register) RequireAdmin() gin.HandlerFunc {
+	return r.authenticate(true, nil)
+}
+
+// OptionalAuth 返回可选认证中间件：携带令牌时校验并记录身份，未携带时以匿名身份继续，供服务发现等公开接口做 ACL 检查
+func (r *Register) OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" || !r.tokens.enabled {
+			c.Next()
+			return
+		}
+		identity, ok := r.tokens.lookup(token)
+		if !ok {
+			logrus.WithContext(c.Request.Context()).Warnf("Rejected request with invalid token from %s", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{
+				Code:  http.StatusUnauthorized,
+				Error: "Invalid token",
+			})
+			return
+		}
+		c.Set(identityKey, identity)
+		c.Next()
+	}
+}
+
+// authenticate 校验 Bearer 令牌并将身份放入 gin 上下文，strict 为 false 时未启用认证直接放行
+func (r *Register) authenticate(strict bool, kinds []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strict && !r.tokens.enabled {
+			c.Next()
+			return
+		}
+		token := bearerToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{
+				Code:  http.StatusUnauthorized,
+				Error: "Missing bearer token",
+			})
+			return
+		}
+		identity, ok := r.tokens.lookup(token)
+		if !ok {
+			logrus.WithContext(c.Request.Context()).Warnf("Rejected request with invalid token from %s", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{
+				Code:  http.StatusUnauthorized,
+				Error: "Invalid token",
+			})
+			return
+		}
+		c.Set(identityKey, identity)
+		allowed := identity.Kind == IdentityAdmin
+		for _, kind := range kinds {
+			if identity.Kind == kind {
+				allowed = true
+			}
+		}
+		if !allowed && kinds == nil && r.acl.enabled() {
+			// 管理接口：由 ACL 决定是否授予非管理员身份
+			allowed = r.aclAllows(c, ActionAdmin, "*")
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.ErrorResponse{
+				Code:  http.StatusForbidden,
+				Error: "Identity " + identity.Name + " is not allowed to call this endpoint",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizeService 检查调用方是否可以对指定服务名执行动作（令牌范围和 ACL），不允许时写入 403 响应并返回 false
+func (r *Register) authorizeService(c *gin.Context, action, serviceName string) bool {
+	namespace := namespaceOf(c)
+	if r.tokens.enabled && !identityOf(c).Allows(namespace, serviceName) {
+		identity := identityOf(c)
+		c.JSON(http.StatusForbidden, model.ErrorResponse{
+			Code:  http.StatusForbidden,
+			Error: "Token " + identity.Name + " is not allowed to manage service " + namespace + "/" + serviceName,
+		})
+		return false
+	}
+	return r.authorizeACL(c, action, serviceName)
+}
+
+// IssueTokenRequest 是签发服务令牌的请求
+type IssueTokenRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Services []string `json:"services" binding:"required"` // 令牌范围，格式见 Identity.Allows
+}
+
+// IssueTokenResponse 是签发服务令牌的响应，令牌明文只在此返回一次
+type IssueTokenResponse struct {
+	Name     string   `json:"name"`
+	Token    string   `json:"token"`
+	Services []string `json:"services"`
+}
+
+// IssueTokenHandler 签发限定服务名范围的令牌，并同步到对等节点
+func (r *Register) IssueTokenHandler(c *gin.Context) {
+	var req IssueTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if len(req.Services) == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "At least one service name is required",
+		})
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to generate token",
+		})
+		return
+	}
+	token := hex.EncodeToString(secret)
+	record := TokenRecord{Name: req.Name, Hash: hashToken(token), Kind: IdentityService, Services: req.Services, Issued: true}
+	if err := validateIssuedToken(record); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "Invalid token request: " + err.Error(),
+		})
+		return
+	}
+	if err := r.tokens.issue(record); err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to issue token %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to persist issued token",
+		})
+		return
+	}
+	r.auditRequest(c, AuditTokenIssue, model.Service{}, fmt.Sprintf("token %s for services %v", req.Name, req.Services))
+	r.syncTokenToPeers(c.Request.Context(), record, "token-issue")
+
+	logrus.WithContext(c.Request.Context()).Infof("Issued token %s for services %v", req.Name, req.Services)
+	c.JSON(http.StatusOK, IssueTokenResponse{Name: req.Name, Token: token, Services: req.Services})
+}
+
+// ListTokensHandler 列出所有令牌的名称和范围
+func (r *Register) ListTokensHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"tokens": r.tokens.list()})
+}
+
+// RevokeTokenHandler 吊销签发的令牌，并同步到对等节点
+func (r *Register) RevokeTokenHandler(c *gin.Context) {
+	name := c.Param("name")
+	found, err := r.tokens.revoke(name)
+	if err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to revoke token %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to persist token revocation",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "Issued token not found: " + name,
+		})
+		return
+	}
+	r.auditRequest(c, AuditTokenRevoke, model.Service{}, "token "+name)
+	r.syncTokenToPeers(c.Request.Context(), TokenRecord{Name: name}, "token-revoke")
+
+	logrus.WithContext(c.Request.Context()).Infof("Revoked token %s", name)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Token revoked"})
+}
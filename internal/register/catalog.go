@@ -0,0 +1,461 @@
+package register
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+)
+
+// 目录导出和导入的格式
+const (
+	CatalogFormatJSON = "json"
+	CatalogFormatYAML = "yaml"
+)
+
+// 目录导入模式
+const (
+	ImportMerge   = "merge"   // 新增和更新快照中的实例，保留快照中没有的实例
+	ImportReplace = "replace" // 使范围内的目录与快照一致，删除快照中没有的实例
+)
+
+// CatalogVersion 是目录快照格式的版本
+const CatalogVersion = 1
+
+// reasonImported 是导入引起的实例变更写入审计记录和目录事件的原因
+const reasonImported = "catalog import"
+
+// CatalogInstance 是快照中的实例，与 model.Service 不同，包含租约令牌和最后心跳时间
+type CatalogInstance struct {
+	Namespace     string            `json:"namespace" yaml:"namespace"`
+	ServiceName   string            `json:"serviceName" yaml:"serviceName"`
+	ServiceId     string            `json:"serviceId" yaml:"serviceId"`
+	IpAddress     string            `json:"ipAddress" yaml:"ipAddress"`
+	Port          int               `json:"port" yaml:"port"`
+	Scheme        string            `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	Status        string            `json:"status,omitempty" yaml:"status,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	LeaseToken    string            `json:"leaseToken,omitempty" yaml:"leaseToken,omitempty"`
+	LastHeartbeat time.Time         `json:"lastHeartbeat,omitempty" yaml:"lastHeartbeat,omitempty"` // 仅供参考，导入时以导入时刻作为心跳时间
+}
+
+// CatalogMaintenance 是快照中处于维护状态的服务
+type CatalogMaintenance struct {
+	Namespace   string    `json:"namespace" yaml:"namespace"`
+	ServiceName string    `json:"serviceName" yaml:"serviceName"`
+	Reason      string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Principal   string    `json:"principal,omitempty" yaml:"principal,omitempty"`
+	Since       time.Time `json:"since" yaml:"since"`
+}
+
+// CatalogSnapshot 是导出的完整目录，按命名空间、服务名和 serviceId 排序，便于比较两个集群的导出结果
+type CatalogSnapshot struct {
+	Version     int                  `json:"version" yaml:"version"`
+	Node        string               `json:"node,omitempty" yaml:"node,omitempty"`
+	ExportedAt  time.Time            `json:"exportedAt" yaml:"exportedAt"`
+	Namespace   string               `json:"namespace,omitempty" yaml:"namespace,omitempty"` // 只导出一个命名空间时设置
+	Instances   []CatalogInstance    `json:"instances" yaml:"instances"`
+	Maintenance []CatalogMaintenance `json:"maintenance,omitempty" yaml:"maintenance,omitempty"`
+}
+
+func catalogInstanceOf(s model.Service) CatalogInstance {
+	return CatalogInstance{
+		Namespace:     s.Namespace,
+		ServiceName:   s.ServiceName,
+		ServiceId:     s.ServiceId,
+		IpAddress:     s.IpAddress,
+		Port:          s.Port,
+		Scheme:        s.Scheme,
+		Status:        statusOf(s),
+		Metadata:      s.Metadata,
+		LeaseToken:    s.LeaseToken,
+		LastHeartbeat: s.LastHeartbeat.UTC(),
+	}
+}
+
+func (i CatalogInstance) service() model.Service {
+	return model.Service{
+		Namespace:   normalizeNamespace(i.Namespace),
+		ServiceName: i.ServiceName,
+		ServiceId:   i.ServiceId,
+		IpAddress:   i.IpAddress,
+		Port:        i.Port,
+		Scheme:      i.Scheme,
+		Status:      i.Status,
+		Metadata:    i.Metadata,
+		LeaseToken:  i.LeaseToken,
+	}
+}
+
+// changedFields 返回导入实例相对于已有实例变化的字段，心跳时间不参与比较
+func changedFields(stored, imported model.Service) []string {
+	var fields []string
+	if stored.ServiceName != imported.ServiceName {
+		fields = append(fields, "serviceName")
+	}
+	if stored.IpAddress != imported.IpAddress {
+		fields = append(fields, "ipAddress")
+	}
+	if stored.Port != imported.Port {
+		fields = append(fields, "port")
+	}
+	if schemeOf(stored) != schemeOf(imported) {
+		fields = append(fields, "scheme")
+	}
+	if statusOf(stored) != statusOf(imported) {
+		fields = append(fields, "status")
+	}
+	if len(stored.Metadata) != len(imported.Metadata) || (len(stored.Metadata) > 0 && !reflect.DeepEqual(stored.Metadata, imported.Metadata)) {
+		fields = append(fields, "metadata")
+	}
+	if stored.LeaseToken != imported.LeaseToken {
+		fields = append(fields, "leaseToken")
+	}
+	return fields
+}
+
+func instanceChange(s model.Service, fields []string) model.InstanceChange {
+	return model.InstanceChange{Namespace: s.Namespace, ServiceName: s.ServiceName, ServiceId: s.ServiceId, Fields: fields}
+}
+
+func importRejection(s model.Service, reason, message string) model.ImportRejection {
+	return model.ImportRejection{Namespace: s.Namespace, ServiceName: s.ServiceName, ServiceId: s.ServiceId, Reason: reason, Error: message}
+}
+
+// exportCatalog 返回命名空间中的目录快照，namespace 为空时导出所有命名空间
+func (r *Register) exportCatalog(namespace string) CatalogSnapshot {
+	snapshot := CatalogSnapshot{
+		Version:     CatalogVersion,
+		Node:        r.nodeID,
+		ExportedAt:  time.Now().UTC(),
+		Namespace:   namespace,
+		Instances:   []CatalogInstance{},
+		Maintenance: []CatalogMaintenance{},
+	}
+	for _, s := range r.GetAllServices(namespace) {
+		snapshot.Instances = append(snapshot.Instances, catalogInstanceOf(s))
+	}
+	sort.Slice(snapshot.Instances, func(i, j int) bool {
+		a, b := snapshot.Instances[i], snapshot.Instances[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.ServiceName != b.ServiceName {
+			return a.ServiceName < b.ServiceName
+		}
+		return a.ServiceId < b.ServiceId
+	})
+	for _, record := range r.maintenance.list(namespace) {
+		snapshot.Maintenance = append(snapshot.Maintenance, CatalogMaintenance(record))
+	}
+	return snapshot
+}
+
+// catalogFormat 确定请求的格式：format 参数优先，其次根据 header 指定的媒体类型判断，默认为 JSON
+func catalogFormat(c *gin.Context, header string) (string, bool) {
+	switch format := strings.ToLower(c.Query("format")); format {
+	case CatalogFormatJSON, CatalogFormatYAML:
+		return format, true
+	case "yml":
+		return CatalogFormatYAML, true
+	case "":
+		if strings.Contains(c.GetHeader(header), "yaml") {
+			return CatalogFormatYAML, true
+		}
+		return CatalogFormatJSON, true
+	default:
+		badRequest(c, "Invalid format parameter, must be json or yaml: "+format)
+		return "", false
+	}
+}
+
+// ExportHandler 导出目录
+// 参数：namespace（为空时导出所有命名空间）、format（json 或 yaml，也可以通过 Accept 请求头指定）
+func (r *Register) ExportHandler(c *gin.Context) {
+	format, ok := catalogFormat(c, "Accept")
+	if !ok {
+		return
+	}
+	namespace := c.Query("namespace")
+	if namespace != "" && !namespacePattern.MatchString(namespace) {
+		badRequest(c, "Invalid namespace parameter: "+namespace)
+		return
+	}
+
+	snapshot := r.exportCatalog(namespace)
+	if format == CatalogFormatYAML {
+		data, err := yaml.Marshal(snapshot)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Code:  http.StatusInternalServerError,
+				Error: "Failed to encode catalog: " + err.Error(),
+			})
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, snapshot)
+}
+
+// maxImportSize 是导入请求体的最大字节数
+const maxImportSize = 64 << 20
+
+// decodeCatalog 解析并校验导入的快照，快照中只能包含导入范围内的实例
+// namespace 为空时导入范围取快照导出时的命名空间；两者都设置时必须相同，返回的快照 Namespace 为导入范围
+func decodeCatalog(data []byte, format, namespace string) (CatalogSnapshot, error) {
+	var snapshot CatalogSnapshot
+	var err error
+	if format == CatalogFormatYAML {
+		err = yaml.Unmarshal(data, &snapshot)
+	} else {
+		err = json.Unmarshal(data, &snapshot)
+	}
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to parse catalog: %v", err)
+	}
+	if snapshot.Version != 0 && snapshot.Version != CatalogVersion {
+		return snapshot, fmt.Errorf("unsupported catalog version %d", snapshot.Version)
+	}
+	switch {
+	case snapshot.Namespace == "":
+		snapshot.Namespace = namespace
+	case !namespacePattern.MatchString(snapshot.Namespace):
+		return snapshot, fmt.Errorf("invalid catalog namespace %s", snapshot.Namespace)
+	case namespace != "" && snapshot.Namespace != namespace:
+		// 避免将一个命名空间的导出以 replace 模式导入到另一个范围，删除范围内不相关的实例
+		return snapshot, fmt.Errorf("catalog was exported from namespace %s, does not match %s", snapshot.Namespace, namespace)
+	}
+	namespace = snapshot.Namespace
+
+	seen := make(map[string]bool, len(snapshot.Instances))
+	for i := range snapshot.Instances {
+		inst := &snapshot.Instances[i]
+		inst.Namespace = normalizeNamespace(inst.Namespace)
+		s := inst.service()
+		if err := s.Validate(); err != nil {
+			return snapshot, fmt.Errorf("instance %d (%s): %v", i, inst.ServiceId, err)
+		}
+		if !namespacePattern.MatchString(inst.Namespace) {
+			return snapshot, fmt.Errorf("instance %d (%s): invalid namespace %s", i, inst.ServiceId, inst.Namespace)
+		}
+		if namespace != "" && inst.Namespace != namespace {
+			return snapshot, fmt.Errorf("instance %d (%s): namespace %s does not match %s", i, inst.ServiceId, inst.Namespace, namespace)
+		}
+		if inst.Status != "" && !model.ValidStatus(inst.Status) {
+			return snapshot, fmt.Errorf("instance %d (%s): invalid status %s", i, inst.ServiceId, inst.Status)
+		}
+		if inst.LeaseToken != "" && len(inst.LeaseToken) < minLeaseTokenLen {
+			return snapshot, fmt.Errorf("instance %d (%s): lease token must be at least %d characters", i, inst.ServiceId, minLeaseTokenLen)
+		}
+		key := serviceKey(inst.Namespace, inst.ServiceId)
+		if seen[key] {
+			return snapshot, fmt.Errorf("instance %d: duplicate serviceId %s in namespace %s", i, inst.ServiceId, inst.Namespace)
+		}
+		seen[key] = true
+	}
+	for i := range snapshot.Maintenance {
+		record := &snapshot.Maintenance[i]
+		record.Namespace = normalizeNamespace(record.Namespace)
+		if record.ServiceName == "" {
+			return snapshot, fmt.Errorf("maintenance %d: serviceName is required", i)
+		}
+		if namespace != "" && record.Namespace != namespace {
+			return snapshot, fmt.Errorf("maintenance %d (%s): namespace %s does not match %s", i, record.ServiceName, record.Namespace, namespace)
+		}
+	}
+	return snapshot, nil
+}
+
+// importCatalog 计算并在 dryRun 为 false 时应用导入的变更
+// 导入不经过准入策略和配额检查；导入的实例以导入时刻作为心跳时间，此后需要实例自己发送心跳
+// replace 模式的范围是快照的命名空间，为空时为整个目录
+// 已有的健康实例保留自己的租约，避免导入使正在运行的实例无法续约或注销；overwriteLeases 为 true 时使用快照中的租约
+func (r *Register) importCatalog(c *gin.Context, snapshot CatalogSnapshot, mode string, dryRun, overwriteLeases bool) model.ImportResult {
+	namespace := snapshot.Namespace
+	result := model.ImportResult{
+		Mode:               mode,
+		DryRun:             dryRun,
+		Added:              []model.InstanceChange{},
+		Updated:            []model.InstanceChange{},
+		Removed:            []model.InstanceChange{},
+		MaintenanceStarted: []string{},
+		MaintenanceEnded:   []string{},
+		Rejected:           []model.ImportRejection{},
+	}
+
+	r.quotaMu.Lock()
+	defer r.quotaMu.Unlock()
+	now := time.Now()
+	source := auditSource(c)
+
+	imported := make(map[string]bool, len(snapshot.Instances))
+	pending := 0 // dryRun 时尚未写入的新实例数，计入配额
+	for _, inst := range snapshot.Instances {
+		service := inst.service()
+		imported[serviceKey(service.Namespace, service.ServiceId)] = true
+		stored, exists := r.LoadService(service.Namespace, service.ServiceId)
+		if exists {
+			if service.LeaseToken == "" || (!overwriteLeases && r.isHealthy(stored, now)) {
+				// 快照中没有租约或实例仍在发送心跳时保留已有实例的租约
+				service.LeaseToken = stored.LeaseToken
+			}
+			fields := changedFields(stored, service)
+			if len(fields) == 0 {
+				result.Unchanged++
+				continue
+			}
+			result.Updated = append(result.Updated, instanceChange(service, fields))
+		} else {
+			// 新实例与对等节点同步一样经过准入策略和配额检查，否则同步到对等节点时会被拒绝，各节点目录不一致
+			if _, rejection := r.admit(service, true); rejection != nil {
+				result.Rejected = append(result.Rejected, importRejection(service, rejection.reason, rejection.message))
+				if !dryRun {
+					r.auditRequest(c, AuditAdmission, service, rejection.reason+": "+rejection.message)
+				}
+				continue
+			}
+			if quota, full := r.quotaFull(service.Namespace, pending); full {
+				result.Rejected = append(result.Rejected, importRejection(service, ReasonNamespaceQuota,
+					fmt.Sprintf("Namespace %s has reached its quota of %d instances", service.Namespace, quota)))
+				continue
+			}
+			change := instanceChange(service, nil)
+			if dryRun {
+				pending++
+			} else if service.LeaseToken == "" {
+				// 没有租约的实例任何持有服务令牌的调用方都能操作，导入时生成租约并在结果中返回
+				service.LeaseToken = util.GenerateToken()
+				change.LeaseToken = service.LeaseToken
+			}
+			result.Added = append(result.Added, change)
+		}
+		if dryRun {
+			continue
+		}
+		service.LastHeartbeat = now
+		r.StoreService(service)
+		r.auditRequest(c, AuditRegister, service, reasonImported)
+		if exists && statusOf(stored) != statusOf(service) {
+			r.publishInstance(EventStatus, source, service, statusOf(stored), reasonImported)
+		} else {
+			r.publishInstance(EventRegister, source, service, "", reasonImported)
+		}
+		r.syncToPeers(c.Request.Context(), service, "register")
+	}
+
+	if mode == ImportReplace {
+		for _, stored := range r.GetAllServices(namespace) {
+			if imported[serviceKey(stored.Namespace, stored.ServiceId)] {
+				continue
+			}
+			result.Removed = append(result.Removed, instanceChange(stored, nil))
+			if dryRun {
+				continue
+			}
+			r.DeleteService(stored.Namespace, stored.ServiceId)
+			r.auditRequest(c, AuditUnregister, stored, reasonImported)
+			r.publishInstance(EventUnregister, source, stored, "", reasonImported)
+			r.pushToPeers(c.Request.Context(), SyncRequest{Service: stored, Action: "unregister", Reason: reasonImported},
+				stored.ServiceName+"-"+stored.ServiceId)
+		}
+	}
+
+	maintained := make(map[string]bool, len(snapshot.Maintenance))
+	for _, m := range snapshot.Maintenance {
+		record := MaintenanceRecord(m)
+		key := record.Namespace + "/" + record.ServiceName
+		maintained[key] = true
+		if r.maintenance.active(record.Namespace, record.ServiceName) {
+			continue
+		}
+		if dryRun {
+			result.MaintenanceStarted = append(result.MaintenanceStarted, key)
+			continue
+		}
+		if record.Since.IsZero() {
+			record.Since = now.UTC()
+		}
+		if err := r.maintenance.set(record); err != nil {
+			logrus.WithContext(c.Request.Context()).Errorf("Failed to persist imported maintenance of %s: %v", key, err)
+			continue
+		}
+		result.MaintenanceStarted = append(result.MaintenanceStarted, key)
+		r.auditRequest(c, AuditMaintenanceOn, model.Service{Namespace: record.Namespace, ServiceName: record.ServiceName}, reasonImported)
+		r.pushToPeers(c.Request.Context(), SyncRequest{Action: "maintenance-on", Maintenance: &record}, "maintenance of "+record.ServiceName)
+	}
+	if mode == ImportReplace {
+		for _, record := range r.maintenance.list(namespace) {
+			key := record.Namespace + "/" + record.ServiceName
+			if maintained[key] {
+				continue
+			}
+			if dryRun {
+				result.MaintenanceEnded = append(result.MaintenanceEnded, key)
+				continue
+			}
+			if _, err := r.maintenance.clear(record.Namespace, record.ServiceName); err != nil {
+				logrus.WithContext(c.Request.Context()).Errorf("Failed to persist imported maintenance of %s: %v", key, err)
+				continue
+			}
+			result.MaintenanceEnded = append(result.MaintenanceEnded, key)
+			r.auditRequest(c, AuditMaintenanceOff, model.Service{Namespace: record.Namespace, ServiceName: record.ServiceName}, reasonImported)
+			r.pushToPeers(c.Request.Context(), SyncRequest{Action: "maintenance-off", Maintenance: &record}, "maintenance of "+record.ServiceName)
+		}
+	}
+	return result
+}
+
+// ImportHandler 导入目录
+// 参数：mode（merge 或 replace，默认 merge）、dryRun（true 时只返回将要发生的变更）、
+// namespace（限定导入和 replace 的范围，为空时使用快照导出时的命名空间）、
+// overwriteLeases（true 时健康实例的租约也使用快照中的租约）、format（json 或 yaml，也可以通过 Content-Type 请求头指定）
+func (r *Register) ImportHandler(c *gin.Context) {
+	format, ok := catalogFormat(c, "Content-Type")
+	if !ok {
+		return
+	}
+	mode := c.DefaultQuery("mode", ImportMerge)
+	if mode != ImportMerge && mode != ImportReplace {
+		badRequest(c, "Invalid mode parameter, must be merge or replace: "+mode)
+		return
+	}
+	namespace := c.Query("namespace")
+	if namespace != "" && !namespacePattern.MatchString(namespace) {
+		badRequest(c, "Invalid namespace parameter: "+namespace)
+		return
+	}
+	dryRun := c.Query("dryRun") == "true"
+	overwriteLeases := c.Query("overwriteLeases") == "true"
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		badRequest(c, "Failed to read request body: "+err.Error())
+		return
+	}
+	snapshot, err := decodeCatalog(data, format, namespace)
+	if err != nil {
+		badRequest(c, "Invalid catalog: "+err.Error())
+		return
+	}
+
+	result := r.importCatalog(c, snapshot, mode, dryRun, overwriteLeases)
+	if !dryRun {
+		r.auditRequest(c, AuditImport, model.Service{Namespace: snapshot.Namespace}, fmt.Sprintf("%s: %d added, %d updated, %d removed",
+			mode, len(result.Added), len(result.Updated), len(result.Removed)))
+		logrus.WithContext(c.Request.Context()).Infof("Imported catalog (%s): %d added, %d updated, %d removed, %d unchanged",
+			mode, len(result.Added), len(result.Updated), len(result.Removed), result.Unchanged)
+	}
+	c.JSON(http.StatusOK, result)
+}
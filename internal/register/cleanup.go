@@ -2,6 +2,7 @@ package register
 
 import (
 	"MicroService/pkg/model"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"time"
 )
@@ -18,21 +19,47 @@ func (r *Register) startCleanup() {
 	}
 }
 
-// cleanupExpiredServices 清理心跳超时的服务实例
-func (r *Register) cleanupExpiredServices() {
+// cleanupExpiredServices 清理心跳超时的服务实例，返回被清理的实例
+func (r *Register) cleanupExpiredServices() []model.Service {
 	now := time.Now()
-	var expired []string
+	var expired []model.Service
 
 	r.services.Range(func(key, value interface{}) bool {
 		service := value.(model.Service)
 		if now.Sub(service.LastHeartbeat) > r.heartbeatTTL {
-			expired = append(expired, key.(string))
+			expired = append(expired, service)
 		}
 		return true
 	})
 
-	for _, serviceId := range expired {
-		r.services.Delete(serviceId)
-		logrus.Infof("Removed expired service: %s", serviceId)
+	r.quotaMu.Lock()
+	removed := expired[:0]
+	for _, service := range expired {
+		// 遍历之后可能收到了心跳，在锁内重新检查
+		current, ok := r.LoadService(service.Namespace, service.ServiceId)
+		if !ok || now.Sub(current.LastHeartbeat) <= r.heartbeatTTL {
+			continue
+		}
+		r.DeleteService(service.Namespace, service.ServiceId)
+		removed = append(removed, current)
+	}
+	r.quotaMu.Unlock()
+	expired = removed
+
+	for _, service := range expired {
+		expiredInstancesTotal.Inc(service.ServiceName)
+		reason := fmt.Sprintf("no heartbeat for %v (ttl %v)", now.Sub(service.LastHeartbeat).Round(time.Second), r.heartbeatTTL)
+		r.audit.record(AuditEvent{
+			Node:        r.nodeID,
+			Source:      AuditSourceCleanup,
+			Action:      AuditExpire,
+			Namespace:   service.Namespace,
+			ServiceName: service.ServiceName,
+			ServiceId:   service.ServiceId,
+			Reason:      reason,
+		})
+		r.publishInstance(EventExpire, AuditSourceCleanup, service, "", reason)
+		logrus.Infof("Removed expired service: %s", service.ServiceId)
 	}
+	return expired
 }
@@ -0,0 +1,90 @@
+package register
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"MicroService/pkg/httpclient"
+	"MicroService/pkg/model"
+)
+
+// peerProbeTimeout 是查询对等节点状态的超时时间
+const peerProbeTimeout = 2 * time.Second
+
+// ClusterStatus 是本节点和所有对等节点的状态
+type ClusterStatus struct {
+	Node  model.NodeStatus   `json:"node"`
+	Peers []model.PeerStatus `json:"peers"`
+}
+
+// nodeStatus 统计本节点的状态
+func (r *Register) nodeStatus() model.NodeStatus {
+	now := time.Now()
+	status := model.NodeStatus{
+		Node:         r.nodeID,
+		StartedAt:    r.startedAt,
+		Peers:        append([]string{}, r.Peers...),
+		Maintenance:  len(r.maintenance.list("")),
+		Webhooks:     len(r.webhooks.list()),
+		FiringAlerts: len(r.slo.alerts("", AlertStateFiring)),
+	}
+	services := make(map[string]bool)
+	r.services.Range(func(_, value interface{}) bool {
+		s := value.(model.Service)
+		status.Instances++
+		if r.isHealthy(s, now) {
+			status.HealthyInstances++
+		}
+		services[s.Namespace+"/"+s.ServiceName] = true
+		return true
+	})
+	status.Services = len(services)
+	return status
+}
+
+// probePeers 并发查询所有对等节点的状态
+func (r *Register) probePeers(ctx context.Context) []model.PeerStatus {
+	config := httpclient.DefaultConfig()
+	config.Timeout = peerProbeTimeout
+	config.MaxRetries = 0
+	config.Breaker.Enabled = false
+	client := httpclient.NewClient(config)
+
+	peers := make([]model.PeerStatus, len(r.Peers))
+	var wg sync.WaitGroup
+	for i, peer := range r.Peers {
+		wg.Add(1)
+		go func(i int, peer string) {
+			defer wg.Done()
+			start := time.Now()
+			var status model.NodeStatus
+			err := client.GetJSON(ctx, peer+"/api/internal/status", &status, httpclient.WithBearerToken(r.peerToken))
+			peers[i] = model.PeerStatus{Address: peer, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				peers[i].Error = err.Error()
+				return
+			}
+			peers[i].Reachable = true
+			peers[i].Status = &status
+		}(i, peer)
+	}
+	wg.Wait()
+	return peers
+}
+
+// NodeStatusHandler 返回本节点的状态，供对等节点汇总集群状态
+func (r *Register) NodeStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, r.nodeStatus())
+}
+
+// ClusterHandler 返回本节点和所有对等节点的状态
+func (r *Register) ClusterHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, ClusterStatus{
+		Node:  r.nodeStatus(),
+		Peers: r.probePeers(c.Request.Context()),
+	})
+}
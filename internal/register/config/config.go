@@ -10,11 +10,46 @@ import (
 )
 
 // Config 定义注册中心的配置
+// 文件路径中的 {port} 由 ExpandPort 替换为监听端口，本机运行的多个节点各自使用不同的文件
 type Config struct {
 	Port          int
 	HeartbeatTTL  time.Duration
 	CleanupPeriod time.Duration
 	SyncAddresses []string
+	APITokens     []APIToken // 预共享的服务令牌
+	TokensFile    string     // 签发令牌文件，设置为空字符串时签发的令牌只保存在内存中
+	PeerToken     string     // 对等节点同步令牌
+	AdminToken    string     // 管理接口令牌
+	ACLFile       string     // ACL 策略文件
+
+	AuditFile       string // 审计日志文件，设置为空字符串时只输出到日志
+	AuditMaxSize    int64  // 审计日志轮转大小（字节）
+	AuditMaxBackups int    // 保留的审计日志轮转文件数
+
+	DefaultNamespaceQuota int            // 未单独配置的命名空间的实例数配额，0 表示不限制；不适用于默认命名空间
+	NamespaceQuotas       map[string]int // 按命名空间配置的实例数配额
+
+	AdmissionFile    string // 注册准入策略文件
+	DuplicateAddress string // 重复地址策略：allow、reject 或 replace，为空时使用准入策略文件中的配置
+
+	WebhookFile        string        // webhook 订阅文件，设置为空字符串时订阅只保存在内存中
+	WebhookMaxAttempts int           // 每次 webhook 投递的最大尝试次数
+	WebhookTimeout     time.Duration // 单次 webhook 请求超时
+
+	ThresholdsFile string        // 告警阈值文件
+	AlertInterval  time.Duration // 告警评估周期
+
+	HistoryMaxEvents int           // 事件历史保留的最大事件数
+	HistoryRetention time.Duration // 事件历史的最长保留时间
+
+	MaintenanceFile string // 维护状态文件，设置为空字符串时维护状态只保存在内存中
+}
+
+// APIToken 是预共享的服务令牌配置
+type APIToken struct {
+	Name     string
+	Secret   string
+	Services []string
 }
 
 // LoadConfig 从环境变量加载配置
@@ -24,6 +59,23 @@ func LoadConfig() Config {
 		HeartbeatTTL:  180 * time.Second,
 		CleanupPeriod: 60 * time.Second,
 		SyncAddresses: []string{},
+
+		TokensFile: "registry-tokens-{port}.json",
+
+		AuditFile:       "registry-audit-{port}.jsonl", // 本机运行的多个节点各自写不同的文件
+		AuditMaxSize:    10 * 1024 * 1024,
+		AuditMaxBackups: 5,
+
+		WebhookFile:        "registry-webhooks-{port}.json", // 订阅只属于本节点
+		WebhookMaxAttempts: 5,
+		WebhookTimeout:     5 * time.Second,
+
+		AlertInterval: 10 * time.Second,
+
+		HistoryMaxEvents: 50000,
+		HistoryRetention: 72 * time.Hour,
+
+		MaintenanceFile: "registry-maintenance-{port}.json",
 	}
 
 	if portStr := os.Getenv("REGISTRY_PORT"); portStr != "" {
@@ -51,5 +103,128 @@ func LoadConfig() Config {
 	if syncStr := os.Getenv("SYNC_ADDRESSES"); syncStr != "" {
 		config.SyncAddresses = strings.Split(syncStr, ",")
 	}
+	// 格式：name:secret:service1|dev/service2|staging/*,name2:secret2:*
+	// 不带命名空间的服务名只适用于默认命名空间
+	if tokensStr := os.Getenv("REGISTRY_API_TOKENS"); tokensStr != "" {
+		for _, entry := range strings.Split(tokensStr, ",") {
+			parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+			if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+				logrus.Warnf("Invalid REGISTRY_API_TOKENS entry for token %q, ignoring", parts[0])
+				continue
+			}
+			config.APITokens = append(config.APITokens, APIToken{
+				Name:     parts[0],
+				Secret:   parts[1],
+				Services: strings.Split(parts[2], "|"),
+			})
+		}
+	}
+	if file, ok := os.LookupEnv("REGISTRY_TOKENS_FILE"); ok {
+		config.TokensFile = file
+	}
+	config.PeerToken = os.Getenv("REGISTRY_PEER_TOKEN")
+	config.AdminToken = os.Getenv("REGISTRY_ADMIN_TOKEN")
+	config.ACLFile = os.Getenv("REGISTRY_ACL_FILE")
+	config.AdmissionFile = os.Getenv("REGISTRY_ADMISSION_FILE")
+
+	if policy := os.Getenv("REGISTRY_DUPLICATE_ADDRESS"); policy != "" {
+		switch policy = strings.ToLower(policy); policy {
+		case "allow", "reject", "replace":
+			config.DuplicateAddress = policy
+		default:
+			logrus.Warnf("Invalid REGISTRY_DUPLICATE_ADDRESS: %s, using default: admission policy setting", policy)
+		}
+	}
+
+	if file, ok := os.LookupEnv("REGISTRY_AUDIT_FILE"); ok {
+		config.AuditFile = file
+	}
+	if sizeStr := os.Getenv("REGISTRY_AUDIT_MAX_SIZE_MB"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil && size > 0 {
+			config.AuditMaxSize = int64(size) * 1024 * 1024
+		} else {
+			logrus.Warnf("Invalid REGISTRY_AUDIT_MAX_SIZE_MB: %s, using default: %d", sizeStr, config.AuditMaxSize/(1024*1024))
+		}
+	}
+	if quotaStr := os.Getenv("REGISTRY_DEFAULT_NAMESPACE_QUOTA"); quotaStr != "" {
+		if quota, err := strconv.Atoi(quotaStr); err == nil && quota >= 0 {
+			config.DefaultNamespaceQuota = quota
+		} else {
+			logrus.Warnf("Invalid REGISTRY_DEFAULT_NAMESPACE_QUOTA: %s, using default: %d", quotaStr, config.DefaultNamespaceQuota)
+		}
+	}
+	// 格式：dev:100,staging:50
+	if quotasStr := os.Getenv("REGISTRY_NAMESPACE_QUOTAS"); quotasStr != "" {
+		config.NamespaceQuotas = make(map[string]int)
+		for _, entry := range strings.Split(quotasStr, ",") {
+			name, quotaStr, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			quota, err := strconv.Atoi(quotaStr)
+			if !ok || name == "" || err != nil || quota < 0 {
+				logrus.Warnf("Invalid REGISTRY_NAMESPACE_QUOTAS entry: %s, ignoring", entry)
+				continue
+			}
+			config.NamespaceQuotas[name] = quota
+		}
+	}
+	if backupsStr := os.Getenv("REGISTRY_AUDIT_MAX_BACKUPS"); backupsStr != "" {
+		if backups, err := strconv.Atoi(backupsStr); err == nil && backups >= 0 {
+			config.AuditMaxBackups = backups
+		} else {
+			logrus.Warnf("Invalid REGISTRY_AUDIT_MAX_BACKUPS: %s, using default: %d", backupsStr, config.AuditMaxBackups)
+		}
+	}
+	if file, ok := os.LookupEnv("REGISTRY_WEBHOOK_FILE"); ok {
+		config.WebhookFile = file
+	}
+	if attemptsStr := os.Getenv("REGISTRY_WEBHOOK_MAX_ATTEMPTS"); attemptsStr != "" {
+		if attempts, err := strconv.Atoi(attemptsStr); err == nil && attempts > 0 {
+			config.WebhookMaxAttempts = attempts
+		} else {
+			logrus.Warnf("Invalid REGISTRY_WEBHOOK_MAX_ATTEMPTS: %s, using default: %d", attemptsStr, config.WebhookMaxAttempts)
+		}
+	}
+	if timeoutStr := os.Getenv("REGISTRY_WEBHOOK_TIMEOUT_SECONDS"); timeoutStr != "" {
+		if timeout, err := strconv.Atoi(timeoutStr); err == nil && timeout > 0 {
+			config.WebhookTimeout = time.Duration(timeout) * time.Second
+		} else {
+			logrus.Warnf("Invalid REGISTRY_WEBHOOK_TIMEOUT_SECONDS: %s, using default: %v", timeoutStr, config.WebhookTimeout)
+		}
+	}
+	config.ThresholdsFile = os.Getenv("REGISTRY_THRESHOLDS_FILE")
+	if intervalStr := os.Getenv("REGISTRY_ALERT_INTERVAL_SECONDS"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			config.AlertInterval = time.Duration(interval) * time.Second
+		} else {
+			logrus.Warnf("Invalid REGISTRY_ALERT_INTERVAL_SECONDS: %s, using default: %v", intervalStr, config.AlertInterval)
+		}
+	}
+	if maxStr := os.Getenv("REGISTRY_HISTORY_MAX_EVENTS"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil && max > 0 {
+			config.HistoryMaxEvents = max
+		} else {
+			logrus.Warnf("Invalid REGISTRY_HISTORY_MAX_EVENTS: %s, using default: %d", maxStr, config.HistoryMaxEvents)
+		}
+	}
+	if hoursStr := os.Getenv("REGISTRY_HISTORY_RETENTION_HOURS"); hoursStr != "" {
+		if hours, err := strconv.Atoi(hoursStr); err == nil && hours > 0 {
+			config.HistoryRetention = time.Duration(hours) * time.Hour
+		} else {
+			logrus.Warnf("Invalid REGISTRY_HISTORY_RETENTION_HOURS: %s, using default: %v", hoursStr, config.HistoryRetention)
+		}
+	}
+	if file, ok := os.LookupEnv("REGISTRY_MAINTENANCE_FILE"); ok {
+		config.MaintenanceFile = file
+	}
 	return config
 }
+
+// ExpandPort 将所有文件路径中的 {port} 替换为监听端口，在命令行参数覆盖端口之后调用
+func (c *Config) ExpandPort() {
+	port := strconv.Itoa(c.Port)
+	for _, path := range []*string{
+		&c.TokensFile, &c.ACLFile, &c.AuditFile, &c.AdmissionFile,
+		&c.WebhookFile, &c.ThresholdsFile, &c.MaintenanceFile,
+	} {
+		*path = strings.ReplaceAll(*path, "{port}", port)
+	}
+}
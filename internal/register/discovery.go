@@ -1,7 +1,11 @@
 package register
 
 import (
+	"encoding/json"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -10,23 +14,62 @@ import (
 	"MicroService/pkg/model"
 )
 
-// GetAllServices 获取所有服务实例
-func (r *Register) GetAllServices() []model.Service {
+// GetAllServices 获取命名空间中的所有服务实例，namespace 为空时返回所有命名空间的实例
+func (r *Register) GetAllServices(namespace string) []model.Service {
 	var services []model.Service
 	r.services.Range(func(_, value interface{}) bool {
-		services = append(services, value.(model.Service))
+		s := value.(model.Service)
+		if namespace == "" || s.Namespace == namespace {
+			services = append(services, s)
+		}
 		return true
 	})
 	return services
 }
 
-// GetServiceByName 获取指定服务名的健康实例（轮询负载均衡）
-func (r *Register) GetServiceByName(name string) (model.Service, bool) {
+// GetServiceInstances 获取命名空间中指定服务名的所有实例，name 为空时返回命名空间中所有服务的实例
+// includeUnhealthy 为 false 时仅返回心跳未超时且服务不在维护中的实例
+func (r *Register) GetServiceInstances(namespace, name string, includeUnhealthy bool) []model.InstanceView {
+	var instances []model.InstanceView
+	now := time.Now()
+	r.services.Range(func(_, value interface{}) bool {
+		s := value.(model.Service)
+		if s.Namespace != namespace || (name != "" && s.ServiceName != name) {
+			return true
+		}
+		view := r.instanceView(s, now)
+		if (!view.Healthy || r.maintenance.active(s.Namespace, s.ServiceName)) && !includeUnhealthy {
+			return true
+		}
+		instances = append(instances, view)
+		return true
+	})
+	return instances
+}
+
+// statusOf 返回实例状态，空值视为 UP
+func statusOf(s model.Service) string {
+	if s.Status == "" {
+		return model.StatusUp
+	}
+	return s.Status
+}
+
+// schemeOf 返回实例的访问协议，空值视为 http
+func schemeOf(s model.Service) string {
+	if s.Scheme == "" {
+		return "http"
+	}
+	return s.Scheme
+}
+
+// GetServiceByName 获取命名空间中指定服务名的健康实例（轮询负载均衡，计数器按命名空间隔离）
+func (r *Register) GetServiceByName(namespace, name string) (model.Service, bool) {
 	var healthy []model.Service
 	now := time.Now()
 	r.services.Range(func(_, value interface{}) bool {
 		s := value.(model.Service)
-		if s.ServiceName == name && now.Sub(s.LastHeartbeat) <= r.heartbeatTTL {
+		if s.Namespace == namespace && s.ServiceName == name && r.isHealthy(s, now) {
 			healthy = append(healthy, s)
 		}
 		return true
@@ -37,17 +80,18 @@ func (r *Register) GetServiceByName(name string) (model.Service, bool) {
 	}
 
 	// 使用读锁检查计数器是否存在
+	key := namespace + "/" + name
 	r.roundRobinMu.RLock()
-	counter, ok := r.roundRobin[name]
+	counter, ok := r.roundRobin[key]
 	r.roundRobinMu.RUnlock()
 
 	if !ok {
 		// 仅在必要时使用写锁初始化
 		r.roundRobinMu.Lock()
-		if _, exists := r.roundRobin[name]; !exists {
-			r.roundRobin[name] = new(uint64)
+		if _, exists := r.roundRobin[key]; !exists {
+			r.roundRobin[key] = new(uint64)
 		}
-		counter = r.roundRobin[name]
+		counter = r.roundRobin[key]
 		r.roundRobinMu.Unlock()
 	}
 
@@ -59,23 +103,66 @@ func (r *Register) GetServiceByName(name string) (model.Service, bool) {
 // DiscoveryHandler 处理服务发现请求
 func (r *Register) DiscoveryHandler(c *gin.Context) {
 	name := c.Query("name")
+	namespace := namespaceOf(c)
+	if name != "" && !r.authorizeACL(c, ActionDiscover, name) {
+		return
+	}
+
+	// ?at= 查询历史目录，返回与 ?all=true 相同格式的实例列表
+	if atParam := c.Query("at"); atParam != "" {
+		at, err := parseTimeParam(atParam, time.Now())
+		if err != nil {
+			badRequest(c, "Invalid at parameter, "+err.Error())
+			return
+		}
+		instances, err := r.instancesAt(namespace, name, at, c.Query("includeUnhealthy") == "true")
+		if err != nil {
+			badRequest(c, "Invalid at parameter, "+err.Error())
+			return
+		}
+		r.listInstances(c, namespace, name, instances, &at)
+		return
+	}
+
+	if c.Query("all") == "true" {
+		r.listInstances(c, namespace, name, r.GetServiceInstances(namespace, name, c.Query("includeUnhealthy") == "true"), nil)
+		return
+	}
 
 	if name == "" {
-		// 返回所有服务实例
-		services := r.GetAllServices()
+		// 返回所有服务实例，只包含调用方有权发现且不在维护中的服务
+		identity := identityOf(c)
+		var services []model.Service
+		for _, s := range r.GetAllServices(namespace) {
+			if r.aclPermits(identity, ActionDiscover, namespace, s.ServiceName) && !r.maintenance.active(namespace, s.ServiceName) {
+				services = append(services, s)
+			}
+		}
 		c.JSON(http.StatusOK, model.DiscoveryListResponse{
 			Services: services,
 		})
 		return
 	}
 
+	if r.maintenance.active(namespace, name) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:   http.StatusNotFound,
+			Error:  "Service " + name + " is in maintenance",
+			Reason: ReasonMaintenance,
+		})
+		return
+	}
+
 	// 返回单个服务实例（轮询负载均衡）
-	if service, ok := r.GetServiceByName(name); ok {
+	if service, ok := r.GetServiceByName(namespace, name); ok {
 		c.JSON(http.StatusOK, model.DiscoveryResponse{
+			Namespace:   service.Namespace,
 			ServiceName: service.ServiceName,
 			ServiceId:   service.ServiceId,
 			IpAddress:   service.IpAddress,
 			Port:        service.Port,
+			Scheme:      service.Scheme,
+			Metadata:    service.Metadata,
 		})
 		return
 	}
@@ -86,3 +173,172 @@ func (r *Register) DiscoveryHandler(c *gin.Context) {
 		Error: "No healthy service instances found for " + name,
 	})
 }
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// instanceSortKeys 定义允许排序的字段及其比较函数
+var instanceSortKeys = map[string]func(a, b model.InstanceView) bool{
+	"serviceName":   func(a, b model.InstanceView) bool { return a.ServiceName < b.ServiceName },
+	"serviceId":     func(a, b model.InstanceView) bool { return a.ServiceId < b.ServiceId },
+	"ipAddress":     func(a, b model.InstanceView) bool { return a.IpAddress < b.IpAddress },
+	"port":          func(a, b model.InstanceView) bool { return a.Port < b.Port },
+	"status":        func(a, b model.InstanceView) bool { return a.Status < b.Status },
+	"lastHeartbeat": func(a, b model.InstanceView) bool { return a.LastHeartbeat.Before(b.LastHeartbeat) },
+}
+
+// instanceFields 定义允许投影的字段
+var instanceFields = map[string]bool{
+	"namespace":     true,
+	"serviceName":   true,
+	"serviceId":     true,
+	"ipAddress":     true,
+	"port":          true,
+	"scheme":        true,
+	"metadata":      true,
+	"status":        true,
+	"healthy":       true,
+	"lastHeartbeat": true,
+}
+
+// listInstances 处理 ?all=true 和 ?at= 的实例列表查询，at 不为 nil 时 instances 是历史目录中的实例
+// 支持参数：includeUnhealthy、page、pageSize、sort（字段名，前缀 "-" 表示降序）、fields（逗号分隔）
+func (r *Register) listInstances(c *gin.Context, namespace, name string, instances []model.InstanceView, at *time.Time) {
+	page, err := parsePositiveInt(c.Query("page"), 1)
+	if err != nil {
+		badRequest(c, "Invalid page parameter: "+c.Query("page"))
+		return
+	}
+	pageSize, err := parsePositiveInt(c.Query("pageSize"), defaultPageSize)
+	if err != nil || pageSize > maxPageSize {
+		badRequest(c, "Invalid pageSize parameter, must be between 1 and "+strconv.Itoa(maxPageSize))
+		return
+	}
+
+	// 默认按 serviceId 排序，保证分页结果稳定
+	sortParam := c.DefaultQuery("sort", "serviceId")
+	desc := strings.HasPrefix(sortParam, "-")
+	less, ok := instanceSortKeys[strings.TrimPrefix(sortParam, "-")]
+	if !ok {
+		badRequest(c, "Invalid sort parameter: "+sortParam)
+		return
+	}
+
+	var fields []string
+	if fieldsParam := c.Query("fields"); fieldsParam != "" {
+		for _, f := range strings.Split(fieldsParam, ",") {
+			f = strings.TrimSpace(f)
+			if !instanceFields[f] {
+				badRequest(c, "Invalid fields parameter: "+f)
+				return
+			}
+			fields = append(fields, f)
+		}
+	}
+
+	if name == "" {
+		// 列出全部服务时过滤掉调用方无权发现的服务
+		identity := identityOf(c)
+		visible := instances[:0]
+		for _, inst := range instances {
+			if r.aclPermits(identity, ActionDiscover, namespace, inst.ServiceName) {
+				visible = append(visible, inst)
+			}
+		}
+		instances = visible
+	}
+	if name != "" && len(instances) == 0 {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "No service instances found for " + name,
+		})
+		return
+	}
+
+	// 排序字段相同时按 serviceId 升序，保证分页结果稳定
+	sort.SliceStable(instances, func(i, j int) bool {
+		a, b := instances[i], instances[j]
+		if desc {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return instances[i].ServiceId < instances[j].ServiceId
+	})
+
+	// 先比较页号再计算偏移量，很大的 page 不会溢出
+	total := len(instances)
+	start := total
+	if page-1 <= total/pageSize {
+		start = min((page-1)*pageSize, total)
+	}
+	end := min(start+pageSize, total)
+
+	projected := make([]map[string]interface{}, 0, end-start)
+	for _, inst := range instances[start:end] {
+		m, err := projectInstance(inst, fields)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Code:  http.StatusInternalServerError,
+				Error: "Failed to build response: " + err.Error(),
+			})
+			return
+		}
+		projected = append(projected, m)
+	}
+
+	c.JSON(http.StatusOK, model.DiscoveryInstancesResponse{
+		ServiceName: name,
+		Total:       total,
+		Page:        page,
+		PageSize:    pageSize,
+		Instances:   projected,
+		At:          at,
+	})
+}
+
+// projectInstance 将实例转换为只包含指定字段的 map，fields 为空时保留全部字段
+func projectInstance(inst model.InstanceView, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(inst)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return all, nil
+	}
+	projected := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		projected[f] = all[f]
+	}
+	return projected, nil
+}
+
+// parsePositiveInt 解析正整数查询参数，为空时返回默认值
+func parsePositiveInt(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return n, nil
+}
+
+// badRequest 返回请求参数错误响应
+func badRequest(c *gin.Context, msg string) {
+	c.JSON(http.StatusBadRequest, model.ErrorResponse{
+		Code:  http.StatusBadRequest,
+		Error: msg,
+	})
+}
@@ -0,0 +1,165 @@
+package register
+
+import (
+	"sync"
+	"time"
+
+	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+)
+
+// 目录事件类型：实例变更以及由此引起的服务健康状态变化
+const (
+	EventRegister    = "register"
+	EventUnregister  = "unregister"
+	EventExpire      = "expire"
+	EventStatus      = "status"       // 实例状态变化（UP、DOWN、OUT_OF_SERVICE）
+	EventServiceDown = "service-down" // 服务失去最后一个健康实例
+	EventServiceUp   = "service-up"   // 服务从没有健康实例恢复为至少一个
+)
+
+// CatalogEvent 是一次目录变更
+type CatalogEvent struct {
+	ID               string              `json:"id"`
+	Type             string              `json:"type"`
+	Time             time.Time           `json:"time"`
+	Node             string              `json:"node"`   // 产生事件的注册中心节点
+	Source           string              `json:"source"` // 与审计来源相同：api、sync、cleanup、admin，告警事件为 monitor
+	Namespace        string              `json:"namespace"`
+	ServiceName      string              `json:"serviceName"`
+	ServiceId        string              `json:"serviceId,omitempty"`
+	Instance         *model.InstanceView `json:"instance,omitempty"` // 实例事件时的实例快照
+	PreviousStatus   string              `json:"previousStatus,omitempty"`
+	Reason           string              `json:"reason,omitempty"`
+	HealthyInstances int                 `json:"healthyInstances"` // 事件发生后服务的健康实例数
+}
+
+// eventBus 将目录事件分发给订阅方，并根据健康实例数的变化产生服务级事件
+type eventBus struct {
+	mu        sync.Mutex
+	listeners []func(CatalogEvent)
+	healthy   map[string]int // 命名空间/服务名到上一次事件时健康实例数的映射
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{healthy: make(map[string]int)}
+}
+
+// subscribe 添加事件订阅方，订阅方在发布事件的 goroutine 中持有事件总线的锁被调用，不能阻塞，也不能发布事件
+func (b *eventBus) subscribe(listener func(CatalogEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, listener)
+}
+
+// instanceView 返回实例在某一时刻的视图
+func (r *Register) instanceView(s model.Service, now time.Time) model.InstanceView {
+	return model.InstanceView{
+		Namespace:     s.Namespace,
+		ServiceName:   s.ServiceName,
+		ServiceId:     s.ServiceId,
+		IpAddress:     s.IpAddress,
+		Port:          s.Port,
+		Scheme:        schemeOf(s),
+		Metadata:      s.Metadata,
+		Status:        statusOf(s),
+		Healthy:       r.isHealthy(s, now),
+		LastHeartbeat: s.LastHeartbeat,
+	}
+}
+
+// healthyCount 统计命名空间中指定服务的健康实例数
+func (r *Register) healthyCount(namespace, name string, now time.Time) int {
+	n := 0
+	r.services.Range(func(_, value interface{}) bool {
+		s := value.(model.Service)
+		if s.Namespace == namespace && s.ServiceName == name && r.isHealthy(s, now) {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// publishInstance 在实例变更写入存储之后发布实例事件
+func (r *Register) publishInstance(eventType, source string, s model.Service, previousStatus, reason string) {
+	now := time.Now()
+	view := r.instanceView(s, now)
+	r.publish(CatalogEvent{
+		Type:           eventType,
+		Time:           now.UTC(),
+		Source:         source,
+		Namespace:      normalizeNamespace(s.Namespace),
+		ServiceName:    s.ServiceName,
+		ServiceId:      s.ServiceId,
+		Instance:       &view,
+		PreviousStatus: previousStatus,
+		Reason:         reason,
+	})
+}
+
+// complete 补全事件的 ID、节点和时间
+func (r *Register) complete(event *CatalogEvent, now time.Time) {
+	if event.Time.IsZero() {
+		event.Time = now.UTC()
+	}
+	event.ID = util.GenerateUUID()
+	event.Node = r.nodeID
+}
+
+// publishAlert 补全并分发告警事件；告警不是目录变更，不更新服务级事件使用的健康实例数
+func (r *Register) publishAlert(event CatalogEvent) {
+	now := time.Now()
+	r.complete(&event, now)
+	b := r.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	event.HealthyInstances = r.healthyCount(event.Namespace, event.ServiceName, now)
+	b.dispatchLocked(event)
+}
+
+// publish 补全事件并分发；服务的健康实例数在 0 和非 0 之间变化时紧接着发布服务级事件
+func (r *Register) publish(event CatalogEvent) {
+	now := time.Now()
+	r.complete(&event, now)
+
+	// 计数、更新和分发在同一把锁内完成：并发变更不会错过或重复产生服务级事件，
+	// 订阅方按健康实例数变化的顺序收到事件
+	b := r.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	event.HealthyInstances = r.healthyCount(event.Namespace, event.ServiceName, now)
+	key := event.Namespace + "/" + event.ServiceName
+	previous := b.healthy[key]
+	b.healthy[key] = event.HealthyInstances
+	b.dispatchLocked(event)
+
+	var transition string
+	switch {
+	case previous > 0 && event.HealthyInstances == 0:
+		transition = EventServiceDown
+	case previous == 0 && event.HealthyInstances > 0:
+		transition = EventServiceUp
+	default:
+		return
+	}
+	serviceEvent := CatalogEvent{
+		ID:               util.GenerateUUID(),
+		Type:             transition,
+		Time:             event.Time,
+		Node:             event.Node,
+		Source:           event.Source,
+		Namespace:        event.Namespace,
+		ServiceName:      event.ServiceName,
+		Reason:           event.Type + " " + event.ServiceId,
+		HealthyInstances: event.HealthyInstances,
+	}
+	b.dispatchLocked(serviceEvent)
+}
+
+// dispatchLocked 依次调用订阅方，调用方需持有 mu
+func (b *eventBus) dispatchLocked(event CatalogEvent) {
+	for _, listener := range b.listeners {
+		listener(event)
+	}
+}
@@ -31,7 +31,7 @@ func (r *Register) HeartbeatHandler(c *gin.Context) {
 	}
 
 	// 检查服务是否存在
-	stored, ok := r.LoadService(req.ServiceId)
+	stored, ok := r.LoadService(namespaceOf(c), req.ServiceId)
 	if !ok {
 		c.JSON(http.StatusNotFound, model.ErrorResponse{
 			Code:  http.StatusNotFound,
@@ -40,6 +40,10 @@ func (r *Register) HeartbeatHandler(c *gin.Context) {
 		return
 	}
 
+	if !r.authorizeService(c, ActionHeartbeat, stored.ServiceName) || !r.checkLease(c, stored, req.LeaseToken) {
+		return
+	}
+
 	// 验证 IP 和端口
 	if stored.IpAddress != req.IpAddress || stored.Port != req.Port {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse{
@@ -49,9 +53,21 @@ func (r *Register) HeartbeatHandler(c *gin.Context) {
 		return
 	}
 
-	// 更新心跳时间
-	stored.LastHeartbeat = time.Now()
-	r.StoreService(stored)
+	// 更新心跳时间；校验之后实例被注销或以新租约重新注册时不再写入
+	_, ok = r.updateService(stored.Namespace, stored.ServiceId, func(s *model.Service) bool {
+		if s.LeaseToken != stored.LeaseToken {
+			return false
+		}
+		s.LastHeartbeat = time.Now()
+		return true
+	})
+	if !ok {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "Service not found",
+		})
+		return
+	}
 
 	// 返回成功响应
 	c.JSON(http.StatusOK, model.HeartbeatResponse{
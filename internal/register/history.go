@@ -0,0 +1,242 @@
+package register
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"MicroService/pkg/model"
+)
+
+// HistoryConfig 定义事件历史的容量，超出任一限制的最旧事件被合并到基线目录中
+type HistoryConfig struct {
+	MaxEvents int           // 保留的最大事件数
+	Retention time.Duration // 事件的最长保留时间
+}
+
+// eventHistory 保存有界的目录事件历史，用于重建任意时刻的目录和查询服务的时间线
+// base 是 baseTime 时刻的目录，将 events 中不晚于某一时刻的实例事件依次应用到 base 上即得到该时刻的目录
+// 心跳不产生事件，历史目录中的实例在注销或心跳超时被清理之前一直存在；健康状态取自最近一次实例事件，
+// 能够确定某一时刻的最后心跳时再按心跳超时重新计算（见 catalogAt）
+type eventHistory struct {
+	mu       sync.RWMutex
+	config   HistoryConfig
+	events   []CatalogEvent // 按发布顺序排列
+	base     map[string]model.InstanceView
+	baseTime time.Time
+}
+
+// newEventHistory 创建事件历史，注册中心启动时目录为空，历史从启动时刻开始
+func newEventHistory(config HistoryConfig) *eventHistory {
+	return &eventHistory{
+		config:   config,
+		base:     make(map[string]model.InstanceView),
+		baseTime: time.Now().UTC(),
+	}
+}
+
+// applyEvent 将实例事件应用到目录上，服务级事件和告警事件不改变目录
+func applyEvent(catalog map[string]model.InstanceView, event CatalogEvent) {
+	key := serviceKey(event.Namespace, event.ServiceId)
+	switch event.Type {
+	case EventRegister, EventStatus:
+		if event.Instance != nil {
+			catalog[key] = *event.Instance
+		}
+	case EventUnregister, EventExpire:
+		delete(catalog, key)
+	}
+}
+
+// record 是事件订阅方，追加事件并丢弃超出容量的最旧事件
+func (h *eventHistory) record(event CatalogEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+
+	cutoff := time.Now().Add(-h.config.Retention)
+	drop := 0
+	for drop < len(h.events) {
+		overflow := h.config.MaxEvents > 0 && len(h.events)-drop > h.config.MaxEvents
+		expired := h.config.Retention > 0 && h.events[drop].Time.Before(cutoff)
+		if !overflow && !expired {
+			break
+		}
+		applyEvent(h.base, h.events[drop])
+		h.baseTime = h.events[drop].Time
+		drop++
+	}
+	if drop > 0 {
+		// 重新切片而不是复制剩余的事件：容量用完时 append 重新分配底层数组，丢弃事件的摊还开销为 O(1)
+		// 清空被丢弃的元素，使其中的实例快照在重新分配之前也可以被回收
+		clear(h.events[:drop])
+		h.events = h.events[drop:]
+	}
+}
+
+// start 返回可以查询的最早时刻
+func (h *eventHistory) start() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.baseTime
+}
+
+// catalogAt 重建 at 时刻的目录，at 早于保留的历史时返回错误
+// 心跳不产生事件，实例视图中的心跳时间和健康状态来自实例事件，可能已经过时。
+// at 之后实例的第一个事件（或 current 返回的当前实例）中的最后心跳不晚于 at 时，它就是 at 时刻的最后心跳，
+// 据此按 ttl 重新计算健康状态，使心跳已经超时但尚未被清理的实例显示为不健康
+func (h *eventHistory) catalogAt(at time.Time, ttl time.Duration, current func(namespace, serviceId string) (time.Time, bool)) (map[string]model.InstanceView, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if at.Before(h.baseTime) {
+		return nil, fmt.Errorf("history is only available from %s", h.baseTime.Format(time.RFC3339))
+	}
+	catalog := make(map[string]model.InstanceView, len(h.base))
+	for key, inst := range h.base {
+		catalog[key] = inst
+	}
+	later := make(map[string]time.Time)
+	for _, event := range h.events {
+		key := serviceKey(event.Namespace, event.ServiceId)
+		if !event.Time.After(at) {
+			applyEvent(catalog, event)
+			continue
+		}
+		if _, seen := later[key]; !seen && event.Instance != nil {
+			later[key] = event.Instance.LastHeartbeat
+		}
+	}
+
+	for key, inst := range catalog {
+		heartbeat, ok := later[key]
+		if !ok {
+			heartbeat, ok = current(inst.Namespace, inst.ServiceId)
+		}
+		if !ok || heartbeat.After(at) {
+			continue
+		}
+		inst.LastHeartbeat = heartbeat
+		inst.Healthy = inst.Healthy && at.Sub(heartbeat) <= ttl
+		catalog[key] = inst
+	}
+	return catalog, nil
+}
+
+// TimelineQuery 是服务时间线的查询条件，零值字段不过滤
+type TimelineQuery struct {
+	Namespace string
+	Service   string
+	Since     time.Time
+	Until     time.Time
+	Types     []string
+	Limit     int
+}
+
+// timeline 返回服务的事件，按时间顺序排列，超出 Limit 时保留最近的事件
+func (h *eventHistory) timeline(q TimelineQuery) []CatalogEvent {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	events := []CatalogEvent{}
+	for _, event := range h.events {
+		if event.Namespace != q.Namespace || event.ServiceName != q.Service {
+			continue
+		}
+		if (!q.Since.IsZero() && event.Time.Before(q.Since)) || (!q.Until.IsZero() && event.Time.After(q.Until)) {
+			continue
+		}
+		if len(q.Types) > 0 && !matchAny(q.Types, event.Type) {
+			continue
+		}
+		events = append(events, event)
+	}
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[len(events)-q.Limit:]
+	}
+	return events
+}
+
+// parseTimeParam 解析 RFC3339 时间或相对时长（例如 1h 表示一小时前）
+func parseTimeParam(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("must be RFC3339 time or duration: %s", value)
+}
+
+// instancesAt 返回 at 时刻命名空间中的实例，name 为空时返回所有服务的实例
+func (r *Register) instancesAt(namespace, name string, at time.Time, includeUnhealthy bool) ([]model.InstanceView, error) {
+	catalog, err := r.history.catalogAt(at, r.heartbeatTTL, func(namespace, serviceId string) (time.Time, bool) {
+		s, ok := r.LoadService(namespace, serviceId)
+		return s.LastHeartbeat, ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	var instances []model.InstanceView
+	for _, inst := range catalog {
+		if inst.Namespace != namespace || (name != "" && inst.ServiceName != name) {
+			continue
+		}
+		if !inst.Healthy && !includeUnhealthy {
+			continue
+		}
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
+
+const (
+	defaultTimelineLimit = 1000
+	maxTimelineLimit     = 10000
+)
+
+// TimelineHandler 返回服务的事件时间线
+// 参数：name（必填）、since、until（RFC3339 时间或相对时长）、type（逗号分隔的事件类型）、limit
+func (r *Register) TimelineHandler(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		badRequest(c, "Missing name parameter")
+		return
+	}
+	if !r.authorizeACL(c, ActionDiscover, name) {
+		return
+	}
+
+	now := time.Now()
+	q := TimelineQuery{Namespace: namespaceOf(c), Service: name}
+	for param, target := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if value := c.Query(param); value != "" {
+			t, err := parseTimeParam(value, now)
+			if err != nil {
+				badRequest(c, "Invalid "+param+" parameter, "+err.Error())
+				return
+			}
+			*target = t
+		}
+	}
+	if types := c.Query("type"); types != "" {
+		q.Types = strings.Split(types, ",")
+	}
+	limit, err := parsePositiveInt(c.Query("limit"), defaultTimelineLimit)
+	if err != nil || limit > maxTimelineLimit {
+		badRequest(c, fmt.Sprintf("Invalid limit parameter, must be between 1 and %d", maxTimelineLimit))
+		return
+	}
+	q.Limit = limit
+
+	events := r.history.timeline(q)
+	c.JSON(http.StatusOK, gin.H{
+		"namespace":    q.Namespace,
+		"serviceName":  name,
+		"historyStart": r.history.start(),
+		"events":       events,
+		"count":        len(events),
+	})
+}
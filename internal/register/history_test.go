@@ -0,0 +1,136 @@
+package register
+
+import (
+	"testing"
+	"time"
+
+	"MicroService/pkg/model"
+)
+
+// instanceEvent 构造带实例快照的实例事件
+func instanceEvent(eventType, id string, at, heartbeat time.Time, status string, healthy bool) CatalogEvent {
+	return CatalogEvent{
+		Type:        eventType,
+		Time:        at,
+		Namespace:   DefaultNamespace,
+		ServiceName: "order-service",
+		ServiceId:   id,
+		Instance: &model.InstanceView{
+			Namespace:     DefaultNamespace,
+			ServiceName:   "order-service",
+			ServiceId:     id,
+			Status:        status,
+			Healthy:       healthy,
+			LastHeartbeat: heartbeat,
+		},
+	}
+}
+
+func TestCatalogAt(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sec := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Second) }
+	const ttl = 30 * time.Second
+
+	h := &eventHistory{base: make(map[string]model.InstanceView), baseTime: t0}
+	h.events = []CatalogEvent{
+		instanceEvent(EventRegister, "b1", sec(5), sec(5), model.StatusUp, true),
+		instanceEvent(EventRegister, "a1", sec(10), sec(10), model.StatusUp, true),
+		instanceEvent(EventRegister, "a2", sec(20), sec(20), model.StatusUp, true),
+		// a2 在 20s 之后没有心跳，60s 注销
+		instanceEvent(EventUnregister, "a2", sec(60), sec(20), model.StatusUp, false),
+		// a1 最后一次心跳在 40s，100s 时状态变为 DOWN
+		instanceEvent(EventStatus, "a1", sec(100), sec(40), model.StatusDown, false),
+	}
+	// b1 仍然在线，最后心跳在 200s；a1、a2 已不在当前目录中
+	current := func(namespace, serviceId string) (time.Time, bool) {
+		if serviceId == "b1" {
+			return sec(200), true
+		}
+		return time.Time{}, false
+	}
+
+	type want struct {
+		healthy   bool
+		heartbeat time.Time
+	}
+	tests := []struct {
+		name string
+		at   time.Time
+		want map[string]want
+	}{
+		{"before later heartbeats", sec(15), map[string]want{
+			"a1": {true, sec(10)},
+			"b1": {true, sec(5)},
+		}},
+		{"heartbeat within ttl", sec(50), map[string]want{
+			"a1": {true, sec(40)},
+			"a2": {true, sec(20)},
+			"b1": {true, sec(5)},
+		}},
+		{"expired but not yet removed", sec(55), map[string]want{
+			"a1": {true, sec(40)},
+			"a2": {false, sec(20)},
+			"b1": {true, sec(5)},
+		}},
+		{"heartbeat from a later event is stale", sec(80), map[string]want{
+			"a1": {false, sec(40)},
+			"b1": {true, sec(5)},
+		}},
+		{"status change keeps event health", sec(150), map[string]want{
+			"a1": {false, sec(40)},
+			"b1": {true, sec(5)},
+		}},
+		{"live heartbeat is stale", sec(250), map[string]want{
+			"a1": {false, sec(40)},
+			"b1": {false, sec(200)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog, err := h.catalogAt(tt.at, ttl, current)
+			if err != nil {
+				t.Fatalf("catalogAt: %v", err)
+			}
+			if len(catalog) != len(tt.want) {
+				t.Fatalf("catalog has %d instances, want %d: %+v", len(catalog), len(tt.want), catalog)
+			}
+			for id, w := range tt.want {
+				inst, ok := catalog[serviceKey(DefaultNamespace, id)]
+				if !ok {
+					t.Fatalf("instance %s missing", id)
+				}
+				if inst.Healthy != w.healthy || !inst.LastHeartbeat.Equal(w.heartbeat) {
+					t.Errorf("%s: healthy=%v lastHeartbeat=%v, want healthy=%v lastHeartbeat=%v",
+						id, inst.Healthy, inst.LastHeartbeat, w.healthy, w.heartbeat)
+				}
+			}
+		})
+	}
+
+	if _, err := h.catalogAt(t0.Add(-time.Second), ttl, current); err == nil {
+		t.Error("catalogAt before the history start succeeded")
+	}
+}
+
+func TestEventHistoryTrim(t *testing.T) {
+	t0 := time.Now().Add(-time.Minute).UTC()
+	h := newEventHistory(HistoryConfig{MaxEvents: 2})
+	h.record(instanceEvent(EventRegister, "a1", t0, t0, model.StatusUp, true))
+	h.record(instanceEvent(EventRegister, "a2", t0.Add(time.Second), t0, model.StatusUp, true))
+	h.record(instanceEvent(EventUnregister, "a1", t0.Add(2*time.Second), t0, model.StatusUp, true))
+
+	// 最旧的事件合并到基线目录，历史从该事件的时刻开始
+	if len(h.events) != 2 || !h.start().Equal(t0) {
+		t.Fatalf("events=%d start=%v, want 2 events from %v", len(h.events), h.start(), t0)
+	}
+	if _, ok := h.base[serviceKey(DefaultNamespace, "a1")]; !ok {
+		t.Fatal("trimmed registration not applied to the base catalog")
+	}
+	catalog, err := h.catalogAt(t0.Add(2*time.Second), time.Hour, func(string, string) (time.Time, bool) { return time.Time{}, false })
+	if err != nil {
+		t.Fatalf("catalogAt: %v", err)
+	}
+	if _, ok := catalog[serviceKey(DefaultNamespace, "a1")]; ok || len(catalog) != 1 {
+		t.Fatalf("catalog = %+v, want only a2", catalog)
+	}
+}
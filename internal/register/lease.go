@@ -0,0 +1,95 @@
+package register
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/model"
+)
+
+// minLeaseTokenLen 是实例提议的租约令牌的最小长度，避免使用可猜测的短令牌
+const minLeaseTokenLen = 32
+
+// leaseMatches 判断请求携带的租约令牌是否与实例的租约一致
+// 来自旧版本对等节点、没有租约的实例不做校验
+func leaseMatches(stored model.Service, token string) bool {
+	if stored.LeaseToken == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(stored.LeaseToken), []byte(token)) == 1
+}
+
+// checkLease 校验租约令牌，不一致时写入 403 响应并返回 false
+func (r *Register) checkLease(c *gin.Context, stored model.Service, token string) bool {
+	if leaseMatches(stored, token) {
+		return true
+	}
+	logrus.WithContext(c.Request.Context()).Warnf("Rejected request for service %s-%s with invalid lease token from %s",
+		stored.ServiceName, stored.ServiceId, c.ClientIP())
+	c.JSON(http.StatusForbidden, model.ErrorResponse{
+		Code:  http.StatusForbidden,
+		Error: "Invalid lease token",
+	})
+	return false
+}
+
+// StatusHandler 处理实例状态变更请求（UP、DOWN、OUT_OF_SERVICE），非 UP 的实例不会出现在服务发现结果中
+func (r *Register) StatusHandler(c *gin.Context) {
+	var req model.StatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if !model.ValidStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "Invalid status: " + req.Status,
+		})
+		return
+	}
+
+	stored, ok := r.LoadService(namespaceOf(c), req.ServiceId)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "Service not found",
+		})
+		return
+	}
+	if !r.authorizeService(c, ActionStatus, stored.ServiceName) || !r.checkLease(c, stored, req.LeaseToken) {
+		return
+	}
+
+	// 在最新的实例记录上修改状态，避免并发心跳写回旧副本覆盖状态变更
+	var previous string
+	stored, ok = r.updateService(stored.Namespace, stored.ServiceId, func(s *model.Service) bool {
+		if s.LeaseToken != stored.LeaseToken {
+			return false
+		}
+		previous = statusOf(*s)
+		s.Status = req.Status
+		return true
+	})
+	if !ok {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "Service not found",
+		})
+		return
+	}
+	r.auditRequest(c, AuditStatus, stored, previous+" -> "+req.Status)
+	r.publishInstance(EventStatus, auditSource(c), stored, previous, "")
+	r.syncToPeers(c.Request.Context(), stored, "register")
+
+	logrus.WithContext(c.Request.Context()).Infof("Service %s-%s status changed to %s", stored.ServiceName, stored.ServiceId, req.Status)
+	c.JSON(http.StatusOK, model.RegisterServiceResponse{
+		Message: "Service status updated",
+		Service: stored,
+	})
+}
@@ -0,0 +1,316 @@
+package register
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+)
+
+// 管理员强制移除实例时写入审计记录和目录事件的原因
+const (
+	reasonEvicted       = "evicted by admin"
+	reasonManualCleanup = "expired (manual cleanup)"
+)
+
+// ReasonMaintenance 是服务发现请求维护中的服务时错误响应的原因码
+const ReasonMaintenance = "SERVICE_IN_MAINTENANCE"
+
+// MaintenanceRecord 描述处于维护状态的服务，维护中的服务不参与服务发现，实例仍然可以注册和发送心跳
+type MaintenanceRecord struct {
+	Namespace   string    `json:"namespace"`
+	ServiceName string    `json:"serviceName"`
+	Reason      string    `json:"reason,omitempty"`
+	Principal   string    `json:"principal,omitempty"` // 开启维护的管理员
+	Since       time.Time `json:"since"`
+}
+
+// maintenanceSet 保存处于维护状态的服务，键为 命名空间/服务名
+// 维护状态写入维护文件，重启后恢复，并通过同步请求传播到对等节点
+type maintenanceSet struct {
+	mu       sync.RWMutex
+	file     string // 维护文件，为空时只保存在内存中
+	services map[string]MaintenanceRecord
+}
+
+// newMaintenanceSet 从维护文件加载维护状态，文件不存在时没有服务处于维护状态
+func newMaintenanceSet(file string) *maintenanceSet {
+	m := &maintenanceSet{file: file, services: make(map[string]MaintenanceRecord)}
+	if file == "" {
+		return m
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return m
+	}
+	if err != nil {
+		logrus.Fatalf("Failed to read maintenance file %s: %v", file, err)
+	}
+	var records []MaintenanceRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		logrus.Fatalf("Failed to parse maintenance file %s: %v", file, err)
+	}
+	for _, record := range records {
+		record.Namespace = normalizeNamespace(record.Namespace)
+		if record.ServiceName == "" {
+			logrus.Fatalf("Invalid maintenance record in %s: serviceName is required", file)
+		}
+		m.services[record.Namespace+"/"+record.ServiceName] = record
+	}
+	logrus.Infof("Loaded %d services in maintenance from %s", len(records), file)
+	return m
+}
+
+// save 将维护状态写回维护文件，调用方需持有 mu
+func (m *maintenanceSet) save() error {
+	if m.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(m.listLocked(""), "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(m.file, data)
+}
+
+// set 将服务置于维护状态并持久化，持久化失败时撤销修改
+func (m *maintenanceSet) set(record MaintenanceRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := record.Namespace + "/" + record.ServiceName
+	previous, existed := m.services[key]
+	m.services[key] = record
+	if err := m.save(); err != nil {
+		if existed {
+			m.services[key] = previous
+		} else {
+			delete(m.services, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// clear 结束服务的维护状态并持久化，服务不在维护中时返回 false；持久化失败时撤销修改
+func (m *maintenanceSet) clear(namespace, name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := namespace + "/" + name
+	record, ok := m.services[key]
+	if !ok {
+		return false, nil
+	}
+	delete(m.services, key)
+	if err := m.save(); err != nil {
+		m.services[key] = record
+		return true, err
+	}
+	return true, nil
+}
+
+func (m *maintenanceSet) active(namespace, name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.services[namespace+"/"+name]
+	return ok
+}
+
+// list 返回命名空间中处于维护状态的服务，namespace 为空时返回所有命名空间
+func (m *maintenanceSet) list(namespace string) []MaintenanceRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.listLocked(namespace)
+}
+
+// listLocked 返回按命名空间和服务名排序的维护记录，调用方需持有 mu
+func (m *maintenanceSet) listLocked(namespace string) []MaintenanceRecord {
+	records := []MaintenanceRecord{}
+	for _, record := range m.services {
+		if namespace == "" || record.Namespace == namespace {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Namespace != records[j].Namespace {
+			return records[i].Namespace < records[j].Namespace
+		}
+		return records[i].ServiceName < records[j].ServiceName
+	})
+	return records
+}
+
+// adminNamespace 解析管理接口的 namespace 查询参数，为空时使用默认命名空间
+func adminNamespace(c *gin.Context) (string, bool) {
+	namespace := normalizeNamespace(c.Query("namespace"))
+	if !namespacePattern.MatchString(namespace) {
+		badRequest(c, "Invalid namespace parameter: "+namespace)
+		return "", false
+	}
+	return namespace, true
+}
+
+// evict 删除实例并同步到对等节点，对等节点以注销处理并记录相同的原因
+func (r *Register) evict(c *gin.Context, service model.Service, action, reason string) {
+	r.quotaMu.Lock()
+	r.DeleteService(service.Namespace, service.ServiceId)
+	r.quotaMu.Unlock()
+	r.auditRequest(c, action, service, reason)
+	r.publishInstance(EventUnregister, auditSource(c), service, "", reason)
+	r.pushToPeers(c.Request.Context(), SyncRequest{Service: service, Action: "unregister", Reason: reason},
+		service.ServiceName+"-"+service.ServiceId)
+	logrus.WithContext(c.Request.Context()).Infof("Evicted service instance %s-%s at %s:%d (%s)",
+		service.ServiceName, service.ServiceId, service.IpAddress, service.Port, reason)
+}
+
+// EvictInstanceHandler 按 serviceId 强制移除实例，不要求租约，用于清理已经不存在的实例
+// 参数：namespace（默认命名空间）
+func (r *Register) EvictInstanceHandler(c *gin.Context) {
+	namespace, ok := adminNamespace(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	service, ok := r.LoadService(namespace, id)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "Service instance not found: " + id,
+		})
+		return
+	}
+	r.evict(c, service, AuditEvict, reasonEvicted)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Service instance evicted", "evicted": []string{id}})
+}
+
+// EvictServiceHandler 强制移除服务的全部实例
+// 参数：namespace（默认命名空间）
+func (r *Register) EvictServiceHandler(c *gin.Context) {
+	namespace, ok := adminNamespace(c)
+	if !ok {
+		return
+	}
+	name := c.Param("name")
+	evicted := []string{}
+	for _, service := range r.GetAllServices(namespace) {
+		if service.ServiceName != name {
+			continue
+		}
+		r.evict(c, service, AuditEvict, reasonEvicted)
+		evicted = append(evicted, service.ServiceId)
+	}
+	if len(evicted) == 0 {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "No service instances found for " + name,
+		})
+		return
+	}
+	sort.Strings(evicted)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": fmt.Sprintf("Evicted %d instances of %s", len(evicted), name), "evicted": evicted})
+}
+
+// MaintenanceRequest 是开启服务维护的请求体
+type MaintenanceRequest struct {
+	Reason string `json:"reason"`
+}
+
+// StartMaintenanceHandler 将服务置于维护状态，服务不必已有实例
+func (r *Register) StartMaintenanceHandler(c *gin.Context) {
+	namespace, ok := adminNamespace(c)
+	if !ok {
+		return
+	}
+	var req MaintenanceRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Code:  http.StatusBadRequest,
+				Error: "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+	}
+	record := MaintenanceRecord{
+		Namespace:   namespace,
+		ServiceName: c.Param("name"),
+		Reason:      req.Reason,
+		Principal:   principalName(identityOf(c)),
+		Since:       time.Now().UTC(),
+	}
+	if err := r.maintenance.set(record); err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to persist maintenance of %s/%s: %v", namespace, record.ServiceName, err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to persist maintenance state",
+		})
+		return
+	}
+	r.auditRequest(c, AuditMaintenanceOn, model.Service{Namespace: namespace, ServiceName: record.ServiceName}, record.Reason)
+	r.pushToPeers(c.Request.Context(), SyncRequest{Action: "maintenance-on", Maintenance: &record}, "maintenance of "+record.ServiceName)
+
+	logrus.WithContext(c.Request.Context()).Infof("Service %s/%s entered maintenance: %s", namespace, record.ServiceName, record.Reason)
+	c.JSON(http.StatusOK, record)
+}
+
+// StopMaintenanceHandler 结束服务的维护状态
+func (r *Register) StopMaintenanceHandler(c *gin.Context) {
+	namespace, ok := adminNamespace(c)
+	if !ok {
+		return
+	}
+	name := c.Param("name")
+	found, err := r.maintenance.clear(namespace, name)
+	if err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to persist maintenance of %s/%s: %v", namespace, name, err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to persist maintenance state",
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "Service is not in maintenance: " + name,
+		})
+		return
+	}
+	record := MaintenanceRecord{Namespace: namespace, ServiceName: name}
+	r.auditRequest(c, AuditMaintenanceOff, model.Service{Namespace: namespace, ServiceName: name}, "")
+	r.pushToPeers(c.Request.Context(), SyncRequest{Action: "maintenance-off", Maintenance: &record}, "maintenance of "+name)
+
+	logrus.WithContext(c.Request.Context()).Infof("Service %s/%s left maintenance", namespace, name)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Service maintenance ended"})
+}
+
+// MaintenanceHandler 列出处于维护状态的服务
+// 参数：namespace（为空时返回所有命名空间）
+func (r *Register) MaintenanceHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"services": r.maintenance.list(c.Query("namespace"))})
+}
+
+// CleanupHandler 立即执行一次心跳超时清理，并将清理结果同步到对等节点
+func (r *Register) CleanupHandler(c *gin.Context) {
+	expired := r.cleanupExpiredServices()
+	removed := make([]string, 0, len(expired))
+	for _, service := range expired {
+		r.pushToPeers(c.Request.Context(), SyncRequest{Service: service, Action: "unregister", Reason: reasonManualCleanup},
+			service.ServiceName+"-"+service.ServiceId)
+		removed = append(removed, service.ServiceId)
+	}
+	sort.Strings(removed)
+	r.auditRequest(c, AuditCleanup, model.Service{}, fmt.Sprintf("removed %d expired instances", len(removed)))
+
+	logrus.WithContext(c.Request.Context()).Infof("Manual cleanup removed %d expired instances", len(removed))
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": fmt.Sprintf("Removed %d expired instances", len(removed)), "removed": removed})
+}
@@ -0,0 +1,45 @@
+package register
+
+import (
+	"time"
+
+	"MicroService/pkg/metrics"
+	"MicroService/pkg/model"
+)
+
+// 注册中心指标
+var (
+	expiredInstancesTotal = metrics.NewCounterVec("registry_expired_instances_total",
+		"Total number of service instances removed by heartbeat expiry, by service.", "service")
+	syncFailuresTotal = metrics.NewCounterVec("registry_sync_failures_total",
+		"Total number of failed incremental sync pushes, by peer.", "peer")
+)
+
+// registerMetrics 注册按服务统计实例数的指标，抓取时根据当前服务列表计算
+func (r *Register) registerMetrics() {
+	type key struct{ namespace, service string }
+	metrics.NewGaugeFunc("registry_instances",
+		"Current number of registered service instances, by namespace, service and health.",
+		[]string{"namespace", "service", "health"},
+		func(emit func(value float64, labelValues ...string)) {
+			healthy := make(map[key]int)
+			unhealthy := make(map[key]int)
+			now := time.Now()
+			r.services.Range(func(_, value interface{}) bool {
+				s := value.(model.Service)
+				k := key{s.Namespace, s.ServiceName}
+				if r.isHealthy(s, now) {
+					healthy[k]++
+				} else {
+					unhealthy[k]++
+				}
+				return true
+			})
+			for k, n := range healthy {
+				emit(float64(n), k.namespace, k.service, "healthy")
+			}
+			for k, n := range unhealthy {
+				emit(float64(n), k.namespace, k.service, "unhealthy")
+			}
+		})
+}
@@ -0,0 +1,163 @@
+package register
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"MicroService/pkg/model"
+)
+
+// DefaultNamespace 是未指定命名空间时使用的命名空间，与引入命名空间之前的行为一致
+const DefaultNamespace = "default"
+
+// NamespaceHeader 是指定命名空间的请求头，也可以使用 /ns/:namespace 路径前缀
+const NamespaceHeader = "X-Namespace"
+
+// namespaceKey 是解析后的命名空间在 gin 上下文中的键
+const namespaceKey = "namespace"
+
+// namespacePattern 限制命名空间为 DNS 标签格式
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// NamespaceConfig 定义命名空间的实例数配额，配额为 0 表示不限制
+type NamespaceConfig struct {
+	DefaultQuota int            // 未单独配置的命名空间的配额，不适用于默认命名空间
+	Quotas       map[string]int // 按命名空间配置的配额
+}
+
+// normalizeNamespace 将空命名空间视为默认命名空间（兼容旧版本对等节点同步的实例）
+func normalizeNamespace(namespace string) string {
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
+}
+
+// serviceKey 返回实例在存储中的键，同一 serviceId 在不同命名空间中互不影响
+func serviceKey(namespace, serviceId string) string {
+	return normalizeNamespace(namespace) + "/" + serviceId
+}
+
+// Namespace 返回解析命名空间的中间件：路径参数优先，其次是 X-Namespace 请求头，都没有时使用默认命名空间
+func (r *Register) Namespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		header := c.GetHeader(NamespaceHeader)
+		if namespace != "" && header != "" && namespace != header {
+			c.AbortWithStatusJSON(http.StatusBadRequest, model.ErrorResponse{
+				Code:  http.StatusBadRequest,
+				Error: fmt.Sprintf("Namespace in path (%s) does not match %s header (%s)", namespace, NamespaceHeader, header),
+			})
+			return
+		}
+		if namespace == "" {
+			namespace = header
+		}
+		namespace = normalizeNamespace(namespace)
+		if !namespacePattern.MatchString(namespace) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, model.ErrorResponse{
+				Code:  http.StatusBadRequest,
+				Error: "Invalid namespace: " + namespace,
+			})
+			return
+		}
+		c.Set(namespaceKey, namespace)
+		c.Next()
+	}
+}
+
+// namespaceOf 返回请求的命名空间
+func namespaceOf(c *gin.Context) string {
+	return normalizeNamespace(c.GetString(namespaceKey))
+}
+
+// quotaFor 返回命名空间的实例数配额，0 表示不限制
+// 默认命名空间承载引入命名空间之前的所有实例，只有单独配置时才限制
+func (r *Register) quotaFor(namespace string) int {
+	if quota, ok := r.namespaces.Quotas[namespace]; ok {
+		return quota
+	}
+	if namespace == DefaultNamespace {
+		return 0
+	}
+	return r.namespaces.DefaultQuota
+}
+
+// countInstances 统计命名空间中的实例数（包括不健康的实例）
+func (r *Register) countInstances(namespace string) int {
+	n := 0
+	r.services.Range(func(_, value interface{}) bool {
+		if value.(model.Service).Namespace == namespace {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// quotaFull 判断命名空间的实例数加上 delta 之后是否已达到配额，返回配额；调用方需持有 quotaMu
+func (r *Register) quotaFull(namespace string, delta int) (int, bool) {
+	quota := r.quotaFor(namespace)
+	return quota, quota > 0 && r.countInstances(namespace)+delta >= quota
+}
+
+// checkQuota 检查新实例是否超出命名空间配额，超出时写入 403 响应并返回 false
+// freed 是本次注册将取代的旧实例数；调用方需持有 quotaMu，避免并发注册越过配额
+func (r *Register) checkQuota(c *gin.Context, namespace string, freed int) bool {
+	quota, full := r.quotaFull(namespace, -freed)
+	if !full {
+		return true
+	}
+	c.JSON(http.StatusForbidden, model.ErrorResponse{
+		Code:   http.StatusForbidden,
+		Error:  fmt.Sprintf("Namespace %s has reached its quota of %d instances", namespace, quota),
+		Reason: ReasonNamespaceQuota,
+	})
+	return false
+}
+
+// NamespaceInfo 是命名空间的概况
+type NamespaceInfo struct {
+	Name      string `json:"name"`
+	Instances int    `json:"instances"`
+	Services  int    `json:"services"`
+	Quota     int    `json:"quota"` // 0 表示不限制
+}
+
+// NamespacesHandler 列出有实例或配置了配额的命名空间
+func (r *Register) NamespacesHandler(c *gin.Context) {
+	infos := make(map[string]*NamespaceInfo)
+	get := func(name string) *NamespaceInfo {
+		info, ok := infos[name]
+		if !ok {
+			info = &NamespaceInfo{Name: name, Quota: r.quotaFor(name)}
+			infos[name] = info
+		}
+		return info
+	}
+	for name := range r.namespaces.Quotas {
+		get(name)
+	}
+	names := make(map[string]map[string]bool)
+	r.services.Range(func(_, value interface{}) bool {
+		s := value.(model.Service)
+		get(s.Namespace).Instances++
+		if names[s.Namespace] == nil {
+			names[s.Namespace] = make(map[string]bool)
+		}
+		names[s.Namespace][s.ServiceName] = true
+		return true
+	})
+
+	list := make([]NamespaceInfo, 0, len(infos))
+	for name, info := range infos {
+		info.Services = len(names[name])
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	c.JSON(http.StatusOK, gin.H{"namespaces": list})
+}
@@ -2,7 +2,9 @@ package register
 
 import (
 	"MicroService/pkg/model"
+	"MicroService/pkg/util"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"net/http"
 	"sync"
 	"time"
@@ -11,17 +13,43 @@ import (
 // api/register
 // Config 定义注册中心的配置
 type Config struct {
-	HeartbeatTTL  time.Duration
-	CleanupPeriod time.Duration
+	HeartbeatTTL     time.Duration
+	CleanupPeriod    time.Duration
+	Auth             AuthConfig
+	ACLFile          string // ACL 策略文件（JSON），为空时不启用 ACL
+	Audit            AuditConfig
+	NodeID           string // 本节点标识，写入审计记录和同步请求
+	Namespaces       NamespaceConfig
+	AdmissionFile    string // 注册准入策略文件（JSON），为空时只做基本校验
+	DuplicateAddress string // 覆盖准入策略中的重复地址策略，为空时不覆盖
+	Webhooks         WebhookConfig
+	ThresholdsFile   string        // 告警阈值文件（JSON），为空时不告警，只统计可用率
+	AlertInterval    time.Duration // 告警评估和可用率采样周期
+	History          HistoryConfig
+	MaintenanceFile  string // 维护状态文件（JSON），为空时维护状态只保存在内存中
 }
 
 // Register 注册中心核心结构
 type Register struct {
-	services      sync.Map           // 存储服务实例，键为 serviceId，值为 model.Service
-	roundRobin    map[string]*uint64 // 服务名到轮询计数器的映射
+	services      sync.Map           // 存储服务实例，键为 命名空间/serviceId，值为 model.Service
+	roundRobin    map[string]*uint64 // 命名空间/服务名到轮询计数器的映射
 	roundRobinMu  sync.RWMutex       // 保护 roundRobin 映射
 	heartbeatTTL  time.Duration      // 心跳超时时间
 	cleanupPeriod time.Duration      // 清理周期
+	tokens        *tokenStore        // 写接口的认证令牌
+	acl           *aclEngine         // 访问控制策略
+	audit         *auditLog          // 变更审计日志
+	nodeID        string             // 本节点标识
+	namespaces    NamespaceConfig    // 命名空间配额
+	quotaMu       sync.Mutex         // 串行化实例的写入和删除，保证配额、准入检查和写入的原子性
+	admission     *AdmissionPolicy   // 注册准入策略
+	events        *eventBus          // 目录变更事件
+	webhooks      *webhookManager    // webhook 订阅和投递
+	slo           *sloTracker        // 告警阈值和可用率统计
+	history       *eventHistory      // 目录事件历史
+	maintenance   *maintenanceSet    // 处于维护状态、不参与服务发现的服务
+	peerToken     string             // 向对等节点同步时使用的令牌
+	startedAt     time.Time          // 节点启动时间
 
 	Peers []string
 }
@@ -35,28 +63,80 @@ func NewRegister(config Config, peers []string) *Register {
 		roundRobinMu:  sync.RWMutex{},
 		heartbeatTTL:  config.HeartbeatTTL,
 		cleanupPeriod: config.CleanupPeriod,
+		tokens:        newTokenStore(config.Auth),
+		acl:           newACLEngine(config.ACLFile),
+		audit:         newAuditLog(config.Audit),
+		nodeID:        config.NodeID,
+		namespaces:    config.Namespaces,
+		admission:     loadAdmissionPolicy(config.AdmissionFile, config.DuplicateAddress),
+		events:        newEventBus(),
+		webhooks:      newWebhookManager(config.Webhooks),
+		slo:           newSLOTracker(loadThresholdPolicy(config.ThresholdsFile), config.AlertInterval),
+		history:       newEventHistory(config.History),
+		maintenance:   newMaintenanceSet(config.MaintenanceFile),
+		peerToken:     config.Auth.PeerToken,
+		startedAt:     time.Now().UTC(),
 		Peers:         peers, // 将对等节点地址列表传递给结构体
 	}
+	r.events.subscribe(r.webhooks.notify)
+	r.events.subscribe(r.observeFlap)
+	r.events.subscribe(r.history.record)
+	r.registerMetrics()
+	r.registerSLOMetrics()
 	go r.startCleanup()
+	go r.startSLOEvaluation()
 	return r
 }
 
-// StoreService 存储服务实例
+// Close 停止 webhook 投递并关闭注册中心持有的文件
+func (r *Register) Close() error {
+	r.webhooks.close()
+	return r.audit.close()
+}
+
+// StoreService 存储服务实例，空命名空间视为默认命名空间
 func (r *Register) StoreService(service model.Service) {
-	r.services.Store(service.ServiceId, service)
+	service.Namespace = normalizeNamespace(service.Namespace)
+	r.services.Store(serviceKey(service.Namespace, service.ServiceId), service)
+}
+
+// LoadService 加载命名空间中的服务实例
+func (r *Register) LoadService(namespace, serviceId string) (model.Service, bool) {
+	if s, ok := r.services.Load(serviceKey(namespace, serviceId)); ok {
+		return s.(model.Service), true
+	}
+	return model.Service{}, false
 }
 
-// LoadService 加载服务实例
-func (r *Register) LoadService(serviceId string) (model.Service, bool) {
-	if s, ok := r.services.Load(serviceId); ok {
+// DeleteService 删除命名空间中的服务实例
+func (r *Register) DeleteService(namespace, serviceId string) {
+	r.services.Delete(serviceKey(namespace, serviceId))
+}
+
+// RemoveService 删除命名空间中的服务实例并返回被删除的记录，实例不存在时返回 false
+func (r *Register) RemoveService(namespace, serviceId string) (model.Service, bool) {
+	if s, ok := r.services.LoadAndDelete(serviceKey(namespace, serviceId)); ok {
 		return s.(model.Service), true
 	}
 	return model.Service{}, false
 }
 
-// DeleteService 删除服务实例
-func (r *Register) DeleteService(serviceId string) {
-	r.services.Delete(serviceId)
+// updateService 在 quotaMu 内重新加载实例并修改，实例已被删除或 update 返回 false 时不写入并返回 false
+// 修改与注册、注销和清理在同一把锁内串行：基于旧副本的写入不会覆盖并发的变更，也不会让刚删除的实例重新出现
+func (r *Register) updateService(namespace, serviceId string, update func(*model.Service) bool) (model.Service, bool) {
+	r.quotaMu.Lock()
+	defer r.quotaMu.Unlock()
+	service, ok := r.LoadService(namespace, serviceId)
+	if !ok || !update(&service) {
+		return model.Service{}, false
+	}
+	r.StoreService(service)
+	return service, true
+}
+
+// isHealthy 判断实例是否可以被发现：心跳未超时且状态为 UP
+func (r *Register) isHealthy(s model.Service, now time.Time) bool {
+	return now.Sub(s.LastHeartbeat) <= r.heartbeatTTL && s.IsUp()
 }
 
 // RegisterHandler 处理服务注册请求
@@ -72,6 +152,7 @@ func (r *Register) RegisterHandler(c *gin.Context) {
 
 	// 转换为 Service 并验证
 	service := req.ToService()
+	service.Namespace = namespaceOf(c)
 	if err := service.Validate(); err != nil {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse{
 			Code:  http.StatusBadRequest,
@@ -80,16 +161,75 @@ func (r *Register) RegisterHandler(c *gin.Context) {
 		return
 	}
 
+	if !r.authorizeService(c, ActionRegister, service.ServiceName) {
+		return
+	}
+
+	// 已存在的实例只有持有租约的一方可以重新注册，保留原有租约和状态
+	service.Status = model.StatusUp
+	reason := ""
+	r.quotaMu.Lock()
+	defer r.quotaMu.Unlock()
+	stored, exists := r.LoadService(service.Namespace, service.ServiceId)
+	if exists {
+		reason = "re-registered"
+	}
+	if exists && stored.LeaseToken != "" {
+		if !leaseMatches(stored, req.LeaseToken) {
+			c.JSON(http.StatusConflict, model.ErrorResponse{
+				Code:  http.StatusConflict,
+				Error: "Service ID is already registered with a different lease",
+			})
+			return
+		}
+		service.LeaseToken = stored.LeaseToken
+		service.Status = stored.Status
+	} else if len(req.LeaseToken) >= minLeaseTokenLen {
+		// 实例提议的租约，用于向多个注册中心注册同一个实例
+		service.LeaseToken = req.LeaseToken
+	} else {
+		service.LeaseToken = util.GenerateToken()
+	}
+
+	replaced, rejection := r.admit(service, false)
+	if rejection != nil {
+		logrus.WithContext(c.Request.Context()).Warnf("Rejected registration of %s-%s at %s:%d: %s",
+			service.ServiceName, service.ServiceId, service.IpAddress, service.Port, rejection.message)
+		r.auditRequest(c, AuditAdmission, service, rejection.reason+": "+rejection.message)
+		c.JSON(rejection.status, model.ErrorResponse{
+			Code:   rejection.status,
+			Error:  rejection.message,
+			Reason: rejection.reason,
+		})
+		return
+	}
+	if !exists && !r.checkQuota(c, service.Namespace, len(replaced)) {
+		return
+	}
+
+	// 同一地址上的旧实例（通常是崩溃后重启前的实例）由新实例取代，不必等到心跳超时
+	for _, old := range replaced {
+		r.DeleteService(old.Namespace, old.ServiceId)
+		r.auditRequest(c, AuditReplace, old, reasonReplacedBy+service.ServiceId)
+		r.publishInstance(EventUnregister, auditSource(c), old, "", reasonReplacedBy+service.ServiceId)
+		r.syncToPeers(c.Request.Context(), old, "unregister")
+		logrus.WithContext(c.Request.Context()).Infof("Replaced stale instance %s-%s at %s:%d with %s",
+			old.ServiceName, old.ServiceId, old.IpAddress, old.Port, service.ServiceId)
+	}
+
 	// 设置初始心跳时间
 	service.LastHeartbeat = time.Now()
 	r.StoreService(service)
+	r.auditRequest(c, AuditRegister, service, reason)
+	r.publishInstance(EventRegister, auditSource(c), service, "", reason)
 
 	// 新增：异步同步到其他对等节点
-	r.syncToPeers(service, "register")
+	r.syncToPeers(c.Request.Context(), service, "register")
 
 	// 返回成功响应
 	c.JSON(http.StatusOK, model.RegisterServiceResponse{
-		Message: "Service registered successfully",
-		Service: service,
+		Message:    "Service registered successfully",
+		Service:    service,
+		LeaseToken: service.LeaseToken,
 	})
 }
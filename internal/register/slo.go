@@ -0,0 +1,537 @@
+package register
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/metrics"
+	"MicroService/pkg/model"
+)
+
+// 告警类型
+const (
+	AlertMinHealthy = "min-healthy" // 健康实例数低于下限
+	AlertFlapping   = "flapping"    // 最近一小时的实例抖动次数超过上限
+)
+
+// 告警状态
+const (
+	AlertStateOK     = "ok"
+	AlertStateFiring = "firing"
+)
+
+// 告警状态变化时发布的目录事件，可通过 webhook 订阅
+const (
+	EventAlertFiring   = "alert-firing"
+	EventAlertResolved = "alert-resolved"
+)
+
+// EventSourceMonitor 是告警评估产生的事件来源
+const EventSourceMonitor = "monitor"
+
+// reasonReplacedBy 是被新实例取代的旧实例的注销原因前缀
+const reasonReplacedBy = "replaced by "
+
+// flapWindow 是计算抖动次数的时间窗口
+const flapWindow = time.Hour
+
+// availabilityWindows 是可用率的统计窗口，最长窗口决定保留的桶数
+var availabilityWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// 可用时长分两级分桶：不超过一小时的窗口按分钟统计，更长的窗口按小时统计，
+// 每次查询最多扫描几百个桶，指标抓取不会长时间持有 sloTracker 的锁
+const (
+	minuteBuckets = 60
+	hourBuckets   = 7 * 24
+)
+
+var serviceFlapsTotal = metrics.NewCounterVec("registry_service_flaps_total",
+	"Total number of instance flaps (unhealthy status, expiry or replacement), by namespace and service.", "namespace", "service")
+
+// AlertThreshold 是一个服务的告警阈值，零值字段不告警
+type AlertThreshold struct {
+	MinHealthy      int `json:"minHealthy,omitempty"`      // 健康实例数下限
+	MaxFlapsPerHour int `json:"maxFlapsPerHour,omitempty"` // 最近一小时的抖动次数上限
+}
+
+// ThresholdPolicy 定义服务的告警阈值
+// Services 的键为服务名或 "命名空间/服务名"，后者优先；按服务配置的阈值逐字段覆盖 Default
+type ThresholdPolicy struct {
+	Default  AlertThreshold            `json:"default"`
+	Services map[string]AlertThreshold `json:"services,omitempty"`
+}
+
+// Validate 校验阈值
+func (p *ThresholdPolicy) Validate() error {
+	check := func(name string, t AlertThreshold) error {
+		if t.MinHealthy < 0 || t.MaxFlapsPerHour < 0 {
+			return fmt.Errorf("%s: thresholds must not be negative", name)
+		}
+		return nil
+	}
+	if err := check("default", p.Default); err != nil {
+		return err
+	}
+	for name, t := range p.Services {
+		if err := check(name, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// thresholdFor 返回服务的有效阈值
+func (p *ThresholdPolicy) thresholdFor(namespace, name string) AlertThreshold {
+	t := p.Default
+	for _, key := range []string{name, namespace + "/" + name} {
+		override, ok := p.Services[key]
+		if !ok {
+			continue
+		}
+		if override.MinHealthy > 0 {
+			t.MinHealthy = override.MinHealthy
+		}
+		if override.MaxFlapsPerHour > 0 {
+			t.MaxFlapsPerHour = override.MaxFlapsPerHour
+		}
+	}
+	return t
+}
+
+// loadThresholdPolicy 从文件加载告警阈值，未配置文件时不告警，只统计可用率
+func loadThresholdPolicy(file string) *ThresholdPolicy {
+	policy := &ThresholdPolicy{}
+	if file == "" {
+		return policy
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		logrus.Fatalf("Failed to read thresholds file %s: %v", file, err)
+	}
+	if err := json.Unmarshal(data, policy); err != nil {
+		logrus.Fatalf("Failed to parse thresholds file %s: %v", file, err)
+	}
+	if err := policy.Validate(); err != nil {
+		logrus.Fatalf("Invalid thresholds file %s: %v", file, err)
+	}
+	logrus.Infof("Loaded alert thresholds from %s (%d service rules)", file, len(policy.Services))
+	return policy
+}
+
+// Alert 是一个服务的告警状态
+type Alert struct {
+	Namespace string    `json:"namespace"`
+	Service   string    `json:"service"`
+	Kind      string    `json:"kind"`
+	State     string    `json:"state"`
+	Value     int       `json:"value"`     // 当前的健康实例数或抖动次数
+	Threshold int       `json:"threshold"` // 配置的阈值
+	Message   string    `json:"message,omitempty"`
+	Since     time.Time `json:"since"` // 进入当前状态的时间
+}
+
+// availabilityBucket 是一分钟或一小时内的可用时长统计
+type availabilityBucket struct {
+	slot  int64   // 自 Unix 纪元起的分钟数或小时数
+	up    float64 // 有健康实例的秒数
+	total float64 // 观测的秒数
+}
+
+// addToBucket 将时长计入 slot 对应的桶，桶中是更早的数据时先清空
+func addToBucket(buckets []availabilityBucket, slot int64, seconds float64, available bool) {
+	b := &buckets[slot%int64(len(buckets))]
+	if b.slot != slot {
+		*b = availabilityBucket{slot: slot}
+	}
+	b.total += seconds
+	if available {
+		b.up += seconds
+	}
+}
+
+// sumBuckets 累计 (current-n, current] 范围内的桶
+func sumBuckets(buckets []availabilityBucket, current, n int64) (float64, float64) {
+	var up, total float64
+	for _, b := range buckets {
+		if b.slot > current-n && b.slot <= current {
+			up += b.up
+			total += b.total
+		}
+	}
+	return up, total
+}
+
+// serviceHealth 是一个服务的健康统计
+type serviceHealth struct {
+	namespace string
+	name      string
+	healthy   int
+	instances int
+	lastEval  time.Time
+	lastSeen  time.Time // 最后一次有实例的时间
+	flaps     []time.Time
+	minutes   []availabilityBucket
+	hours     []availabilityBucket
+	alerts    map[string]*Alert
+}
+
+// record 将上次评估以来的时长计入对应分钟和小时的桶
+func (h *serviceHealth) record(now time.Time, elapsed time.Duration, available bool) {
+	if elapsed <= 0 {
+		return
+	}
+	addToBucket(h.minutes, now.Unix()/60, elapsed.Seconds(), available)
+	addToBucket(h.hours, now.Unix()/3600, elapsed.Seconds(), available)
+}
+
+// availability 返回窗口内的可用率和观测时长，没有观测数据时可用率为 -1
+// 超过一小时的窗口按整小时统计，包含当前小时已经过去的部分
+func (h *serviceHealth) availability(now time.Time, window time.Duration) (float64, float64) {
+	var up, total float64
+	if window <= time.Hour {
+		up, total = sumBuckets(h.minutes, now.Unix()/60, int64(window/time.Minute))
+	} else {
+		up, total = sumBuckets(h.hours, now.Unix()/3600, int64(window/time.Hour))
+	}
+	if total == 0 {
+		return -1, 0
+	}
+	return up / total, total
+}
+
+// pruneFlaps 丢弃窗口之外的抖动记录
+func (h *serviceHealth) pruneFlaps(now time.Time) {
+	cutoff := now.Add(-flapWindow)
+	i := 0
+	for i < len(h.flaps) && h.flaps[i].Before(cutoff) {
+		i++
+	}
+	h.flaps = h.flaps[i:]
+}
+
+// sloTracker 持续评估告警阈值并统计服务可用率
+// 可用率以"至少有一个健康实例"为可用，数据来自注册中心自身的心跳状态
+type sloTracker struct {
+	mu       sync.Mutex
+	policy   *ThresholdPolicy
+	interval time.Duration
+	services map[string]*serviceHealth // 命名空间/服务名到统计的映射
+}
+
+func newSLOTracker(policy *ThresholdPolicy, interval time.Duration) *sloTracker {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &sloTracker{policy: policy, interval: interval, services: make(map[string]*serviceHealth)}
+}
+
+// getLocked 返回服务的统计，不存在时创建；调用方需持有 mu
+func (t *sloTracker) getLocked(namespace, name string) *serviceHealth {
+	key := namespace + "/" + name
+	h, ok := t.services[key]
+	if !ok {
+		h = &serviceHealth{
+			namespace: namespace,
+			name:      name,
+			minutes:   make([]availabilityBucket, minuteBuckets),
+			hours:     make([]availabilityBucket, hourBuckets),
+			alerts:    make(map[string]*Alert),
+		}
+		t.services[key] = h
+	}
+	return h
+}
+
+// isFlap 判断事件是否为一次抖动：实例从 UP 变为其他状态、心跳超时被清理或被同一地址的新实例取代
+// 正常注销不计入
+func isFlap(event CatalogEvent) bool {
+	switch event.Type {
+	case EventStatus:
+		return event.PreviousStatus == model.StatusUp && event.Instance != nil && event.Instance.Status != model.StatusUp
+	case EventExpire:
+		return true
+	case EventUnregister:
+		return strings.HasPrefix(event.Reason, reasonReplacedBy)
+	}
+	return false
+}
+
+// observeFlap 是事件订阅方，记录实例抖动
+func (r *Register) observeFlap(event CatalogEvent) {
+	if !isFlap(event) {
+		return
+	}
+	serviceFlapsTotal.Inc(event.Namespace, event.ServiceName)
+	t := r.slo
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.getLocked(event.Namespace, event.ServiceName)
+	h.flaps = append(h.flaps, event.Time)
+}
+
+// startSLOEvaluation 定时评估告警阈值并累计可用时长
+func (r *Register) startSLOEvaluation() {
+	ticker := time.NewTicker(r.slo.interval)
+	defer ticker.Stop()
+	r.evaluateSLO(time.Now())
+	for now := range ticker.C {
+		r.evaluateSLO(now)
+	}
+}
+
+// evaluateSLO 统计每个服务的健康实例数，更新可用率和告警状态，告警状态变化时发布事件
+func (r *Register) evaluateSLO(now time.Time) {
+	type counts struct{ healthy, instances int }
+	current := make(map[[2]string]*counts)
+	r.services.Range(func(_, value interface{}) bool {
+		s := value.(model.Service)
+		k := [2]string{s.Namespace, s.ServiceName}
+		c, ok := current[k]
+		if !ok {
+			c = &counts{}
+			current[k] = c
+		}
+		c.instances++
+		if r.isHealthy(s, now) {
+			c.healthy++
+		}
+		return true
+	})
+
+	t := r.slo
+	t.mu.Lock()
+	for k := range current {
+		t.getLocked(k[0], k[1])
+	}
+	var changed []Alert
+	for key, h := range t.services {
+		c := current[[2]string{h.namespace, h.name}]
+		if c == nil {
+			c = &counts{}
+		}
+		// 评估中断（例如进程暂停）时最多计入两个评估周期，避免一次计入过长的时长
+		if !h.lastEval.IsZero() {
+			elapsed := now.Sub(h.lastEval)
+			if elapsed > 2*t.interval {
+				elapsed = 2 * t.interval
+			}
+			h.record(now, elapsed, c.healthy > 0)
+		}
+		h.lastEval = now
+		h.healthy, h.instances = c.healthy, c.instances
+		if c.instances > 0 {
+			h.lastSeen = now
+		} else if now.Sub(h.lastSeen) > availabilityWindows[len(availabilityWindows)-1].duration && len(h.flaps) == 0 {
+			// 下线超过最长统计窗口的服务不再跟踪
+			delete(t.services, key)
+			continue
+		}
+		h.pruneFlaps(now)
+
+		threshold := t.policy.thresholdFor(h.namespace, h.name)
+		if alert := h.evaluate(AlertMinHealthy, threshold.MinHealthy, h.healthy, h.healthy < threshold.MinHealthy, now,
+			fmt.Sprintf("%d healthy instances, minimum is %d", h.healthy, threshold.MinHealthy)); alert != nil {
+			changed = append(changed, *alert)
+		}
+		if alert := h.evaluate(AlertFlapping, threshold.MaxFlapsPerHour, len(h.flaps), len(h.flaps) > threshold.MaxFlapsPerHour, now,
+			fmt.Sprintf("%d flaps in the last hour, maximum is %d", len(h.flaps), threshold.MaxFlapsPerHour)); alert != nil {
+			changed = append(changed, *alert)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, alert := range changed {
+		eventType := EventAlertResolved
+		if alert.State == AlertStateFiring {
+			eventType = EventAlertFiring
+			logrus.Warnf("Alert %s firing for %s/%s: %s", alert.Kind, alert.Namespace, alert.Service, alert.Message)
+		} else {
+			logrus.Infof("Alert %s resolved for %s/%s", alert.Kind, alert.Namespace, alert.Service)
+		}
+		r.publishAlert(CatalogEvent{
+			Type:        eventType,
+			Source:      EventSourceMonitor,
+			Namespace:   alert.Namespace,
+			ServiceName: alert.Service,
+			Reason:      alert.Kind + ": " + alert.Message,
+		})
+	}
+}
+
+// evaluate 更新一种告警的状态，threshold 为 0 时不告警；状态变化时返回告警的副本
+func (h *serviceHealth) evaluate(kind string, threshold, value int, breached bool, now time.Time, message string) *Alert {
+	alert, ok := h.alerts[kind]
+	if threshold <= 0 {
+		delete(h.alerts, kind)
+		return nil
+	}
+	state := AlertStateOK
+	if breached {
+		state = AlertStateFiring
+	}
+	if !ok {
+		// 新的告警从正常状态开始，首次评估即正常时不发布 resolved 事件
+		alert = &Alert{Namespace: h.namespace, Service: h.name, Kind: kind, State: AlertStateOK, Since: now.UTC()}
+		h.alerts[kind] = alert
+	}
+	alert.Value, alert.Threshold = value, threshold
+	alert.Message = ""
+	if breached {
+		alert.Message = message
+	}
+	if alert.State == state {
+		return nil
+	}
+	alert.State = state
+	alert.Since = now.UTC()
+	copied := *alert
+	return &copied
+}
+
+// alerts 返回告警状态列表，按命名空间、服务名和告警类型排序
+func (t *sloTracker) alerts(namespace, state string) []Alert {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := []Alert{}
+	for _, h := range t.services {
+		if namespace != "" && h.namespace != namespace {
+			continue
+		}
+		for _, alert := range h.alerts {
+			if state == "" || alert.State == state {
+				list = append(list, *alert)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Kind < b.Kind
+	})
+	return list
+}
+
+// WindowAvailability 是一个统计窗口内的可用率
+type WindowAvailability struct {
+	Window          string   `json:"window"`
+	Availability    *float64 `json:"availability"` // 没有观测数据时为 null
+	ObservedSeconds float64  `json:"observedSeconds"`
+}
+
+// ServiceSLO 是一个服务的健康统计和可用率
+type ServiceSLO struct {
+	Namespace        string               `json:"namespace"`
+	Service          string               `json:"service"`
+	HealthyInstances int                  `json:"healthyInstances"`
+	Instances        int                  `json:"instances"`
+	FlapsLastHour    int                  `json:"flapsLastHour"`
+	Thresholds       AlertThreshold       `json:"thresholds"`
+	Availability     []WindowAvailability `json:"availability"`
+}
+
+// report 返回服务的可用率统计
+func (t *sloTracker) report(namespace, service string, now time.Time) []ServiceSLO {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := []ServiceSLO{}
+	for _, h := range t.services {
+		if (namespace != "" && h.namespace != namespace) || (service != "" && h.name != service) {
+			continue
+		}
+		h.pruneFlaps(now)
+		slo := ServiceSLO{
+			Namespace:        h.namespace,
+			Service:          h.name,
+			HealthyInstances: h.healthy,
+			Instances:        h.instances,
+			FlapsLastHour:    len(h.flaps),
+			Thresholds:       t.policy.thresholdFor(h.namespace, h.name),
+		}
+		for _, w := range availabilityWindows {
+			ratio, observed := h.availability(now, w.duration)
+			wa := WindowAvailability{Window: w.name, ObservedSeconds: observed}
+			if ratio >= 0 {
+				wa.Availability = &ratio
+			}
+			slo.Availability = append(slo.Availability, wa)
+		}
+		list = append(list, slo)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Namespace != list[j].Namespace {
+			return list[i].Namespace < list[j].Namespace
+		}
+		return list[i].Service < list[j].Service
+	})
+	return list
+}
+
+// registerSLOMetrics 注册告警状态和可用率指标，抓取时根据当前统计计算
+func (r *Register) registerSLOMetrics() {
+	metrics.NewGaugeFunc("registry_service_alert",
+		"Whether a service alert is firing (1) or not (0), by namespace, service and alert kind.",
+		[]string{"namespace", "service", "alert"},
+		func(emit func(value float64, labelValues ...string)) {
+			for _, alert := range r.slo.alerts("", "") {
+				value := 0.0
+				if alert.State == AlertStateFiring {
+					value = 1
+				}
+				emit(value, alert.Namespace, alert.Service, alert.Kind)
+			}
+		})
+	metrics.NewGaugeFunc("registry_service_availability_ratio",
+		"Fraction of observed time a service had at least one healthy instance, by namespace, service and window.",
+		[]string{"namespace", "service", "window"},
+		func(emit func(value float64, labelValues ...string)) {
+			for _, slo := range r.slo.report("", "", time.Now()) {
+				for _, w := range slo.Availability {
+					if w.Availability != nil {
+						emit(*w.Availability, slo.Namespace, slo.Service, w.Window)
+					}
+				}
+			}
+		})
+}
+
+// AlertsHandler 返回告警状态，参数：namespace、state（ok、firing）
+func (r *Register) AlertsHandler(c *gin.Context) {
+	state := c.Query("state")
+	if state != "" && state != AlertStateOK && state != AlertStateFiring {
+		badRequest(c, "Invalid state parameter, must be ok or firing")
+		return
+	}
+	alerts := r.slo.alerts(c.Query("namespace"), state)
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts, "count": len(alerts)})
+}
+
+// SLOHandler 返回服务的健康统计和各窗口的可用率，参数：namespace、service
+func (r *Register) SLOHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"services": r.slo.report(c.Query("namespace"), c.Query("service"), time.Now())})
+}
+
+// ThresholdsHandler 返回当前的告警阈值
+func (r *Register) ThresholdsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, r.slo.policy)
+}
@@ -3,6 +3,9 @@ package register
 import (
 	"MicroService/pkg/httpclient"
 	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
@@ -13,11 +16,26 @@ import (
 // SyncRequest 用于接收增量同步请求的结构体
 type SyncRequest struct {
 	Service model.Service `json:"service"`
-	Action  string        `json:"action"` // "register" or "unregister"
+	Action  string        `json:"action"`          // "register"、"unregister"、"token-issue"、"token-revoke"、"acl-update"、"maintenance-on" 或 "maintenance-off"
+	Token   *TokenRecord  `json:"token,omitempty"` // 令牌同步时携带令牌哈希，不含明文
+	ACL     *ACLPolicy    `json:"acl,omitempty"`   // ACL 同步时携带完整策略，为空表示关闭 ACL
+
+	// Maintenance 是维护状态同步时的服务，结束维护时只包含命名空间和服务名
+	Maintenance *MaintenanceRecord `json:"maintenance,omitempty"`
+
+	// Reason 是注销的原因，管理员强制移除和手动清理时设置
+	Reason string `json:"reason,omitempty"`
+
+	// LeaseToken 是实例的租约令牌；model.Service 序列化时不包含租约，需要单独传递
+	LeaseToken string `json:"leaseToken,omitempty"`
+
+	// Origin 是发起变更的注册中心节点，用于审计
+	Origin string `json:"origin,omitempty"`
 }
 
 // SyncHandler 处理来自其他注册中心的同步请求
 func (r *Register) SyncHandler(c *gin.Context) {
+	c.Set(auditSourceKey, AuditSourceSync)
 	var req SyncRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse{
@@ -27,15 +45,155 @@ func (r *Register) SyncHandler(c *gin.Context) {
 		return
 	}
 
+	// 同步请求的命名空间取自实例记录或维护记录
+	req.Service.Namespace = normalizeNamespace(req.Service.Namespace)
+	c.Set(namespaceKey, req.Service.Namespace)
+	if req.Maintenance != nil {
+		req.Maintenance.Namespace = normalizeNamespace(req.Maintenance.Namespace)
+	}
+	if !r.authorizeSync(c, req) {
+		return
+	}
+
 	if req.Action == "register" {
 		// 更新本地服务列表
 		req.Service.LastHeartbeat = time.Now() // 收到同步时，更新心跳时间
+		// 新实例与本地注册一样经过准入策略和配额检查，避免对等节点越过本节点的限制
+		r.quotaMu.Lock()
+		defer r.quotaMu.Unlock()
+		previous, existed := r.LoadService(req.Service.Namespace, req.Service.ServiceId)
+		if !existed {
+			if _, rejection := r.admit(req.Service, true); rejection != nil {
+				logrus.WithContext(c.Request.Context()).Warnf("Rejected synchronized registration of %s-%s from %s: %s",
+					req.Service.ServiceName, req.Service.ServiceId, req.Origin, rejection.message)
+				r.auditSync(c, req, AuditAdmission, req.Service, rejection.reason+": "+rejection.message)
+				c.JSON(rejection.status, model.ErrorResponse{
+					Code:   rejection.status,
+					Error:  rejection.message,
+					Reason: rejection.reason,
+				})
+				return
+			}
+			if !r.checkQuota(c, req.Service.Namespace, 0) {
+				return
+			}
+		}
+		// 旧版本对等节点同步时不携带租约：保留已有实例的租约，新实例在本地生成租约，
+		// 不能留空，否则本节点对该实例的心跳、状态变更和注销不做租约校验
+		req.Service.LeaseToken = req.LeaseToken
+		if req.LeaseToken == "" {
+			if existed {
+				req.Service.LeaseToken = previous.LeaseToken
+			} else {
+				req.Service.LeaseToken = util.GenerateToken()
+			}
+		}
 		r.StoreService(req.Service)
-		logrus.Infof("Synchronized new service registration: %s-%s", req.Service.ServiceName, req.Service.ServiceId)
+		r.auditSync(c, req, AuditRegister, req.Service, "")
+		// 状态变更也以 register 动作同步，已有实例的状态变化发布为状态事件
+		if existed && statusOf(previous) != statusOf(req.Service) {
+			r.publishInstance(EventStatus, AuditSourceSync, req.Service, statusOf(previous), "")
+		} else {
+			r.publishInstance(EventRegister, AuditSourceSync, req.Service, "", "")
+		}
+		logrus.WithContext(c.Request.Context()).Infof("Synchronized new service registration: %s-%s", req.Service.ServiceName, req.Service.ServiceId)
 	} else if req.Action == "unregister" {
-		// 从本地服务列表移除
-		r.DeleteService(req.Service.ServiceId)
-		logrus.Infof("Synchronized service unregistration: %s-%s", req.Service.ServiceName, req.Service.ServiceId)
+		// 从本地服务列表移除；重复或迟到的同步找不到实例，不再审计和发布事件
+		r.quotaMu.Lock()
+		stored, existed := r.RemoveService(req.Service.Namespace, req.Service.ServiceId)
+		r.quotaMu.Unlock()
+		if !existed {
+			logrus.WithContext(c.Request.Context()).Debugf("Ignored unregistration of unknown service %s-%s", req.Service.ServiceName, req.Service.ServiceId)
+		} else {
+			r.auditSync(c, req, AuditUnregister, stored, req.Reason)
+			r.publishInstance(EventUnregister, AuditSourceSync, stored, "", req.Reason)
+			logrus.WithContext(c.Request.Context()).Infof("Synchronized service unregistration: %s-%s", stored.ServiceName, stored.ServiceId)
+		}
+	} else if (req.Action == "token-issue" || req.Action == "token-revoke") && req.Token != nil {
+		// 对等节点只能同步签发的服务令牌，不能借同步创建对等节点或管理员令牌
+		var err error
+		if req.Action == "token-issue" {
+			record := *req.Token
+			if record.Kind == "" {
+				record.Kind = IdentityService
+			}
+			if err = validateIssuedToken(record); err != nil {
+				c.JSON(http.StatusBadRequest, model.ErrorResponse{
+					Code:  http.StatusBadRequest,
+					Error: "Invalid token record: " + err.Error(),
+				})
+				return
+			}
+			err = r.tokens.issue(record)
+		} else if req.Token.Name == "" {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Code:  http.StatusBadRequest,
+				Error: "Invalid token record: token name is required",
+			})
+			return
+		} else {
+			_, err = r.tokens.revoke(req.Token.Name)
+		}
+		if errors.Is(err, errTokenConflict) {
+			c.JSON(http.StatusConflict, model.ErrorResponse{
+				Code:  http.StatusConflict,
+				Error: "Invalid token record: " + err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			logrus.WithContext(c.Request.Context()).Errorf("Failed to apply synchronized %s for %s: %v", req.Action, req.Token.Name, err)
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Code:  http.StatusInternalServerError,
+				Error: "Failed to apply " + req.Action + ": " + err.Error(),
+			})
+			return
+		}
+		r.auditSync(c, req, req.Action, model.Service{}, "token "+req.Token.Name)
+		logrus.WithContext(c.Request.Context()).Infof("Synchronized %s: %s", req.Action, req.Token.Name)
+	} else if req.Action == "acl-update" {
+		if req.ACL != nil {
+			if err := req.ACL.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, model.ErrorResponse{
+					Code:  http.StatusBadRequest,
+					Error: "Invalid ACL policy: " + err.Error(),
+				})
+				return
+			}
+		}
+		if err := r.acl.set(req.ACL); err != nil {
+			logrus.WithContext(c.Request.Context()).Errorf("Failed to persist synchronized ACL policy: %v", err)
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Code:  http.StatusInternalServerError,
+				Error: "Failed to persist ACL policy",
+			})
+			return
+		}
+		r.auditSync(c, req, AuditACLUpdate, model.Service{}, aclSummary(req.ACL))
+		logrus.WithContext(c.Request.Context()).Infof("Synchronized ACL policy update (enabled: %v)", req.ACL != nil)
+	} else if (req.Action == "maintenance-on" || req.Action == "maintenance-off") && req.Maintenance != nil {
+		record := *req.Maintenance
+		target := model.Service{Namespace: record.Namespace, ServiceName: record.ServiceName}
+		var err error
+		if req.Action == "maintenance-on" {
+			err = r.maintenance.set(record)
+		} else {
+			_, err = r.maintenance.clear(record.Namespace, record.ServiceName)
+		}
+		if err != nil {
+			logrus.WithContext(c.Request.Context()).Errorf("Failed to persist synchronized %s for %s/%s: %v", req.Action, record.Namespace, record.ServiceName, err)
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+				Code:  http.StatusInternalServerError,
+				Error: "Failed to persist maintenance state",
+			})
+			return
+		}
+		if req.Action == "maintenance-on" {
+			r.auditSync(c, req, AuditMaintenanceOn, target, record.Reason)
+		} else {
+			r.auditSync(c, req, AuditMaintenanceOff, target, "")
+		}
+		logrus.WithContext(c.Request.Context()).Infof("Synchronized %s: %s/%s", req.Action, record.Namespace, record.ServiceName)
 	} else {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse{
 			Code:  http.StatusBadRequest,
@@ -47,25 +205,73 @@ func (r *Register) SyncHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Sync successful"})
 }
 
+// authorizeSync 按 ACL 检查对等节点能否应用同步请求，不允许时写入 403 响应并返回 false
+// 实例和维护状态按所属服务检查 sync 动作；令牌和 ACL 是集群级配置，要求对所有服务（"*"）的 sync 权限
+// 关闭 ACL 或将默认效果改为 allow 会放开所有检查，还要求 admin 权限
+func (r *Register) authorizeSync(c *gin.Context, req SyncRequest) bool {
+	switch req.Action {
+	case "register", "unregister":
+		return r.authorizeACL(c, ActionSync, req.Service.ServiceName)
+	case "maintenance-on", "maintenance-off":
+		if req.Maintenance == nil {
+			return true // 由 SyncHandler 按无效请求拒绝
+		}
+		c.Set(namespaceKey, req.Maintenance.Namespace)
+		return r.authorizeACL(c, ActionSync, req.Maintenance.ServiceName)
+	case "acl-update":
+		if !r.authorizeACL(c, ActionSync, "*") {
+			return false
+		}
+		if req.ACL == nil || req.ACL.DefaultEffect == EffectAllow {
+			return r.authorizeACL(c, ActionAdmin, "*")
+		}
+		return true
+	default:
+		return r.authorizeACL(c, ActionSync, "*")
+	}
+}
+
+// auditSync 记录应用对等节点变更的审计事件，附带发起节点
+func (r *Register) auditSync(c *gin.Context, req SyncRequest, action string, service model.Service, reason string) {
+	event := r.requestEvent(c, action, service, reason)
+	event.Origin = req.Origin
+	r.audit.record(event)
+}
+
 // syncToPeers 异步地将变更推送到其他对等节点
-func (r *Register) syncToPeers(service model.Service, action string) {
+// ctx 仅用于延续链路追踪，同步请求不会随原请求结束而取消
+func (r *Register) syncToPeers(ctx context.Context, service model.Service, action string) {
+	r.pushToPeers(ctx, SyncRequest{Service: service, Action: action, LeaseToken: service.LeaseToken}, service.ServiceName+"-"+service.ServiceId)
+}
+
+// syncTokenToPeers 异步地将令牌签发或吊销推送到其他对等节点
+func (r *Register) syncTokenToPeers(ctx context.Context, record TokenRecord, action string) {
+	r.pushToPeers(ctx, SyncRequest{Action: action, Token: &record}, "token "+record.Name)
+}
+
+// syncACLToPeers 异步地将 ACL 策略推送到其他对等节点，policy 为 nil 表示关闭 ACL
+func (r *Register) syncACLToPeers(ctx context.Context, policy *ACLPolicy) {
+	r.pushToPeers(ctx, SyncRequest{Action: "acl-update", ACL: policy}, "ACL policy")
+}
+
+// pushToPeers 使用对等节点令牌将同步请求发送到所有对等节点
+func (r *Register) pushToPeers(ctx context.Context, syncReq SyncRequest, subject string) {
+	ctx = context.WithoutCancel(ctx)
+	syncReq.Origin = r.nodeID
 	// 使用 goroutine 异步发送，不阻塞主请求
 	go func() {
 		client := httpclient.NewClient(httpclient.DefaultConfig())
-		syncReq := SyncRequest{
-			Service: service,
-			Action:  action,
-		}
 
 		for _, peer := range r.Peers {
 			url := peer + "/api/internal/sync"
 			var resp interface{} // 我们不需要解析响应
-			err := client.Post(url, syncReq, &resp, httpclient.DefaultConfig())
+			err := client.PostJSON(ctx, url, syncReq, &resp, httpclient.WithIdempotent(), httpclient.WithBearerToken(r.peerToken))
 			if err != nil {
-				logrus.Errorf("Failed to sync %s action for service %s-%s to peer %s: %v",
-					action, service.ServiceName, service.ServiceId, peer, err)
+				syncFailuresTotal.Inc(peer)
+				logrus.WithContext(ctx).Errorf("Failed to sync %s action for %s to peer %s: %v",
+					syncReq.Action, subject, peer, err)
 			} else {
-				logrus.Debugf("Successfully synced %s for service %s to peer %s", action, service.ServiceId, peer)
+				logrus.WithContext(ctx).Debugf("Successfully synced %s for %s to peer %s", syncReq.Action, subject, peer)
 			}
 		}
 	}()
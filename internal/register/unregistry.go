@@ -31,7 +31,7 @@ func (r *Register) UnregisterHandler(c *gin.Context) {
 	}
 
 	// 检查服务是否存在且信息匹配
-	stored, ok := r.LoadService(service.ServiceId)
+	stored, ok := r.LoadService(namespaceOf(c), service.ServiceId)
 	if !ok {
 		c.JSON(http.StatusNotFound, model.ErrorResponse{
 			Code:  http.StatusNotFound,
@@ -39,6 +39,9 @@ func (r *Register) UnregisterHandler(c *gin.Context) {
 		})
 		return
 	}
+	if !r.authorizeService(c, ActionUnregister, stored.ServiceName) || !r.checkLease(c, stored, req.LeaseToken) {
+		return
+	}
 	if stored.ServiceName != service.ServiceName ||
 		stored.IpAddress != service.IpAddress ||
 		stored.Port != service.Port {
@@ -50,10 +53,14 @@ func (r *Register) UnregisterHandler(c *gin.Context) {
 	}
 
 	// 删除服务
-	r.DeleteService(service.ServiceId)
+	r.quotaMu.Lock()
+	r.DeleteService(stored.Namespace, stored.ServiceId)
+	r.quotaMu.Unlock()
+	r.auditRequest(c, AuditUnregister, stored, "")
+	r.publishInstance(EventUnregister, auditSource(c), stored, "", "")
 
 	// 新增：异步同步到其他对等节点
-	r.syncToPeers(stored, "unregister") // 注意这里使用 stored 对象，以确保信息完整
+	r.syncToPeers(c.Request.Context(), stored, "unregister") // 注意这里使用 stored 对象，以确保信息完整
 
 	// 返回成功响应
 	c.JSON(http.StatusOK, model.RegisterServiceResponse{
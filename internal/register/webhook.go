@@ -0,0 +1,567 @@
+package register
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/httpclient"
+	"MicroService/pkg/metrics"
+	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+)
+
+// EventPing 是测试订阅时发送的事件，不受订阅的事件过滤影响
+const EventPing = "ping"
+
+var validWebhookEvents = map[string]bool{
+	EventRegister: true, EventUnregister: true, EventExpire: true, EventStatus: true,
+	EventServiceDown: true, EventServiceUp: true, EventAlertFiring: true, EventAlertResolved: true, "*": true,
+}
+
+// webhook 请求头：接收方使用订阅的密钥对 "<时间戳>.<请求体>" 计算 HMAC-SHA256 并与签名比较
+const (
+	WebhookEventHeader     = "X-Registry-Event"
+	WebhookDeliveryHeader  = "X-Registry-Delivery"
+	WebhookTimestampHeader = "X-Registry-Timestamp"
+	WebhookSignatureHeader = "X-Registry-Signature" // 格式：sha256=<十六进制>
+)
+
+// 投递状态
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	webhookWorkers        = 4
+	webhookQueueSize      = 1000
+	webhookLogSize        = 1000 // 投递日志保留的最近记录数
+	webhookInitialBackoff = time.Second
+	webhookMaxBackoff     = time.Minute
+)
+
+var webhookDeliveriesTotal = metrics.NewCounterVec("registry_webhook_deliveries_total",
+	"Total number of webhook delivery attempts, by result (delivered, retry, failed).", "result")
+
+// WebhookConfig 定义 webhook 投递的配置
+type WebhookConfig struct {
+	File        string        // 订阅持久化文件（包含密钥），为空时只保存在内存中
+	MaxAttempts int           // 每次投递的最大尝试次数
+	Timeout     time.Duration // 单次请求超时
+}
+
+// WebhookSubscription 是一个 webhook 订阅，零值过滤条件表示不过滤
+// Services 和 Namespaces 支持 path.Match 通配符，与 ACL 规则相同
+type WebhookSubscription struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Events     []string  `json:"events,omitempty"`
+	Services   []string  `json:"services,omitempty"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	Secret     string    `json:"secret,omitempty"` // 只在创建时返回
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Validate 校验订阅
+func (s *WebhookSubscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q, must be an absolute http or https URL", s.URL)
+	}
+	for _, event := range s.Events {
+		if !validWebhookEvents[event] {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	for _, pattern := range s.Services {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid service pattern %q", pattern)
+		}
+	}
+	for _, pattern := range s.Namespaces {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// matches 判断订阅是否接收该事件
+func (s WebhookSubscription) matches(event CatalogEvent) bool {
+	return (len(s.Events) == 0 || matchAny(s.Events, event.Type)) &&
+		(len(s.Services) == 0 || matchService(s.Services, event.ServiceName)) &&
+		(len(s.Namespaces) == 0 || matchService(s.Namespaces, event.Namespace))
+}
+
+// WebhookDelivery 是投递日志中的一条记录
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscriptionId"`
+	URL            string     `json:"url"`
+	EventID        string     `json:"eventId"`
+	EventType      string     `json:"eventType"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	StatusCode     int        `json:"statusCode,omitempty"` // 最后一次尝试的响应状态码
+	Error          string     `json:"error,omitempty"`      // 最后一次尝试的错误
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+}
+
+// webhookJob 是投递队列中的一项，重试时重新入队
+type webhookJob struct {
+	delivery *WebhookDelivery
+	secret   string
+	body     []byte
+}
+
+// webhookManager 管理订阅并异步投递事件，失败时按指数退避重试
+// 订阅只保存在本节点：本节点收到的所有变更（包括对等节点同步的变更）都会投递，
+// 在多个节点上创建相同订阅会收到重复事件，接收方可按事件内容去重
+type webhookManager struct {
+	config WebhookConfig
+	client *httpclient.Client
+	queue  chan *webhookJob
+	stop   chan struct{}
+
+	mu            sync.RWMutex
+	subscriptions map[string]WebhookSubscription
+
+	logMu sync.Mutex
+	log   []*WebhookDelivery // 按创建时间排序，超出 webhookLogSize 时丢弃最旧的记录
+}
+
+// newWebhookManager 加载订阅文件并启动投递协程
+func newWebhookManager(config WebhookConfig) *webhookManager {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	clientConfig := httpclient.DefaultConfig()
+	m := &webhookManager{
+		config: config,
+		// 重试由投递队列负责，以便记录每次尝试；接收方故障不影响注册中心的其他出站请求，不启用熔断
+		client: httpclient.NewClient(httpclient.Config{
+			Timeout:     config.Timeout,
+			RetryPolicy: httpclient.RetryPolicy{MaxAttempts: 1},
+			TLS:         clientConfig.TLS,
+		}),
+		queue:         make(chan *webhookJob, webhookQueueSize),
+		stop:          make(chan struct{}),
+		subscriptions: make(map[string]WebhookSubscription),
+	}
+	m.load()
+	for i := 0; i < webhookWorkers; i++ {
+		go m.worker()
+	}
+	return m
+}
+
+// load 从订阅文件加载订阅，文件不存在时没有订阅
+func (m *webhookManager) load() {
+	if m.config.File == "" {
+		return
+	}
+	data, err := os.ReadFile(m.config.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		logrus.Fatalf("Failed to read webhook file %s: %v", m.config.File, err)
+	}
+	var subscriptions []WebhookSubscription
+	if err := json.Unmarshal(data, &subscriptions); err != nil {
+		logrus.Fatalf("Failed to parse webhook file %s: %v", m.config.File, err)
+	}
+	for _, s := range subscriptions {
+		if err := s.Validate(); err != nil {
+			logrus.Fatalf("Invalid webhook subscription %s in %s: %v", s.ID, m.config.File, err)
+		}
+		m.subscriptions[s.ID] = s
+	}
+	logrus.Infof("Loaded %d webhook subscriptions from %s", len(subscriptions), m.config.File)
+}
+
+// save 将订阅写回订阅文件，调用方需持有 mu
+func (m *webhookManager) save() error {
+	if m.config.File == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(m.sortedLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(m.config.File, data)
+}
+
+// sortedLocked 返回按创建时间排序的订阅，调用方需持有 mu
+func (m *webhookManager) sortedLocked() []WebhookSubscription {
+	list := make([]WebhookSubscription, 0, len(m.subscriptions))
+	for _, s := range m.subscriptions {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// add 添加订阅并持久化，持久化失败时撤销添加
+func (m *webhookManager) add(s WebhookSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscriptions[s.ID] = s
+	if err := m.save(); err != nil {
+		delete(m.subscriptions, s.ID)
+		return err
+	}
+	return nil
+}
+
+// remove 删除订阅并持久化，订阅不存在时返回 false；持久化失败时恢复订阅
+func (m *webhookManager) remove(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.subscriptions[id]
+	if !ok {
+		return false, nil
+	}
+	delete(m.subscriptions, id)
+	if err := m.save(); err != nil {
+		m.subscriptions[id] = s
+		return true, err
+	}
+	return true, nil
+}
+
+// get 返回订阅（包含密钥）
+func (m *webhookManager) get(id string) (WebhookSubscription, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.subscriptions[id]
+	return s, ok
+}
+
+// list 返回不含密钥的订阅列表
+func (m *webhookManager) list() []WebhookSubscription {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.sortedLocked()
+	for i := range list {
+		list[i].Secret = ""
+	}
+	return list
+}
+
+// notify 是事件订阅方，为每个匹配的订阅创建投递，不阻塞发布事件的请求
+func (m *webhookManager) notify(event CatalogEvent) {
+	m.mu.RLock()
+	var matched []WebhookSubscription
+	for _, s := range m.subscriptions {
+		if s.matches(event) {
+			matched = append(matched, s)
+		}
+	}
+	m.mu.RUnlock()
+	if len(matched) == 0 {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		logrus.Errorf("Failed to encode webhook event %s: %v", event.ID, err)
+		return
+	}
+	for _, s := range matched {
+		m.deliver(s, event, body)
+	}
+}
+
+// deliver 记录投递并放入队列，队列已满时直接记为失败
+func (m *webhookManager) deliver(s WebhookSubscription, event CatalogEvent, body []byte) *WebhookDelivery {
+	now := time.Now().UTC()
+	delivery := &WebhookDelivery{
+		ID:             util.GenerateUUID(),
+		SubscriptionID: s.ID,
+		URL:            s.URL,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		Status:         DeliveryPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	m.logMu.Lock()
+	m.log = append(m.log, delivery)
+	if len(m.log) > webhookLogSize {
+		m.log = append([]*WebhookDelivery(nil), m.log[len(m.log)-webhookLogSize:]...)
+	}
+	m.logMu.Unlock()
+
+	m.enqueue(&webhookJob{delivery: delivery, secret: s.Secret, body: body})
+	return delivery
+}
+
+func (m *webhookManager) enqueue(job *webhookJob) {
+	select {
+	case <-m.stop:
+	case m.queue <- job:
+	default:
+		webhookDeliveriesTotal.Inc(DeliveryFailed)
+		m.update(job.delivery, func(d *WebhookDelivery) {
+			d.Status = DeliveryFailed
+			d.Error = "delivery queue is full"
+			d.NextAttemptAt = nil
+		})
+		logrus.Warnf("Webhook delivery queue is full, dropping %s event %s for %s", job.delivery.EventType, job.delivery.EventID, job.delivery.URL)
+	}
+}
+
+// update 在日志锁内修改投递记录
+func (m *webhookManager) update(delivery *WebhookDelivery, fn func(d *WebhookDelivery)) {
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+	fn(delivery)
+	delivery.UpdatedAt = time.Now().UTC()
+}
+
+func (m *webhookManager) worker() {
+	for {
+		select {
+		case <-m.stop:
+			return
+		case job := <-m.queue:
+			m.attempt(job)
+		}
+	}
+}
+
+// attempt 发送一次投递；网络错误、超时、429 和 5xx 在尝试次数用完之前按指数退避重试
+func (m *webhookManager) attempt(job *webhookJob) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set(WebhookEventHeader, job.delivery.EventType)
+	header.Set(WebhookDeliveryHeader, job.delivery.ID)
+	header.Set(WebhookTimestampHeader, timestamp)
+	header.Set(WebhookSignatureHeader, "sha256="+signWebhook(job.secret, timestamp, job.body))
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	_, err := m.client.Do(ctx, &httpclient.Request{
+		Method: http.MethodPost,
+		URL:    job.delivery.URL,
+		Header: header,
+		Body:   json.RawMessage(job.body),
+	})
+	cancel()
+
+	var attempts int
+	m.update(job.delivery, func(d *WebhookDelivery) {
+		d.Attempts++
+		attempts = d.Attempts
+		d.StatusCode = httpclient.StatusCode(err)
+		d.NextAttemptAt = nil
+		if err == nil {
+			d.Status = DeliveryDelivered
+			d.Error = ""
+			return
+		}
+		d.Error = err.Error()
+	})
+	if err == nil {
+		webhookDeliveriesTotal.Inc(DeliveryDelivered)
+		return
+	}
+
+	code := httpclient.StatusCode(err)
+	retryable := code == 0 || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	if !retryable || attempts >= m.config.MaxAttempts {
+		webhookDeliveriesTotal.Inc(DeliveryFailed)
+		m.update(job.delivery, func(d *WebhookDelivery) { d.Status = DeliveryFailed })
+		logrus.Warnf("Webhook delivery %s of %s event %s to %s failed after %d attempts: %v",
+			job.delivery.ID, job.delivery.EventType, job.delivery.EventID, job.delivery.URL, attempts, err)
+		return
+	}
+
+	webhookDeliveriesTotal.Inc("retry")
+	backoff := webhookInitialBackoff << (attempts - 1)
+	if backoff > webhookMaxBackoff || backoff <= 0 {
+		backoff = webhookMaxBackoff
+	}
+	next := time.Now().Add(backoff).UTC()
+	m.update(job.delivery, func(d *WebhookDelivery) { d.NextAttemptAt = &next })
+	logrus.Debugf("Webhook delivery %s to %s failed (attempt %d), retrying in %v: %v", job.delivery.ID, job.delivery.URL, attempts, backoff, err)
+	time.AfterFunc(backoff, func() { m.enqueue(job) })
+}
+
+// signWebhook 计算 "<时间戳>.<请求体>" 的 HMAC-SHA256，时间戳参与签名，接收方可以拒绝过旧的请求防止重放
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliveries 返回最近的投递记录（最新的在前）
+func (m *webhookManager) deliveries(subscriptionID, status string, limit int) []WebhookDelivery {
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+	result := []WebhookDelivery{}
+	for i := len(m.log) - 1; i >= 0 && len(result) < limit; i-- {
+		d := m.log[i]
+		if (subscriptionID == "" || d.SubscriptionID == subscriptionID) && (status == "" || d.Status == status) {
+			result = append(result, *d)
+		}
+	}
+	return result
+}
+
+// close 停止投递协程，尚未完成的投递被丢弃
+func (m *webhookManager) close() {
+	close(m.stop)
+}
+
+// CreateWebhookRequest 是创建 webhook 订阅的请求，Secret 为空时自动生成
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	Events     []string `json:"events"`
+	Services   []string `json:"services"`
+	Namespaces []string `json:"namespaces"`
+	Secret     string   `json:"secret"`
+}
+
+// CreateWebhookHandler 创建 webhook 订阅，响应中的密钥只返回一次
+func (r *Register) CreateWebhookHandler(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	subscription := WebhookSubscription{
+		ID:         util.GenerateUUID(),
+		URL:        req.URL,
+		Events:     req.Events,
+		Services:   req.Services,
+		Namespaces: req.Namespaces,
+		Secret:     req.Secret,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := subscription.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:  http.StatusBadRequest,
+			Error: "Invalid webhook subscription: " + err.Error(),
+		})
+		return
+	}
+	if subscription.Secret == "" {
+		subscription.Secret = util.GenerateToken()
+	}
+	if err := r.webhooks.add(subscription); err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to persist webhook subscriptions: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to persist webhook subscription",
+		})
+		return
+	}
+	r.auditRequest(c, AuditWebhookCreate, model.Service{}, fmt.Sprintf("webhook %s to %s", subscription.ID, subscription.URL))
+
+	logrus.WithContext(c.Request.Context()).Infof("Created webhook subscription %s to %s", subscription.ID, subscription.URL)
+	c.JSON(http.StatusOK, subscription)
+}
+
+// ListWebhooksHandler 列出 webhook 订阅（不含密钥）
+func (r *Register) ListWebhooksHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"webhooks": r.webhooks.list()})
+}
+
+// DeleteWebhookHandler 删除 webhook 订阅
+func (r *Register) DeleteWebhookHandler(c *gin.Context) {
+	id := c.Param("id")
+	removed, err := r.webhooks.remove(id)
+	if err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("Failed to persist webhook subscriptions: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to persist webhook subscription removal",
+		})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "Webhook subscription not found: " + id,
+		})
+		return
+	}
+	r.auditRequest(c, AuditWebhookDelete, model.Service{}, "webhook "+id)
+
+	logrus.WithContext(c.Request.Context()).Infof("Deleted webhook subscription %s", id)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Webhook subscription deleted"})
+}
+
+// TestWebhookHandler 向订阅发送 ping 事件，用于检查接收方和签名校验
+func (r *Register) TestWebhookHandler(c *gin.Context) {
+	subscription, ok := r.webhooks.get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:  http.StatusNotFound,
+			Error: "Webhook subscription not found: " + c.Param("id"),
+		})
+		return
+	}
+	event := CatalogEvent{
+		ID:     util.GenerateUUID(),
+		Type:   EventPing,
+		Time:   time.Now().UTC(),
+		Node:   r.nodeID,
+		Source: auditSource(c),
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Failed to encode ping event",
+		})
+		return
+	}
+	delivery := r.webhooks.deliver(subscription, event, body)
+	c.JSON(http.StatusAccepted, gin.H{"deliveryId": delivery.ID, "eventId": event.ID})
+}
+
+const defaultDeliveryLimit = 100
+
+// WebhookDeliveriesHandler 查询投递日志，参数：subscription、status（pending、delivered、failed）、limit
+func (r *Register) WebhookDeliveriesHandler(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != DeliveryPending && status != DeliveryDelivered && status != DeliveryFailed {
+		badRequest(c, "Invalid status parameter, must be one of pending, delivered, failed")
+		return
+	}
+	limit, err := parsePositiveInt(c.Query("limit"), defaultDeliveryLimit)
+	if err != nil || limit > webhookLogSize {
+		badRequest(c, fmt.Sprintf("Invalid limit parameter, must be between 1 and %d", webhookLogSize))
+		return
+	}
+	deliveries := r.webhooks.deliveries(c.Query("subscription"), status, limit)
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "count": len(deliveries)})
+}
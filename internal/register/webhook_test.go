@@ -0,0 +1,144 @@
+package register
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForDelivery 等待投递记录离开 pending 状态
+func waitForDelivery(t *testing.T, m *webhookManager, subscriptionID string) WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if log := m.deliveries(subscriptionID, "", 10); len(log) > 0 && log[0].Status != DeliveryPending {
+			return log[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("delivery for subscription %s did not finish", subscriptionID)
+	return WebhookDelivery{}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		requests <- received{header: req.Header.Clone(), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := newWebhookManager(WebhookConfig{MaxAttempts: 1, Timeout: time.Second})
+	defer m.close()
+	subscription := WebhookSubscription{ID: "sub-1", URL: server.URL, Events: []string{EventRegister}, Services: []string{"order-*"}, Secret: "secret"}
+	if err := m.add(subscription); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	// 不匹配事件类型或服务名的事件不投递
+	m.notify(CatalogEvent{ID: "e0", Type: EventUnregister, Namespace: DefaultNamespace, ServiceName: "order-service"})
+	m.notify(CatalogEvent{ID: "e1", Type: EventRegister, Namespace: DefaultNamespace, ServiceName: "user-service"})
+	event := CatalogEvent{ID: "e2", Type: EventRegister, Namespace: DefaultNamespace, ServiceName: "order-service", ServiceId: "order-1"}
+	m.notify(event)
+
+	delivery := waitForDelivery(t, m, subscription.ID)
+	if delivery.Status != DeliveryDelivered || delivery.EventID != event.ID || delivery.Attempts != 1 {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+	got := <-requests
+	select {
+	case extra := <-requests:
+		t.Fatalf("unexpected extra delivery: %s", extra.body)
+	default:
+	}
+
+	if got.header.Get(WebhookEventHeader) != EventRegister || got.header.Get(WebhookDeliveryHeader) != delivery.ID {
+		t.Errorf("unexpected headers: %v", got.header)
+	}
+	timestamp := got.header.Get(WebhookTimestampHeader)
+	if want := "sha256=" + signWebhook("secret", timestamp, got.body); got.header.Get(WebhookSignatureHeader) != want {
+		t.Errorf("signature = %q, want %q", got.header.Get(WebhookSignatureHeader), want)
+	}
+	var decoded CatalogEvent
+	if err := json.Unmarshal(got.body, &decoded); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if decoded.ID != event.ID || decoded.ServiceId != event.ServiceId {
+		t.Errorf("body = %+v, want event %s", decoded, event.ID)
+	}
+}
+
+func TestWebhookDeliveryRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	m := newWebhookManager(WebhookConfig{MaxAttempts: 3, Timeout: time.Second})
+	defer m.close()
+	if err := m.add(WebhookSubscription{ID: "sub-1", URL: server.URL, Secret: "secret"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	m.notify(CatalogEvent{ID: "e1", Type: EventRegister, Namespace: DefaultNamespace, ServiceName: "order-service"})
+
+	delivery := waitForDelivery(t, m, "sub-1")
+	if delivery.Status != DeliveryDelivered || delivery.Attempts != 2 {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestWebhookDeliveryClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	m := newWebhookManager(WebhookConfig{MaxAttempts: 3, Timeout: time.Second})
+	defer m.close()
+	if err := m.add(WebhookSubscription{ID: "sub-1", URL: server.URL, Secret: "secret"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	m.notify(CatalogEvent{ID: "e1", Type: EventRegister, Namespace: DefaultNamespace, ServiceName: "order-service"})
+
+	// 4xx 不重试
+	delivery := waitForDelivery(t, m, "sub-1")
+	if delivery.Status != DeliveryFailed || delivery.Attempts != 1 || delivery.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestWebhookPersistFailureRollsBack(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "missing", "webhooks.json")
+	m := newWebhookManager(WebhookConfig{File: file, MaxAttempts: 1, Timeout: time.Second})
+	defer m.close()
+
+	if err := m.add(WebhookSubscription{ID: "sub-1", URL: "http://127.0.0.1:1/hook"}); err == nil {
+		t.Fatal("add succeeded although the webhook file cannot be written")
+	}
+	if list := m.list(); len(list) != 0 {
+		t.Fatalf("subscription kept after failed add: %+v", list)
+	}
+
+	m.subscriptions["sub-2"] = WebhookSubscription{ID: "sub-2", URL: "http://127.0.0.1:1/hook"}
+	if removed, err := m.remove("sub-2"); !removed || err == nil {
+		t.Fatalf("remove = %v, %v; want true and an error", removed, err)
+	}
+	if _, ok := m.get("sub-2"); !ok {
+		t.Fatal("subscription removed although the webhook file cannot be written")
+	}
+}
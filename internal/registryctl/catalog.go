@@ -0,0 +1,145 @@
+package registryctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"MicroService/pkg/httpclient"
+	"MicroService/pkg/model"
+)
+
+// catalogTimeout 是导入导出请求的最短超时，目录较大时传输和应用需要的时间比普通请求长
+const catalogTimeout = 2 * time.Minute
+
+// catalogClient 返回导入导出使用的客户端，超时取 --timeout 和 catalogTimeout 中较大的一个
+func (e *env) catalogClient() *Client {
+	if e.timeout >= catalogTimeout {
+		return e.client
+	}
+	return e.client.WithTimeout(catalogTimeout)
+}
+
+func runExport(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("export", "")
+	file := fs.String("file", "", "Write the catalog to this file instead of stdout")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	// 导出的目录只有 JSON 和 YAML 两种格式，表格格式按 JSON 导出
+	format := OutputJSON
+	if e.output == OutputYAML {
+		format = OutputYAML
+	}
+
+	opts := append(e.adminQuery(), httpclient.WithQuery("format", format))
+	resp, _, err := e.catalogClient().Do(ctx, http.MethodGet, "/api/admin/export", nil, opts...)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		_, err = e.stdout.Write(resp.Body)
+		return err
+	}
+	// 导出的目录包含租约令牌，只允许当前用户读写
+	if err := os.WriteFile(*file, resp.Body, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", *file, err)
+	}
+	fmt.Fprintf(e.stderr, "Catalog written to %s\n", *file)
+	return nil
+}
+
+// catalogBody 将 JSON 或 YAML 格式的目录转换为请求体，YAML 转换为等价的 JSON 对象
+func catalogBody(data []byte) (interface{}, error) {
+	if json.Valid(data) {
+		return json.RawMessage(data), nil
+	}
+	var catalog map[string]interface{}
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("catalog is neither valid JSON nor YAML: %v", err)
+	}
+	return catalog, nil
+}
+
+func runImport(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("import", "<file|->")
+	mode := fs.String("mode", "merge", "Import mode: merge (add and update) or replace (also remove instances missing from the file)")
+	dryRun := fs.Bool("dry-run", false, "Only report what would change")
+	overwriteLeases := fs.Bool("overwrite-leases", false, "Replace the leases of healthy instances with the leases in the file")
+	positional, err := e.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *mode != "merge" && *mode != "replace" {
+		return usageError{"invalid --mode " + *mode + ", must be merge or replace"}
+	}
+
+	var data []byte
+	if positional[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(positional[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read catalog: %v", err)
+	}
+	body, err := catalogBody(data)
+	if err != nil {
+		return err
+	}
+
+	opts := append(e.adminQuery(),
+		httpclient.WithQuery("mode", *mode),
+		httpclient.WithQuery("dryRun", strconv.FormatBool(*dryRun)),
+		httpclient.WithQuery("overwriteLeases", strconv.FormatBool(*overwriteLeases)),
+	)
+	var result model.ImportResult
+	if err := e.catalogClient().DoJSON(ctx, http.MethodPost, "/api/admin/import", body, &result, opts...); err != nil {
+		return err
+	}
+
+	return e.printer.print(result, func(w io.Writer) {
+		title := "Imported catalog"
+		if result.DryRun {
+			title = "Dry run, nothing was changed"
+		}
+		fmt.Fprintf(w, "%s (mode %s): %d added, %d updated, %d removed, %d unchanged, %d rejected\n",
+			title, result.Mode, len(result.Added), len(result.Updated), len(result.Removed), result.Unchanged, len(result.Rejected))
+		if len(result.Added)+len(result.Updated)+len(result.Removed)+len(result.MaintenanceStarted)+len(result.MaintenanceEnded)+len(result.Rejected) == 0 {
+			return
+		}
+		fmt.Fprintln(w, "\nCHANGE\tNAMESPACE\tSERVICE\tID\tFIELDS")
+		for _, group := range []struct {
+			change  string
+			changes []model.InstanceChange
+		}{{"add", result.Added}, {"update", result.Updated}, {"remove", result.Removed}} {
+			for _, c := range group.changes {
+				fields := strings.Join(c.Fields, ",")
+				if c.LeaseToken != "" {
+					fields = "leaseToken=" + c.LeaseToken
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", group.change, c.Namespace, c.ServiceName, c.ServiceId, fields)
+			}
+		}
+		for _, r := range result.Rejected {
+			fmt.Fprintf(w, "reject\t%s\t%s\t%s\t%s: %s\n", r.Namespace, r.ServiceName, r.ServiceId, r.Reason, r.Error)
+		}
+		for _, group := range []struct {
+			change   string
+			services []string
+		}{{"maintenance-on", result.MaintenanceStarted}, {"maintenance-off", result.MaintenanceEnded}} {
+			for _, key := range group.services {
+				namespace, service, _ := strings.Cut(key, "/")
+				fmt.Fprintf(w, "%s\t%s\t%s\t-\t\n", group.change, namespace, service)
+			}
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package registryctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"MicroService/pkg/httpclient"
+	"MicroService/pkg/model"
+)
+
+// Client 按顺序访问注册中心，当前注册中心不可用时故障转移到下一个
+// GET 请求在网络错误、超时或 5xx 时故障转移；其他请求可能已经被应用，只在连接未建立时故障转移
+type Client struct {
+	addrs      []string
+	token      string
+	namespace  string
+	config     httpclient.Config
+	httpClient *httpclient.Client
+}
+
+// NewClient 创建访问注册中心的客户端，namespace 通过 X-Namespace 请求头传递
+func NewClient(addrs []string, token, namespace string, config httpclient.Config) *Client {
+	var cleaned []string
+	for _, addr := range addrs {
+		if addr = strings.TrimRight(strings.TrimSpace(addr), "/"); addr != "" {
+			cleaned = append(cleaned, addr)
+		}
+	}
+	return &Client{
+		addrs:      cleaned,
+		token:      token,
+		namespace:  namespace,
+		config:     config,
+		httpClient: httpclient.NewClient(config),
+	}
+}
+
+// WithTimeout 返回使用指定单次请求超时的客户端，用于导入导出等耗时较长的请求
+func (c *Client) WithTimeout(timeout time.Duration) *Client {
+	config := c.config
+	config.Timeout = timeout
+	return &Client{
+		addrs:      c.addrs,
+		token:      c.token,
+		namespace:  c.namespace,
+		config:     config,
+		httpClient: httpclient.NewClient(config),
+	}
+}
+
+// failover 判断请求失败后能否发送到下一个注册中心
+func failover(method string, err error) bool {
+	if method == http.MethodGet {
+		return httpclient.IsUnavailable(err)
+	}
+	return httpclient.IsNotSent(err)
+}
+
+// Do 依次向注册中心发送请求，返回响应和应答的注册中心地址
+// 注册中心返回 4xx 时不再故障转移，直接返回错误
+func (c *Client) Do(ctx context.Context, method, path string, body interface{}, opts ...httpclient.RequestOption) (*httpclient.Response, string, error) {
+	if len(c.addrs) == 0 {
+		return nil, "", errors.New("no registry addresses configured")
+	}
+	var lastErr error
+	for _, addr := range c.addrs {
+		req := &httpclient.Request{Method: method, URL: addr + path, Body: body}
+		if c.namespace != "" {
+			httpclient.WithHeader("X-Namespace", c.namespace)(req)
+		}
+		httpclient.WithBearerToken(c.token)(req)
+		for _, opt := range opts {
+			opt(req)
+		}
+		resp, err := c.httpClient.Do(ctx, req)
+		if err == nil {
+			return resp, addr, nil
+		}
+		if ctx.Err() != nil || !failover(method, err) {
+			return nil, addr, err
+		}
+		lastErr = err
+	}
+	return nil, "", fmt.Errorf("all registries failed: %v", lastErr)
+}
+
+// DoJSON 发送请求并将 JSON 响应解码到 response
+func (c *Client) DoJSON(ctx context.Context, method, path string, body, response interface{}, opts ...httpclient.RequestOption) error {
+	resp, _, err := c.Do(ctx, method, path, body, opts...)
+	if err != nil {
+		return err
+	}
+	return resp.DecodeJSON(response)
+}
+
+// instancesPage 对应注册中心 ?all=true 的响应
+type instancesPage struct {
+	Total     int                  `json:"total"`
+	Instances []model.InstanceView `json:"instances"`
+}
+
+// Instances 分页获取命名空间中的实例，name 为空时返回所有服务的实例，at 不为空时查询历史目录
+// 服务没有实例时返回空列表
+func (c *Client) Instances(ctx context.Context, name string, includeUnhealthy bool, at string) ([]model.InstanceView, error) {
+	instances := []model.InstanceView{}
+	for page := 1; ; page++ {
+		opts := []httpclient.RequestOption{
+			httpclient.WithQuery("all", "true"),
+			httpclient.WithQuery("includeUnhealthy", strconv.FormatBool(includeUnhealthy)),
+			httpclient.WithQuery("page", strconv.Itoa(page)),
+			httpclient.WithQuery("pageSize", "1000"),
+		}
+		if name != "" {
+			opts = append(opts, httpclient.WithQuery("name", name))
+		}
+		if at != "" {
+			opts = append(opts, httpclient.WithQuery("at", at))
+		}
+		var resp instancesPage
+		err := c.DoJSON(ctx, http.MethodGet, "/api/discovery", nil, &resp, opts...)
+		if httpclient.IsNotFound(err) {
+			return instances, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, resp.Instances...)
+		if len(resp.Instances) == 0 || len(instances) >= resp.Total {
+			return instances, nil
+		}
+	}
+}
+
+// Instance 按 serviceId 查找命名空间中的实例，包括不健康的实例
+func (c *Client) Instance(ctx context.Context, serviceId string) (model.InstanceView, error) {
+	instances, err := c.Instances(ctx, "", true, "")
+	if err != nil {
+		return model.InstanceView{}, err
+	}
+	for _, inst := range instances {
+		if inst.ServiceId == serviceId {
+			return inst, nil
+		}
+	}
+	return model.InstanceView{}, fmt.Errorf("instance %s not found", serviceId)
+}
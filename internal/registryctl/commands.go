@@ -0,0 +1,459 @@
+// Package registryctl 实现注册中心的命令行工具
+package registryctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"MicroService/internal/registryctl/config"
+	"MicroService/pkg/httpclient"
+	"MicroService/pkg/model"
+	"MicroService/pkg/tlsutil"
+	"MicroService/pkg/util"
+)
+
+// command 是一个子命令
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = []command{
+	{"services", "List services with instance counts", runServices},
+	{"instances", "List instances, optionally of one service", runInstances},
+	{"get", "Show one instance", runGet},
+	{"register", "Register an instance manually", runRegister},
+	{"deregister", "Deregister an instance (evicts it when no lease token is given)", runDeregister},
+	{"status", "Change the status of an instance", runStatus},
+	{"watch", "Watch instance changes live", runWatch},
+	{"cluster", "Show registry node and peer status", runCluster},
+	{"export", "Export the catalog", runExport},
+	{"import", "Import a catalog exported by 'export'", runImport},
+}
+
+// usageError 表示命令行参数错误，退出码为 2
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// env 是子命令的运行环境：公共参数、注册中心客户端和输出格式
+type env struct {
+	config config.Config
+	stdout io.Writer
+	stderr io.Writer
+
+	addrs     string
+	token     string
+	namespace string
+	output    string
+	timeout   time.Duration
+
+	client  *Client
+	printer printer
+}
+
+// Run 执行 registryctl 命令，返回进程退出码
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	e := &env{config: config.LoadConfig(), stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		e.usage()
+		return 2
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		e.usage()
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(ctx, e, args[1:])
+		var usageErr usageError
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(stderr, "Error: %s\nRun 'registryctl %s -h' for usage.\n", usageErr.msg, cmd.name)
+			return 2
+		default:
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	fmt.Fprintf(stderr, "Error: unknown command %q\n\n", args[0])
+	e.usage()
+	return 2
+}
+
+// usage 打印子命令列表
+func (e *env) usage() {
+	fmt.Fprintln(e.stderr, "Usage: registryctl <command> [flags] [args]")
+	fmt.Fprintln(e.stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(e.stderr, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(e.stderr, "\nCommon flags (environment variables in parentheses):")
+	fmt.Fprintln(e.stderr, "  --registry-addrs  Comma-separated registry addresses, tried in order (REGISTRYCTL_ADDRS)")
+	fmt.Fprintln(e.stderr, "  --token           Bearer token; admin commands need the admin token (REGISTRYCTL_TOKEN)")
+	fmt.Fprintln(e.stderr, "  -n, --namespace   Namespace (REGISTRYCTL_NAMESPACE)")
+	fmt.Fprintln(e.stderr, "  -o, --output      Output format: table, json or yaml (REGISTRYCTL_OUTPUT)")
+	fmt.Fprintln(e.stderr, "  --timeout         Request timeout per registry, at least 2m for export and import (REGISTRYCTL_TIMEOUT_SECONDS)")
+	fmt.Fprintln(e.stderr, "\nRun 'registryctl <command> -h' for command flags.")
+}
+
+// flagSet 创建子命令的参数集合并注册公共参数
+func (e *env) flagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.StringVar(&e.addrs, "registry-addrs", strings.Join(e.config.RegistryAddrs, ","), "Comma-separated list of registry addresses, tried in order")
+	fs.StringVar(&e.token, "token", e.config.Token, "Bearer token for the registry")
+	fs.StringVar(&e.namespace, "namespace", e.config.Namespace, "Namespace")
+	fs.StringVar(&e.namespace, "n", e.config.Namespace, "Namespace (shorthand)")
+	fs.StringVar(&e.output, "output", e.config.Output, "Output format: table, json or yaml")
+	fs.StringVar(&e.output, "o", e.config.Output, "Output format (shorthand)")
+	fs.DurationVar(&e.timeout, "timeout", e.config.Timeout, "Request timeout per registry")
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: registryctl %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse 解析参数（参数和位置参数可以交替出现），检查位置参数个数并创建客户端
+func (e *env) parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) < minArgs || (maxArgs >= 0 && len(positional) > maxArgs) {
+		return nil, usageError{fmt.Sprintf("%s expects %s", fs.Name(), argCount(minArgs, maxArgs))}
+	}
+	if !validOutput(e.output) {
+		return nil, usageError{"invalid output format " + e.output + ", must be table, json or yaml"}
+	}
+
+	tlsConfig, err := tlsutil.LoadConfig().ClientTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up TLS: %v", err)
+	}
+	httpConfig := httpclient.DefaultConfig()
+	httpConfig.Timeout = e.timeout
+	httpConfig.MaxRetries = 0 // 失败时直接故障转移到下一个注册中心
+	httpConfig.Breaker.Enabled = false
+	httpConfig.TLS = tlsConfig
+	e.client = NewClient(strings.Split(e.addrs, ","), e.token, e.namespace, httpConfig)
+	e.printer = printer{format: e.output, out: e.stdout}
+	return positional, nil
+}
+
+func argCount(minArgs, maxArgs int) string {
+	switch {
+	case minArgs == maxArgs && minArgs == 0:
+		return "no arguments"
+	case minArgs == maxArgs:
+		return fmt.Sprintf("%d argument(s)", minArgs)
+	case maxArgs < 0:
+		return fmt.Sprintf("at least %d argument(s)", minArgs)
+	default:
+		return fmt.Sprintf("%d to %d argument(s)", minArgs, maxArgs)
+	}
+}
+
+// adminQuery 返回管理接口的 namespace 查询参数，管理接口不读取 X-Namespace 请求头
+func (e *env) adminQuery() []httpclient.RequestOption {
+	if e.namespace == "" {
+		return nil
+	}
+	return []httpclient.RequestOption{httpclient.WithQuery("namespace", e.namespace)}
+}
+
+// address 返回实例的访问地址
+func address(inst model.InstanceView) string {
+	return fmt.Sprintf("%s:%d", inst.IpAddress, inst.Port)
+}
+
+// age 返回时间距今的时长，用于表格输出
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
+
+// sortInstances 按服务名和 serviceId 排序
+func sortInstances(instances []model.InstanceView) {
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].ServiceName != instances[j].ServiceName {
+			return instances[i].ServiceName < instances[j].ServiceName
+		}
+		return instances[i].ServiceId < instances[j].ServiceId
+	})
+}
+
+// ServiceSummary 是 services 命令输出的一行
+type ServiceSummary struct {
+	ServiceName string `json:"serviceName"`
+	Instances   int    `json:"instances"`
+	Healthy     int    `json:"healthy"`
+}
+
+func runServices(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("services", "")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	instances, err := e.client.Instances(ctx, "", true, "")
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]*ServiceSummary)
+	for _, inst := range instances {
+		summary, ok := counts[inst.ServiceName]
+		if !ok {
+			summary = &ServiceSummary{ServiceName: inst.ServiceName}
+			counts[inst.ServiceName] = summary
+		}
+		summary.Instances++
+		if inst.Healthy {
+			summary.Healthy++
+		}
+	}
+	services := make([]ServiceSummary, 0, len(counts))
+	for _, summary := range counts {
+		services = append(services, *summary)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].ServiceName < services[j].ServiceName })
+
+	return e.printer.print(services, func(w io.Writer) {
+		fmt.Fprintln(w, "SERVICE\tINSTANCES\tHEALTHY")
+		for _, s := range services {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", s.ServiceName, s.Instances, s.Healthy)
+		}
+	})
+}
+
+func runInstances(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("instances", "[service]")
+	healthyOnly := fs.Bool("healthy", false, "Only list healthy instances")
+	at := fs.String("at", "", "Show the catalog as it was at this time (RFC3339 or a duration ago, e.g. 10m)")
+	positional, err := e.parse(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	name := ""
+	if len(positional) == 1 {
+		name = positional[0]
+	}
+	instances, err := e.client.Instances(ctx, name, !*healthyOnly, *at)
+	if err != nil {
+		return err
+	}
+	sortInstances(instances)
+
+	return e.printer.print(instances, func(w io.Writer) {
+		fmt.Fprintln(w, "SERVICE\tID\tADDRESS\tSCHEME\tSTATUS\tHEALTHY\tLAST HEARTBEAT")
+		for _, inst := range instances {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\t%s\n",
+				inst.ServiceName, inst.ServiceId, address(inst), inst.Scheme, inst.Status, inst.Healthy, age(inst.LastHeartbeat))
+		}
+	})
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("get", "<serviceId>")
+	positional, err := e.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	inst, err := e.client.Instance(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	return e.printer.print(inst, func(w io.Writer) {
+		fmt.Fprintf(w, "Namespace:\t%s\n", inst.Namespace)
+		fmt.Fprintf(w, "Service:\t%s\n", inst.ServiceName)
+		fmt.Fprintf(w, "ID:\t%s\n", inst.ServiceId)
+		fmt.Fprintf(w, "Address:\t%s\n", address(inst))
+		fmt.Fprintf(w, "Scheme:\t%s\n", inst.Scheme)
+		fmt.Fprintf(w, "Status:\t%s\n", inst.Status)
+		fmt.Fprintf(w, "Healthy:\t%v\n", inst.Healthy)
+		fmt.Fprintf(w, "Last heartbeat:\t%s (%s)\n", inst.LastHeartbeat.Format(time.RFC3339), age(inst.LastHeartbeat))
+		keys := make([]string, 0, len(inst.Metadata))
+		for k := range inst.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(w, "Metadata:")
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s:\t%s\n", k, inst.Metadata[k])
+		}
+	})
+}
+
+func runRegister(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("register", "")
+	name := fs.String("name", "", "Service name (required)")
+	id := fs.String("id", "", "Service ID (default: a new UUID)")
+	ip := fs.String("ip", "", "Instance IP address (required)")
+	port := fs.Int("port", 0, "Instance port (required)")
+	scheme := fs.String("scheme", "", "Instance scheme: http or https")
+	metadata := fs.String("metadata", "", "Instance metadata, e.g. 'version=1.2.0,zone=a'")
+	lease := fs.String("lease", "", "Lease token to propose or to re-register an existing instance")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *name == "" || *ip == "" || *port <= 0 {
+		return usageError{"--name, --ip and --port are required"}
+	}
+	req := model.RegisterServiceRequest{
+		ServiceName: *name,
+		ServiceId:   *id,
+		IpAddress:   *ip,
+		Port:        *port,
+		Scheme:      *scheme,
+		LeaseToken:  *lease,
+	}
+	if req.ServiceId == "" {
+		req.ServiceId = util.GenerateUUID()
+	}
+	if *metadata != "" {
+		m, err := util.ParseKeyValues(*metadata)
+		if err != nil {
+			return usageError{"invalid --metadata: " + err.Error()}
+		}
+		req.Metadata = m
+	}
+
+	var resp model.RegisterServiceResponse
+	if err := e.client.DoJSON(ctx, http.MethodPost, "/api/register", req, &resp); err != nil {
+		return err
+	}
+	return e.printer.print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "Registered %s/%s at %s:%d\n", resp.Service.ServiceName, resp.Service.ServiceId, resp.Service.IpAddress, resp.Service.Port)
+		fmt.Fprintf(w, "Lease token:\t%s\n", resp.LeaseToken)
+		fmt.Fprintln(w, "The instance expires unless it sends heartbeats with this lease token.")
+	})
+}
+
+func runDeregister(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("deregister", "<serviceId>")
+	lease := fs.String("lease", "", "Lease token of the instance; without it the instance is evicted with the admin API")
+	positional, err := e.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id := positional[0]
+
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if *lease == "" {
+		// 没有租约时通过管理接口强制移除，需要管理员令牌
+		err = e.client.DoJSON(ctx, http.MethodDelete, "/api/admin/instances/"+url.PathEscape(id), nil, &resp, e.adminQuery()...)
+	} else {
+		inst, lookupErr := e.client.Instance(ctx, id)
+		if lookupErr != nil {
+			return lookupErr
+		}
+		req := model.RegisterServiceRequest{
+			ServiceName: inst.ServiceName,
+			ServiceId:   inst.ServiceId,
+			IpAddress:   inst.IpAddress,
+			Port:        inst.Port,
+			LeaseToken:  *lease,
+		}
+		err = e.client.DoJSON(ctx, http.MethodPost, "/api/unregister", req, &resp)
+	}
+	if err != nil {
+		return err
+	}
+	return e.printer.print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "%s: %s\n", resp.Message, id)
+	})
+}
+
+func runStatus(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("status", "<serviceId> <UP|DOWN|OUT_OF_SERVICE>")
+	lease := fs.String("lease", "", "Lease token of the instance")
+	positional, err := e.parse(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	status := strings.ToUpper(positional[1])
+	if !model.ValidStatus(status) {
+		return usageError{"invalid status " + positional[1] + ", must be UP, DOWN or OUT_OF_SERVICE"}
+	}
+
+	req := model.StatusRequest{ServiceId: positional[0], Status: status, LeaseToken: *lease}
+	var resp model.RegisterServiceResponse
+	if err := e.client.DoJSON(ctx, http.MethodPost, "/api/status", req, &resp); err != nil {
+		return err
+	}
+	return e.printer.print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "%s: %s/%s is %s\n", resp.Message, resp.Service.ServiceName, resp.Service.ServiceId, resp.Service.Status)
+	})
+}
+
+// ClusterStatus 对应注册中心 /api/admin/cluster 的响应
+type ClusterStatus struct {
+	Address string             `json:"address"` // 应答的注册中心地址
+	Node    model.NodeStatus   `json:"node"`
+	Peers   []model.PeerStatus `json:"peers"`
+}
+
+func runCluster(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("cluster", "")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	resp, addr, err := e.client.Do(ctx, http.MethodGet, "/api/admin/cluster", nil)
+	if err != nil {
+		return err
+	}
+	var status ClusterStatus
+	if err := resp.DecodeJSON(&status); err != nil {
+		return err
+	}
+	status.Address = addr
+
+	return e.printer.print(status, func(w io.Writer) {
+		fmt.Fprintln(w, "NODE\tADDRESS\tREACHABLE\tLATENCY\tINSTANCES\tHEALTHY\tSERVICES\tALERTS\tUPTIME\tERROR")
+		row := func(node model.NodeStatus, address, latency string) {
+			fmt.Fprintf(w, "%s\t%s\tyes\t%s\t%d\t%d\t%d\t%d\t%s\t\n", node.Node, address, latency,
+				node.Instances, node.HealthyInstances, node.Services, node.FiringAlerts, time.Since(node.StartedAt).Round(time.Second))
+		}
+		row(status.Node, status.Address+" (answered)", "-")
+		for _, peer := range status.Peers {
+			latency := fmt.Sprintf("%.1fms", peer.LatencyMs)
+			if peer.Reachable && peer.Status != nil {
+				row(*peer.Status, peer.Address, latency)
+				continue
+			}
+			fmt.Fprintf(w, "-\t%s\tno\t%s\t-\t-\t-\t-\t-\t%s\n", peer.Address, latency, peer.Error)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config 包含 registryctl 的默认配置，命令行参数优先于环境变量
+type Config struct {
+	RegistryAddrs []string      // 注册中心地址列表，按顺序故障转移
+	Token         string        // 访问注册中心的令牌，管理命令需要管理员令牌
+	Namespace     string        // 命名空间，为空时使用默认命名空间
+	Output        string        // 输出格式：table、json 或 yaml
+	Timeout       time.Duration // 单个注册中心的请求超时时间
+}
+
+// LoadConfig 从环境变量加载 registryctl 配置
+func LoadConfig() Config {
+	config := Config{
+		RegistryAddrs: []string{"http://localhost:8180"}, // 默认注册中心地址
+		Output:        "table",                           // 默认以表格输出
+		Timeout:       5 * time.Second,                   // 默认超时 5 秒
+	}
+
+	// 加载 REGISTRYCTL_ADDRS
+	if addrs := os.Getenv("REGISTRYCTL_ADDRS"); addrs != "" {
+		config.RegistryAddrs = strings.Split(addrs, ",")
+	}
+
+	// 加载 REGISTRYCTL_TOKEN
+	if token := os.Getenv("REGISTRYCTL_TOKEN"); token != "" {
+		config.Token = token
+	}
+
+	// 加载 REGISTRYCTL_NAMESPACE
+	if namespace := os.Getenv("REGISTRYCTL_NAMESPACE"); namespace != "" {
+		config.Namespace = namespace
+	}
+
+	// 加载 REGISTRYCTL_OUTPUT
+	if output := os.Getenv("REGISTRYCTL_OUTPUT"); output != "" {
+		config.Output = output
+	}
+
+	// 加载 REGISTRYCTL_TIMEOUT_SECONDS
+	if timeoutStr := os.Getenv("REGISTRYCTL_TIMEOUT_SECONDS"); timeoutStr != "" {
+		if timeout, err := strconv.Atoi(timeoutStr); err == nil && timeout > 0 {
+			config.Timeout = time.Duration(timeout) * time.Second
+		} else {
+			logrus.Warnf("Invalid REGISTRYCTL_TIMEOUT_SECONDS: %s, using default: %v", timeoutStr, config.Timeout)
+		}
+	}
+
+	return config
+}
@@ -0,0 +1,75 @@
+package registryctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 输出格式
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// validOutput 判断输出格式是否合法
+func validOutput(format string) bool {
+	return format == OutputTable || format == OutputJSON || format == OutputYAML
+}
+
+// printer 按输出格式打印命令结果
+type printer struct {
+	format string
+	out    io.Writer
+}
+
+// print 以 JSON、YAML 打印 v，表格格式时调用 table 逐行写入
+func (p printer) print(v interface{}, table func(w io.Writer)) error {
+	switch p.format {
+	case OutputJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.out, string(data))
+		return err
+	case OutputYAML:
+		data, err := toYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = p.out.Write(data)
+		return err
+	default:
+		w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	}
+}
+
+// toYAML 将 v 编码为 YAML，字段名和顺序与 JSON 输出一致
+// 先编码为 JSON 再解析为 yaml.Node，model 中的结构体只有 json 标签
+func toYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// blockStyle 清除从 JSON 解析得到的流式和引号样式，由编码器按需加引号
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,159 @@
+package registryctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"MicroService/pkg/model"
+)
+
+// 实例变更类型
+const (
+	WatchAdded    = "ADDED"
+	WatchModified = "MODIFIED"
+	WatchDeleted  = "DELETED"
+)
+
+// WatchEvent 是 watch 命令输出的一次实例变更
+type WatchEvent struct {
+	Time     time.Time          `json:"time"`
+	Type     string             `json:"type"`
+	Instance model.InstanceView `json:"instance"`
+	Changes  []string           `json:"changes,omitempty"` // MODIFIED 时变化的字段
+}
+
+// instanceChanges 返回实例变化的字段，心跳时间不算作变化
+func instanceChanges(old, cur model.InstanceView) []string {
+	var changes []string
+	if old.IpAddress != cur.IpAddress || old.Port != cur.Port {
+		changes = append(changes, "address")
+	}
+	if old.Scheme != cur.Scheme {
+		changes = append(changes, "scheme")
+	}
+	if old.Status != cur.Status {
+		changes = append(changes, "status")
+	}
+	if old.Healthy != cur.Healthy {
+		changes = append(changes, "healthy")
+	}
+	if len(old.Metadata) != len(cur.Metadata) || (len(old.Metadata) > 0 && !reflect.DeepEqual(old.Metadata, cur.Metadata)) {
+		changes = append(changes, "metadata")
+	}
+	return changes
+}
+
+// diffInstances 比较两次轮询的实例列表，按 serviceId 顺序返回变更
+func diffInstances(previous map[string]model.InstanceView, current []model.InstanceView, now time.Time) ([]WatchEvent, map[string]model.InstanceView) {
+	var events []WatchEvent
+	next := make(map[string]model.InstanceView, len(current))
+	for _, inst := range current {
+		next[inst.ServiceId] = inst
+		old, ok := previous[inst.ServiceId]
+		if !ok {
+			events = append(events, WatchEvent{Time: now, Type: WatchAdded, Instance: inst})
+			continue
+		}
+		if changes := instanceChanges(old, inst); len(changes) > 0 {
+			events = append(events, WatchEvent{Time: now, Type: WatchModified, Instance: inst, Changes: changes})
+		}
+	}
+	var deleted []model.InstanceView
+	for id, old := range previous {
+		if _, ok := next[id]; !ok {
+			deleted = append(deleted, old)
+		}
+	}
+	sortInstances(deleted)
+	for _, old := range deleted {
+		events = append(events, WatchEvent{Time: now, Type: WatchDeleted, Instance: old})
+	}
+	return events, next
+}
+
+// watchLineFormat 是表格格式的列宽，watch 逐行输出，不能使用 tabwriter 对齐
+const watchLineFormat = "%-8s  %-9s  %-20s  %-36s  %-21s  %-14s  %-7v  %s\n"
+
+// printWatchEvent 逐条输出变更：JSON 每行一个对象，YAML 每个变更一个文档
+func (p printer) printWatchEvent(event WatchEvent) error {
+	switch p.format {
+	case OutputJSON:
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.out, string(data))
+		return err
+	case OutputYAML:
+		data, err := toYAML(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.out, "---\n%s", data)
+		return err
+	default:
+		inst := event.Instance
+		changes := ""
+		for i, c := range event.Changes {
+			if i > 0 {
+				changes += ","
+			}
+			changes += c
+		}
+		_, err := fmt.Fprintf(p.out, watchLineFormat, event.Time.Format("15:04:05"), event.Type,
+			inst.ServiceName, inst.ServiceId, address(inst), inst.Status, inst.Healthy, changes)
+		return err
+	}
+}
+
+func runWatch(ctx context.Context, e *env, args []string) error {
+	fs := e.flagSet("watch", "[service]")
+	interval := fs.Duration("interval", 2*time.Second, "Polling interval")
+	positional, err := e.parse(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return usageError{"--interval must be positive"}
+	}
+	name := ""
+	if len(positional) == 1 {
+		name = positional[0]
+	}
+
+	// 注册中心没有推送接口，按固定间隔轮询并比较实例列表；首次轮询输出当前全部实例
+	if e.printer.format == OutputTable {
+		fmt.Fprintf(e.stdout, watchLineFormat, "TIME", "EVENT", "SERVICE", "ID", "ADDRESS", "STATUS", "HEALTHY", "CHANGES")
+	}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	var known map[string]model.InstanceView
+	for {
+		instances, err := e.client.Instances(ctx, name, true, "")
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			// 所有注册中心都不可用时保留上一次的结果，恢复后只输出期间的变更
+			fmt.Fprintf(e.stderr, "Warning: %v\n", err)
+		default:
+			sortInstances(instances)
+			var events []WatchEvent
+			events, known = diffInstances(known, instances, time.Now())
+			for _, event := range events {
+				if err := e.printer.printWatchEvent(event); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
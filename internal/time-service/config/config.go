@@ -6,13 +6,18 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/util"
 )
 
 type TimeServiceConfig struct {
 	Port              int
 	RegistryAddr      string
+	RegistryToken     string // 访问注册中心写接口的服务令牌
 	ServiceHostIP     string
 	HeartbeatInterval time.Duration
+	Metadata          map[string]string // 注册时上报的实例元数据
+	InstanceIDFile    string            // 持久化实例身份的文件，为空时每次启动使用新的 ServiceId
 }
 
 func LoadTimeServiceConfig() TimeServiceConfig {
@@ -30,10 +35,27 @@ func LoadTimeServiceConfig() TimeServiceConfig {
 		config.RegistryAddr = addr
 	}
 
+	if token := os.Getenv("REGISTRY_TOKEN"); token != "" {
+		config.RegistryToken = token
+	}
+
 	if ip := os.Getenv("SERVICE_HOST_IP"); ip != "" {
 		config.ServiceHostIP = ip
 	}
 
+	// 格式：version=1.2.0,zone=a
+	if metadataStr := os.Getenv("SERVICE_METADATA"); metadataStr != "" {
+		if metadata, err := util.ParseKeyValues(metadataStr); err == nil {
+			config.Metadata = metadata
+		} else {
+			logrus.Warnf("Invalid SERVICE_METADATA: %s, ignoring: %v", metadataStr, err)
+		}
+	}
+
+	if idFile := os.Getenv("SERVICE_INSTANCE_ID_FILE"); idFile != "" {
+		config.InstanceIDFile = idFile
+	}
+
 	if intervalStr := os.Getenv("HEARTBEAT_INTERVAL_SECONDS"); intervalStr != "" {
 		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
 			config.HeartbeatInterval = time.Duration(interval) * time.Second
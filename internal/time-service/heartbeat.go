@@ -2,34 +2,60 @@ package timeservice
 
 // 定时调用api/heartbeat
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"MicroService/pkg/httpclient"
+	"MicroService/pkg/metrics"
 	"MicroService/pkg/model"
 )
 
+// heartbeatsTotal 统计心跳结果：success、failure 或 reregistered
+var heartbeatsTotal = metrics.NewCounterVec("service_heartbeats_total",
+	"Total number of heartbeats sent to registries, by registry and result.", "registry", "result")
+
 // StartHeartbeat 定期向注册中心发送心跳
 // registryAddr: 注册中心的地址，例如 "http://localhost:8180"
+// serviceName: 服务名称，注册中心返回 404 时用于重新注册
 // serviceId: 本服务实例的唯一ID
 // ipAddress: 本服务实例的IP地址
 // port: 本服务实例的端口
+// scheme、metadata: 本服务实例的访问协议和元数据，重新注册时使用
 // interval: 心跳间隔
-func StartHeartbeat(registryAddrs []string, serviceId, ipAddress string, port int, interval time.Duration) chan struct{} {
+// token: 访问注册中心写接口的服务令牌
+// leaseToken: 注册时获得的租约令牌
+func StartHeartbeat(registryAddrs []string, serviceName, serviceId, ipAddress string, port int, scheme string, metadata map[string]string, interval time.Duration, token, leaseToken string) chan struct{} {
 	ticker := time.NewTicker(interval)
 	stopChan := make(chan struct{})
 
-	clientConfig := httpclient.DefaultConfig()
-	httpClient := httpclient.NewClient(clientConfig)
+	httpClient := httpclient.NewClient(httpclient.DefaultConfig())
 
 	heartbeatReq := model.HeartbeatRequest{
-		ServiceId: serviceId,
-		IpAddress: ipAddress,
-		Port:      port,
+		ServiceId:  serviceId,
+		IpAddress:  ipAddress,
+		Port:       port,
+		LeaseToken: leaseToken,
+	}
+	registerReq := model.RegisterServiceRequest{
+		ServiceName: serviceName,
+		ServiceId:   serviceId,
+		IpAddress:   ipAddress,
+		Port:        port,
+		Scheme:      scheme,
+		Metadata:    metadata,
+		LeaseToken:  leaseToken, // 重新注册时沿用原租约
 	}
 
+	// stopChan 关闭时取消进行中的心跳请求及其重试
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopChan
+		cancel()
+	}()
+
 	go func() {
 		defer ticker.Stop()
 		for {
@@ -38,10 +64,24 @@ func StartHeartbeat(registryAddrs []string, serviceId, ipAddress string, port in
 				for _, registryAddr := range registryAddrs {
 					heartbeatURL := fmt.Sprintf("%s/api/heartbeat", registryAddr)
 					var heartbeatResp model.HeartbeatResponse
-					err := httpClient.Post(heartbeatURL, heartbeatReq, &heartbeatResp, clientConfig)
-					if err != nil {
+					err := httpClient.PostJSON(ctx, heartbeatURL, heartbeatReq, &heartbeatResp, httpclient.WithIdempotent(), httpclient.WithBearerToken(token))
+					if httpclient.IsNotFound(err) {
+						// 注册中心不认识本实例（例如注册中心重启或实例已过期被清理），重新注册
+						logrus.Warnf("Service %s not found on registry %s, re-registering", serviceId, registryAddr)
+						var registerResp model.RegisterServiceResponse
+						registerURL := fmt.Sprintf("%s/api/register", registryAddr)
+						if err := httpClient.PostJSON(ctx, registerURL, registerReq, &registerResp, httpclient.WithIdempotent(), httpclient.WithBearerToken(token)); err != nil {
+							heartbeatsTotal.Inc(registryAddr, "failure")
+							logrus.Errorf("Failed to re-register service %s to %s: %v", serviceId, registryAddr, err)
+						} else {
+							heartbeatsTotal.Inc(registryAddr, "reregistered")
+							logrus.Infof("Service %s re-registered to %s", serviceId, registryAddr)
+						}
+					} else if err != nil {
+						heartbeatsTotal.Inc(registryAddr, "failure")
 						logrus.Errorf("Failed to send heartbeat for service %s to %s: %v", serviceId, registryAddr, err)
 					} else {
+						heartbeatsTotal.Inc(registryAddr, "success")
 						logrus.Debugf("Heartbeat sent successfully for service: %s to %s", heartbeatResp.ServiceId, registryAddr)
 					}
 				}
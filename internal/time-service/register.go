@@ -5,49 +5,78 @@ import (
 	"MicroService/pkg/httpclient"
 	"MicroService/pkg/model"
 	"MicroService/pkg/util"
+	"context"
 	"fmt"
+
+	"github.com/sirupsen/logrus"
 )
 
 // RegisterService 向注册中心注册服务
+// ctx: 控制注册请求的取消和超时
 // registryAddr: 注册中心的地址，例如 "http://localhost:8180"
 // serviceName: 服务名称，例如 "time-service"
+// identity: 实例身份（ServiceId 和提议的租约令牌），持久化的身份使重启后的实例沿用同一 ServiceId
 // ipAddress: 本服务实例的IP地址，如果为空则内部尝试自动检测
 // port: 本服务实例运行的端口
-func RegisterService(registryAddrs []string, serviceName, ipAddress string, port int) (string, error) {
+// scheme: 本实例的访问协议，"http" 或 "https"
+// metadata: 实例元数据，注册中心可能要求某些键（准入策略）
+// token: 注册中心签发或预共享的服务令牌，为空时不发送认证头
+// 返回 serviceId 和租约令牌，后续心跳、状态变更和注销时必须携带租约令牌
+func RegisterService(ctx context.Context, registryAddrs []string, serviceName string, identity util.InstanceIdentity, ipAddress string, port int, scheme string, metadata map[string]string, token string) (string, string, error) {
 	finalIPAddr := ipAddress
 	if finalIPAddr == "" {
 		// 如果未手动指定，则尝试自动获取
 		var err error
 		finalIPAddr, err = util.GetLocalIP()
 		if err != nil {
-			return "", fmt.Errorf("failed to get local IP address and no explicit IP was provided: %v", err)
+			return "", "", fmt.Errorf("failed to get local IP address and no explicit IP was provided: %v", err)
 		}
 	}
 
-	// 生成唯一的 ServiceId
-	serviceId := util.GenerateUUID()
+	// 同一租约提议给所有注册中心，使实例在任意节点上都使用同一租约
+	serviceId := identity.ServiceId
+	leaseToken := identity.LeaseToken
 
 	registerReq := model.RegisterServiceRequest{
 		ServiceName: serviceName,
 		ServiceId:   serviceId,
 		IpAddress:   finalIPAddr,
 		Port:        port,
+		Scheme:      scheme,
+		Metadata:    metadata,
+		LeaseToken:  leaseToken,
 	}
 
-	clientConfig := httpclient.DefaultConfig()
-	httpClient := httpclient.NewClient(clientConfig)
+	httpClient := httpclient.NewClient(httpclient.DefaultConfig())
 
 	// 遍历所有注册中心地址进行注册
+	// 不可达的注册中心会被跳过，由对等节点同步和心跳时的重新注册补齐
+	registered := 0
+	var lastErr error
 	for _, registryAddr := range registryAddrs {
 		registerURL := fmt.Sprintf("%s/api/register", registryAddr)
 		var registerResp model.RegisterServiceResponse
-		err := httpClient.Post(registerURL, registerReq, &registerResp, clientConfig)
+		err := httpClient.PostJSON(ctx, registerURL, registerReq, &registerResp, httpclient.WithIdempotent(), httpclient.WithBearerToken(token))
 		if err != nil {
-			// 如果注册失败，这里可以根据需要决定是继续尝试其他注册中心还是直接返回错误
-			return "", fmt.Errorf("failed to register service %s-%s at %s:%d to registry %s: %v", serviceName, serviceId, finalIPAddr, port, registryAddr, err)
+			if httpclient.IsUnavailable(err) {
+				logrus.Warnf("Registry %s unavailable, skipping registration of %s-%s: %v", registryAddr, serviceName, serviceId, err)
+				lastErr = err
+				continue
+			}
+			// 注册中心拒绝了注册请求（例如请求无效），直接返回错误
+			return "", "", fmt.Errorf("failed to register service %s-%s at %s:%d to registry %s: %v", serviceName, serviceId, finalIPAddr, port, registryAddr, err)
+		}
+		registered++
+		if registerResp.LeaseToken != "" && registerResp.LeaseToken != leaseToken {
+			// 注册中心没有接受提议的租约，后续使用其签发的租约
+			leaseToken = registerResp.LeaseToken
+			registerReq.LeaseToken = leaseToken
 		}
-		fmt.Printf("Service registered successfully to %s: %s\n", registryAddr, registerResp.Message)
+		logrus.WithContext(ctx).Infof("Service registered successfully to %s: %s", registryAddr, registerResp.Message)
+	}
+	if registered == 0 {
+		return "", "", fmt.Errorf("failed to register service %s-%s at %s:%d to any registry: %v", serviceName, serviceId, finalIPAddr, port, lastErr)
 	}
 
-	return serviceId, nil
+	return serviceId, leaseToken, nil
 }
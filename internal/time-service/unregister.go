@@ -2,6 +2,7 @@ package timeservice
 
 //退出信号时调用api/unregister
 import (
+	"context"
 	"fmt"
 
 	"github.com/sirupsen/logrus"
@@ -17,23 +18,25 @@ import (
 // ipAddress: 本服务实例的IP地址
 // port: 本服务实例的端口
 // registryAddrs: 注册中心的地址列表
-func UnregisterService(registryAddrs []string, serviceName, serviceId, ipAddress string, port int) error {
+// token: 访问注册中心写接口的服务令牌
+// leaseToken: 注册时获得的租约令牌
+func UnregisterService(ctx context.Context, registryAddrs []string, serviceName, serviceId, ipAddress string, port int, token, leaseToken string) error {
 	unregisterReq := model.RegisterServiceRequest{ // 复用注册请求结构
 		ServiceName: serviceName,
 		ServiceId:   serviceId,
 		IpAddress:   ipAddress,
 		Port:        port,
+		LeaseToken:  leaseToken,
 	}
 
-	clientConfig := httpclient.DefaultConfig()
-	httpClient := httpclient.NewClient(clientConfig)
+	httpClient := httpclient.NewClient(httpclient.DefaultConfig())
 
 	// 遍历所有注册中心地址，向每个地址发送注销请求
 	for _, registryAddr := range registryAddrs {
 		unregisterURL := fmt.Sprintf("%s/api/unregister", registryAddr)
 		var unregisterResp model.RegisterServiceResponse
 
-		err := httpClient.Post(unregisterURL, unregisterReq, &unregisterResp, clientConfig)
+		err := httpClient.PostJSON(ctx, unregisterURL, unregisterReq, &unregisterResp, httpclient.WithIdempotent(), httpclient.WithBearerToken(token))
 		if err != nil {
 			logrus.Errorf("Failed to unregister service %s-%s from registry %s: %v", serviceName, serviceId, registryAddr, err)
 			continue
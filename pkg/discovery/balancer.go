@@ -0,0 +1,63 @@
+package discovery
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"MicroService/pkg/model"
+)
+
+// Balancer 从一组实例中选择一个实例（客户端负载均衡）
+type Balancer interface {
+	Pick(serviceName string, instances []model.Service) (model.Service, bool)
+}
+
+// NewBalancer 根据名称创建负载均衡器，支持 "round-robin"（默认）和 "random"
+func NewBalancer(name string) Balancer {
+	switch name {
+	case "random":
+		return &RandomBalancer{}
+	default:
+		return NewRoundRobinBalancer()
+	}
+}
+
+// RoundRobinBalancer 按服务名维护计数器进行轮询
+type RoundRobinBalancer struct {
+	mu       sync.Mutex
+	counters map[string]*uint64
+}
+
+// NewRoundRobinBalancer 创建轮询负载均衡器
+func NewRoundRobinBalancer() *RoundRobinBalancer {
+	return &RoundRobinBalancer{counters: make(map[string]*uint64)}
+}
+
+// Pick 轮询选择实例
+func (b *RoundRobinBalancer) Pick(serviceName string, instances []model.Service) (model.Service, bool) {
+	if len(instances) == 0 {
+		return model.Service{}, false
+	}
+	b.mu.Lock()
+	counter, ok := b.counters[serviceName]
+	if !ok {
+		counter = new(uint64)
+		b.counters[serviceName] = counter
+	}
+	b.mu.Unlock()
+
+	index := (atomic.AddUint64(counter, 1) - 1) % uint64(len(instances))
+	return instances[index], true
+}
+
+// RandomBalancer 随机选择实例
+type RandomBalancer struct{}
+
+// Pick 随机选择实例
+func (b *RandomBalancer) Pick(_ string, instances []model.Service) (model.Service, bool) {
+	if len(instances) == 0 {
+		return model.Service{}, false
+	}
+	return instances[rand.Intn(len(instances))], true
+}
@@ -0,0 +1,316 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/httpclient"
+	"MicroService/pkg/model"
+)
+
+// ErrNoInstances 表示缓存和注册中心中都没有可用实例
+var ErrNoInstances = errors.New("no available instances")
+
+// ErrTooStale 表示缓存中的实例超过了允许的最大陈旧时间
+var ErrTooStale = errors.New("last known instances are too stale")
+
+// Config 定义服务发现客户端的配置
+type Config struct {
+	RegistryAddrs   []string      // 注册中心地址列表，按顺序故障转移
+	RefreshInterval time.Duration // 后台轮询刷新间隔
+	Balancer        string        // 负载均衡策略："round-robin" 或 "random"
+	SnapshotPath    string        // 目录快照文件路径，为空时不持久化
+	MaxStaleness    time.Duration // 陈旧实例允许使用的最长时间，0 表示不限制
+	Hedge           HedgeConfig   // 对冲请求配置
+
+	// InstanceFilter 在负载均衡前过滤实例，返回 false 的实例将被跳过（例如熔断中的实例）
+	// 所有实例都被过滤时退回到完整列表
+	InstanceFilter func(model.Service) bool
+}
+
+// DefaultConfig 返回默认的服务发现配置
+func DefaultConfig() Config {
+	return Config{
+		RefreshInterval: 10 * time.Second,
+		Balancer:        "round-robin",
+		MaxStaleness:    24 * time.Hour,
+		Hedge:           DefaultHedgeConfig(),
+	}
+}
+
+// cacheEntry 保存某个服务最近一次成功获取的实例列表
+type cacheEntry struct {
+	instances []model.Service
+	updatedAt time.Time // 最近一次从注册中心成功获取的时间
+	stale     bool      // 来自磁盘快照或最近一次刷新失败
+}
+
+// Selection 是一次实例选择的结果
+type Selection struct {
+	model.Service
+	Stale bool          // 为 true 表示实例来自最后已知目录，注册中心当前不可达
+	Age   time.Duration // 距离最近一次成功从注册中心获取的时间
+}
+
+// Client 是客户端服务发现 SDK，维护按服务名划分的本地缓存
+// 缓存通过后台轮询保持更新；当所有注册中心都不可用时继续使用最后已知的实例
+type Client struct {
+	config     Config
+	httpClient *httpclient.Client
+	balancer   Balancer
+
+	mu        sync.RWMutex
+	cache     map[string]*cacheEntry
+	watched   map[string]struct{}
+	preferred int // 最近一次成功的注册中心下标，优先使用
+
+	latencies     *latencyTracker // 成功调用的延迟样本，用于计算对冲等待时间
+	hedgeCounters hedgeCounters
+
+	snapshotMu      sync.Mutex
+	snapshotCatalog []byte    // 最近一次写入快照的实例列表（JSON），用于判断目录是否变化
+	snapshotSavedAt time.Time // 最近一次写入快照的时间
+
+	ctx    context.Context // 后台刷新使用的 context，Stop 时取消
+	cancel context.CancelFunc
+}
+
+// NewClient 创建服务发现客户端
+func NewClient(config Config, httpClient *httpclient.Client) *Client {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = DefaultConfig().RefreshInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		config:     config,
+		httpClient: httpClient,
+		balancer:   NewBalancer(config.Balancer),
+		cache:      make(map[string]*cacheEntry),
+		watched:    make(map[string]struct{}),
+		latencies:  newLatencyTracker(),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	if config.SnapshotPath != "" {
+		if err := c.loadSnapshot(); err != nil {
+			logrus.Warnf("Failed to load discovery snapshot %s: %v", config.SnapshotPath, err)
+		}
+	}
+	return c
+}
+
+// Watch 将服务加入后台刷新列表，并立即尝试获取一次实例
+func (c *Client) Watch(serviceName string) {
+	c.mu.Lock()
+	c.watched[serviceName] = struct{}{}
+	c.mu.Unlock()
+
+	if err := c.Refresh(c.ctx, serviceName); err != nil {
+		logrus.Warnf("Initial discovery of '%s' failed: %v", serviceName, err)
+	}
+}
+
+// Start 启动后台轮询刷新
+func (c *Client) Start() {
+	go func() {
+		ticker := time.NewTicker(c.config.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, name := range c.watchedServices() {
+					if err := c.Refresh(c.ctx, name); err != nil {
+						logrus.Warnf("Failed to refresh instances of '%s', keeping last known: %v", name, err)
+					}
+				}
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台轮询并取消进行中的刷新
+func (c *Client) Stop() {
+	c.cancel()
+}
+
+// Instances 返回服务的实例列表，优先使用缓存；缓存为空时同步向注册中心查询
+func (c *Client) Instances(ctx context.Context, serviceName string) ([]model.Service, error) {
+	entry, err := c.entry(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return entry.instances, nil
+}
+
+// Pick 使用负载均衡器选择一个实例，并标注实例是否来自陈旧目录
+func (c *Client) Pick(ctx context.Context, serviceName string) (Selection, error) {
+	entry, err := c.entry(ctx, serviceName)
+	if err != nil {
+		return Selection{}, err
+	}
+	instance, ok := c.balancer.Pick(serviceName, c.filter(entry.instances))
+	if !ok {
+		return Selection{}, fmt.Errorf("%w for '%s'", ErrNoInstances, serviceName)
+	}
+	return Selection{
+		Service: instance,
+		Stale:   entry.stale,
+		Age:     time.Since(entry.updatedAt),
+	}, nil
+}
+
+// entry 返回可用的缓存条目；缓存为空时先同步刷新一次
+// 陈旧条目由后台轮询负责刷新，避免注册中心故障期间每个请求都被阻塞
+func (c *Client) entry(ctx context.Context, serviceName string) (cacheEntry, error) {
+	entry, ok := c.cached(serviceName)
+	if !ok || len(entry.instances) == 0 {
+		if err := c.Refresh(ctx, serviceName); err != nil {
+			return cacheEntry{}, err
+		}
+		entry, _ = c.cached(serviceName)
+	}
+	if len(entry.instances) == 0 {
+		return cacheEntry{}, fmt.Errorf("%w for '%s'", ErrNoInstances, serviceName)
+	}
+	if entry.stale && c.config.MaxStaleness > 0 && time.Since(entry.updatedAt) > c.config.MaxStaleness {
+		return cacheEntry{}, fmt.Errorf("%w for '%s' (age %v)", ErrTooStale, serviceName, time.Since(entry.updatedAt).Round(time.Second))
+	}
+	return entry, nil
+}
+
+// Refresh 从注册中心拉取服务的全部健康实例，依次故障转移
+// 所有注册中心都失败时保留缓存中的最后已知实例并返回错误
+func (c *Client) Refresh(ctx context.Context, serviceName string) error {
+	addrs := c.config.RegistryAddrs
+	if len(addrs) == 0 {
+		return errors.New("no registry addresses configured")
+	}
+
+	c.mu.RLock()
+	start := c.preferred
+	c.mu.RUnlock()
+
+	var lastErr error
+	for i := 0; i < len(addrs); i++ {
+		idx := (start + i) % len(addrs)
+		instances, err := c.fetch(ctx, addrs[idx], serviceName)
+		if err != nil {
+			// 网络错误、超时、熔断和 5xx 均故障转移到下一个注册中心
+			logrus.Debugf("Failed to discover '%s' from registry %s: %v", serviceName, addrs[idx], err)
+			lastErr = err
+			if ctx.Err() != nil {
+				return err // 调用方已取消，不再尝试其他注册中心，也不标记缓存陈旧
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		c.cache[serviceName] = &cacheEntry{instances: instances, updatedAt: time.Now()}
+		c.preferred = idx
+		c.mu.Unlock()
+
+		if c.config.SnapshotPath != "" {
+			if err := c.saveSnapshot(); err != nil {
+				logrus.Warnf("Failed to save discovery snapshot %s: %v", c.config.SnapshotPath, err)
+			}
+		}
+		return nil
+	}
+
+	// 所有注册中心都不可达，将已有缓存标记为陈旧
+	c.mu.Lock()
+	if entry, ok := c.cache[serviceName]; ok {
+		entry.stale = true
+	}
+	c.mu.Unlock()
+	return fmt.Errorf("all registries failed to discover '%s': %v", serviceName, lastErr)
+}
+
+// instancesResponse 对应注册中心 ?all=true 的响应
+type instancesResponse struct {
+	Total     int                  `json:"total"`
+	Instances []model.InstanceView `json:"instances"`
+}
+
+// fetch 从单个注册中心分页获取服务的全部健康实例
+func (c *Client) fetch(ctx context.Context, registryAddr, serviceName string) ([]model.Service, error) {
+	var instances []model.Service
+	for page := 1; ; page++ {
+		var resp instancesResponse
+		err := c.httpClient.GetJSON(ctx, registryAddr+"/api/discovery", &resp,
+			httpclient.WithQuery("name", serviceName),
+			httpclient.WithQuery("all", "true"),
+			httpclient.WithQuery("page", strconv.Itoa(page)),
+			httpclient.WithQuery("pageSize", "1000"),
+		)
+		if httpclient.IsNotFound(err) {
+			return nil, nil // 注册中心正常响应，但服务当前没有健康实例
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, inst := range resp.Instances {
+			instances = append(instances, model.Service{
+				Namespace:   inst.Namespace,
+				ServiceName: inst.ServiceName,
+				ServiceId:   inst.ServiceId,
+				IpAddress:   inst.IpAddress,
+				Port:        inst.Port,
+				Scheme:      inst.Scheme,
+				Metadata:    inst.Metadata,
+			})
+		}
+		if len(resp.Instances) == 0 || len(instances) >= resp.Total {
+			return instances, nil
+		}
+	}
+}
+
+// filter 使用 InstanceFilter 过滤实例，全部被过滤时返回原列表
+func (c *Client) filter(instances []model.Service) []model.Service {
+	if c.config.InstanceFilter == nil {
+		return instances
+	}
+	var available []model.Service
+	for _, inst := range instances {
+		if c.config.InstanceFilter(inst) {
+			available = append(available, inst)
+		}
+	}
+	if len(available) == 0 {
+		return instances
+	}
+	return available
+}
+
+// cached 返回缓存条目的副本
+func (c *Client) cached(serviceName string) (cacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.cache[serviceName]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	copied := *entry
+	copied.instances = append([]model.Service(nil), entry.instances...)
+	return copied, true
+}
+
+// watchedServices 返回需要后台刷新的服务名列表
+func (c *Client) watchedServices() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.watched))
+	for name := range c.watched {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,233 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"MicroService/pkg/httpclient"
+	"MicroService/pkg/model"
+	"MicroService/pkg/tracing"
+)
+
+// HedgeConfig 定义对冲请求的配置
+type HedgeConfig struct {
+	Enabled    bool          // 是否启用对冲请求
+	Delay      time.Duration // 发送对冲请求前的等待时间；延迟样本不足时使用
+	Percentile float64       // 大于 0 时根据最近成功请求的延迟百分位（例如 0.95）自适应计算等待时间
+	MinDelay   time.Duration // 自适应等待时间的下限
+}
+
+// DefaultHedgeConfig 返回默认的对冲配置（默认关闭）
+func DefaultHedgeConfig() HedgeConfig {
+	return HedgeConfig{
+		Enabled:    false,
+		Delay:      100 * time.Millisecond,
+		Percentile: 0.95,
+		MinDelay:   10 * time.Millisecond,
+	}
+}
+
+// HedgeStats 是对冲请求的统计信息
+type HedgeStats struct {
+	Calls     uint64 `json:"calls"`     // 调用总数
+	Hedged    uint64 `json:"hedged"`    // 发出了对冲请求的调用数
+	HedgeWins uint64 `json:"hedgeWins"` // 对冲请求先于原请求成功的次数
+}
+
+// hedgeCounters 是对冲统计计数器
+type hedgeCounters struct {
+	calls     uint64
+	hedged    uint64
+	hedgeWins uint64
+}
+
+const latencySamples = 128   // 每个服务保留的延迟样本数
+const minLatencySamples = 20 // 计算百分位所需的最少样本数
+
+// latencyTracker 按服务记录最近成功请求的延迟
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	next    map[string]int
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		samples: make(map[string][]time.Duration),
+		next:    make(map[string]int),
+	}
+}
+
+// observe 记录一次延迟样本（环形缓冲）
+func (t *latencyTracker) observe(serviceName string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	samples := t.samples[serviceName]
+	if len(samples) < latencySamples {
+		t.samples[serviceName] = append(samples, d)
+		return
+	}
+	samples[t.next[serviceName]] = d
+	t.next[serviceName] = (t.next[serviceName] + 1) % latencySamples
+}
+
+// percentile 返回延迟百分位，样本不足时返回 false
+func (t *latencyTracker) percentile(serviceName string, p float64) (time.Duration, bool) {
+	t.mu.Lock()
+	samples := append([]time.Duration(nil), t.samples[serviceName]...)
+	t.mu.Unlock()
+	if len(samples) < minLatencySamples {
+		return 0, false
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	idx := int(float64(len(samples)-1) * p)
+	return samples[idx], true
+}
+
+// CallFunc 对选中的实例发起一次调用，ctx 在另一路请求胜出后会被取消
+type CallFunc func(ctx context.Context, instance Selection) (interface{}, error)
+
+// callResult 是一路调用的结果
+type callResult struct {
+	value    interface{}
+	instance Selection
+	err      error
+	hedge    bool
+}
+
+// Call 选择一个实例并执行 fn；启用对冲时，若原请求在等待时间内未完成（或提前失败），
+// 会向另一个实例发送对冲请求，返回先成功的结果并取消另一路请求
+// 整个调用记录为一个 span，原请求和对冲请求均为其子 span
+func (c *Client) Call(ctx context.Context, serviceName string, fn CallFunc) (interface{}, Selection, error) {
+	ctx, span := tracing.Start(ctx, "discovery.Call "+serviceName, tracing.SpanKindInternal)
+	defer span.End()
+	span.SetAttribute("peer.service", serviceName)
+
+	value, instance, hedged, err := c.call(ctx, serviceName, fn)
+	if instance.ServiceId != "" {
+		span.SetAttribute("peer.serviceId", instance.ServiceId)
+		span.SetAttribute("discovery.stale", instance.Stale)
+	}
+	span.SetAttribute("discovery.hedged", hedged)
+	span.RecordError(err)
+	return value, instance, err
+}
+
+// call 是 Call 的实现，额外返回是否发出了对冲请求
+func (c *Client) call(ctx context.Context, serviceName string, fn CallFunc) (interface{}, Selection, bool, error) {
+	primary, err := c.Pick(ctx, serviceName)
+	if err != nil {
+		return nil, Selection{}, false, err
+	}
+	atomic.AddUint64(&c.hedgeCounters.calls, 1) // 只统计实际发出的调用
+	if !c.config.Hedge.Enabled {
+		value, err := c.timedCall(ctx, serviceName, primary, fn)
+		return value, primary, false, err
+	}
+
+	// 对冲代替重试处理慢请求和失败：每一路只尝试一次，避免重试放大请求数
+	ctx, cancel := context.WithCancel(httpclient.WithoutRetries(ctx))
+	defer cancel() // 返回时取消未完成的一路请求
+
+	results := make(chan callResult, 2)
+	launch := func(instance Selection, hedge bool) {
+		go func() {
+			value, err := c.timedCall(ctx, serviceName, instance, fn)
+			results <- callResult{value: value, instance: instance, err: err, hedge: hedge}
+		}()
+	}
+	launch(primary, false)
+
+	timer := time.NewTimer(c.hedgeDelay(serviceName))
+	defer timer.Stop()
+
+	pending, hedged := 1, false
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedged = c.launchHedge(ctx, serviceName, primary, launch)
+				if hedged {
+					pending++
+				}
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if res.hedge {
+					atomic.AddUint64(&c.hedgeCounters.hedgeWins, 1)
+				}
+				return res.value, res.instance, hedged, nil
+			}
+			lastErr = res.err
+			// 原请求提前失败时立即发送对冲请求
+			if !hedged && ctx.Err() == nil {
+				hedged = c.launchHedge(ctx, serviceName, primary, launch)
+				if hedged {
+					pending++
+				}
+			}
+		}
+	}
+	return nil, primary, hedged, lastErr
+}
+
+// launchHedge 选择与原请求不同的实例并发送对冲请求，没有其他实例时返回 false
+func (c *Client) launchHedge(ctx context.Context, serviceName string, primary Selection, launch func(Selection, bool)) bool {
+	entry, err := c.entry(ctx, serviceName)
+	if err != nil {
+		return false
+	}
+	var others []model.Service
+	for _, inst := range c.filter(entry.instances) {
+		if inst.ServiceId != primary.ServiceId {
+			others = append(others, inst)
+		}
+	}
+	instance, ok := c.balancer.Pick(serviceName, others)
+	if !ok {
+		return false
+	}
+	atomic.AddUint64(&c.hedgeCounters.hedged, 1)
+	launch(Selection{Service: instance, Stale: entry.stale, Age: time.Since(entry.updatedAt)}, true)
+	return true
+}
+
+// timedCall 执行调用并记录成功请求的延迟
+func (c *Client) timedCall(ctx context.Context, serviceName string, instance Selection, fn CallFunc) (interface{}, error) {
+	start := time.Now()
+	value, err := fn(ctx, instance)
+	if err != nil {
+		return nil, fmt.Errorf("call to %s instance %s failed: %w", serviceName, instance.ServiceId, err)
+	}
+	c.latencies.observe(serviceName, time.Since(start))
+	return value, nil
+}
+
+// hedgeDelay 返回发送对冲请求前的等待时间
+func (c *Client) hedgeDelay(serviceName string) time.Duration {
+	hedge := c.config.Hedge
+	if hedge.Percentile > 0 {
+		if d, ok := c.latencies.percentile(serviceName, hedge.Percentile); ok {
+			if d < hedge.MinDelay {
+				return hedge.MinDelay
+			}
+			return d
+		}
+	}
+	return hedge.Delay
+}
+
+// HedgeStats 返回对冲请求统计，用于监控对冲率和对冲胜出率
+func (c *Client) HedgeStats() HedgeStats {
+	return HedgeStats{
+		Calls:     atomic.LoadUint64(&c.hedgeCounters.calls),
+		Hedged:    atomic.LoadUint64(&c.hedgeCounters.hedged),
+		HedgeWins: atomic.LoadUint64(&c.hedgeCounters.hedgeWins),
+	}
+}
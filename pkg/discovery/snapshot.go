@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+)
+
+// snapshot 是持久化到磁盘的最后已知目录
+type snapshot struct {
+	SavedAt  time.Time                  `json:"savedAt"`
+	Services map[string]snapshotService `json:"services"`
+}
+
+// snapshotService 是快照中单个服务的实例列表
+type snapshotService struct {
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Instances []model.Service `json:"instances"`
+}
+
+// snapshotRefreshInterval 是目录没有变化时重写快照的间隔，刷新其中的更新时间，
+// 避免重启后仍然有效的快照因 MaxStaleness 被丢弃
+func (c *Client) snapshotRefreshInterval() time.Duration {
+	if c.config.MaxStaleness > 0 && c.config.MaxStaleness/2 < time.Minute {
+		return c.config.MaxStaleness / 2
+	}
+	return time.Minute
+}
+
+// saveSnapshot 将当前缓存写入快照文件（先写临时文件再原子重命名）
+// 实例列表与上次写入相同且未到刷新间隔时不写文件
+func (c *Client) saveSnapshot() error {
+	c.mu.RLock()
+	snap := snapshot{
+		SavedAt:  time.Now(),
+		Services: make(map[string]snapshotService, len(c.cache)),
+	}
+	catalog := make(map[string][]model.Service, len(c.cache))
+	for name, entry := range c.cache {
+		snap.Services[name] = snapshotService{
+			UpdatedAt: entry.updatedAt,
+			Instances: entry.instances,
+		}
+		catalog[name] = entry.instances
+	}
+	c.mu.RUnlock()
+
+	catalogData, err := json.Marshal(catalog)
+	if err != nil {
+		return err
+	}
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+	if bytes.Equal(catalogData, c.snapshotCatalog) && time.Since(c.snapshotSavedAt) < c.snapshotRefreshInterval() {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := util.WriteFileAtomic(c.config.SnapshotPath, data); err != nil {
+		return err
+	}
+	c.snapshotCatalog = catalogData
+	c.snapshotSavedAt = snap.SavedAt
+	return nil
+}
+
+// loadSnapshot 从快照文件加载最后已知目录，加载的条目均标记为陈旧
+// 超过 MaxStaleness 的条目直接丢弃
+func (c *Client) loadSnapshot() error {
+	data, err := os.ReadFile(c.config.SnapshotPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, svc := range snap.Services {
+		if c.config.MaxStaleness > 0 && time.Since(svc.UpdatedAt) > c.config.MaxStaleness {
+			logrus.Warnf("Discarding snapshot of '%s' saved at %v: exceeds max staleness %v", name, svc.UpdatedAt, c.config.MaxStaleness)
+			continue
+		}
+		c.cache[name] = &cacheEntry{
+			instances: svc.Instances,
+			updatedAt: svc.UpdatedAt,
+			stale:     true,
+		}
+		logrus.Infof("Loaded %d last known instances of '%s' from snapshot (updated at %v)", len(svc.Instances), name, svc.UpdatedAt)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package httpclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 表示目标主机的熔断器处于打开状态，请求未被发送
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	StateClosed   BreakerState = iota // 关闭：请求正常通过
+	StateOpen                         // 打开：请求直接失败
+	StateHalfOpen                     // 半开：允许少量探测请求
+)
+
+// String 返回熔断器状态名称
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig 定义按主机划分的熔断器配置
+type BreakerConfig struct {
+	Enabled              bool          // 是否启用熔断
+	Window               time.Duration // 失败率统计窗口
+	MinRequests          int           // 窗口内达到该请求数后才计算失败率
+	FailureRateThreshold float64       // 失败率阈值（0-1），超过则打开熔断器
+	CoolDown             time.Duration // 打开状态持续时间，之后进入半开状态
+	HalfOpenMaxRequests  int           // 半开状态下允许同时进行的探测请求数
+}
+
+// DefaultBreakerConfig 返回默认的熔断器配置
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Enabled:              true,
+		Window:               30 * time.Second,
+		MinRequests:          5,
+		FailureRateThreshold: 0.5,
+		CoolDown:             10 * time.Second,
+		HalfOpenMaxRequests:  1,
+	}
+}
+
+// circuitBreaker 是单个主机的熔断器
+type circuitBreaker struct {
+	config BreakerConfig
+
+	mu          sync.Mutex
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int    // 半开状态下正在进行的探测请求数
+	generation  uint64 // 每次状态变化加一，状态变化之前放行的请求结果不再计入
+}
+
+func newCircuitBreaker(config BreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		config:      config,
+		state:       StateClosed,
+		windowStart: time.Now(),
+	}
+}
+
+// allow 判断请求是否可以发送，返回的代数在记录结果时传回
+func (b *circuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState(time.Now()) {
+	case StateOpen:
+		return 0, ErrCircuitOpen
+	case StateHalfOpen:
+		if b.probes >= b.config.HalfOpenMaxRequests {
+			return 0, ErrCircuitOpen
+		}
+		b.probes++
+	}
+	return b.generation, nil
+}
+
+// record 记录一次请求结果，放行之后状态已经变化的请求不计入：
+// 例如打开之前发出的慢请求不会被当作半开探测，探测成功关闭后迟到的探测失败也不会再次打开熔断器
+func (b *circuitBreaker) record(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	state := b.currentState(now)
+	if generation != b.generation {
+		return
+	}
+	switch state {
+	case StateHalfOpen:
+		b.probes--
+		if success {
+			b.reset(now)
+		} else {
+			b.trip(now)
+		}
+	case StateClosed:
+		if now.Sub(b.windowStart) > b.config.Window {
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+		b.requests++
+		if !success {
+			b.failures++
+		}
+		if b.requests >= b.config.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.config.FailureRateThreshold {
+			b.trip(now)
+		}
+	}
+}
+
+// release 释放半开状态下的探测名额（请求被调用方取消，结果不计入统计）
+func (b *circuitBreaker) release(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateHalfOpen && generation == b.generation {
+		b.probes--
+	}
+}
+
+// currentState 返回当前状态，打开状态超过冷却时间后转为半开
+func (b *circuitBreaker) currentState(now time.Time) BreakerState {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.config.CoolDown {
+		b.state = StateHalfOpen
+		b.probes = 0
+		b.generation++
+	}
+	return b.state
+}
+
+// trip 打开熔断器
+func (b *circuitBreaker) trip(now time.Time) {
+	b.state = StateOpen
+	b.openedAt = now
+	b.probes = 0
+	b.generation++
+}
+
+// reset 关闭熔断器并清空统计
+func (b *circuitBreaker) reset(now time.Time) {
+	b.state = StateClosed
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+	b.probes = 0
+	b.generation++
+}
+
+// breakerFor 返回指定主机的熔断器，不存在时创建
+func (c *Client) breakerFor(host string) *circuitBreaker {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		b = newCircuitBreaker(c.breakerConfig)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+// BreakerState 返回指定主机（host:port）的熔断器状态，用于监控
+func (c *Client) BreakerState(host string) BreakerState {
+	c.breakersMu.Lock()
+	b, ok := c.breakers[host]
+	c.breakersMu.Unlock()
+	if !ok {
+		return StateClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState(time.Now())
+}
+
+// BreakerStates 返回所有已知主机的熔断器状态，用于监控
+func (c *Client) BreakerStates() map[string]BreakerState {
+	c.breakersMu.Lock()
+	hosts := make([]string, 0, len(c.breakers))
+	for host := range c.breakers {
+		hosts = append(hosts, host)
+	}
+	c.breakersMu.Unlock()
+
+	states := make(map[string]BreakerState, len(hosts))
+	for _, host := range hosts {
+		states[host] = c.BreakerState(host)
+	}
+	return states
+}
+
+// Available 判断指定主机当前是否可以接收请求（熔断器未打开）
+// 可作为服务发现的实例过滤钩子，跳过熔断中的实例
+func (c *Client) Available(host string) bool {
+	if !c.breakerConfig.Enabled {
+		return true
+	}
+	return c.BreakerState(host) != StateOpen
+}
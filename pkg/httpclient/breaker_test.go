@@ -0,0 +1,201 @@
+package httpclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testBreakerConfig 返回窗口内 4 个请求、失败率 50% 打开的测试配置
+func testBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Enabled:              true,
+		Window:               time.Minute,
+		MinRequests:          4,
+		FailureRateThreshold: 0.5,
+		CoolDown:             time.Minute,
+		HalfOpenMaxRequests:  1,
+	}
+}
+
+// tripBreaker 连续记录失败直到熔断器打开，返回打开前放行的请求代数
+func tripBreaker(t *testing.T, b *circuitBreaker) uint64 {
+	t.Helper()
+	var generation uint64
+	for i := 0; i < b.config.MinRequests; i++ {
+		g, err := b.allow()
+		if err != nil {
+			t.Fatalf("allow before trip: %v", err)
+		}
+		generation = g
+		b.record(g, false)
+	}
+	if b.state != StateOpen {
+		t.Fatalf("state = %v after %d failures, want open", b.state, b.config.MinRequests)
+	}
+	return generation
+}
+
+// coolDown 模拟冷却时间已过
+func coolDown(b *circuitBreaker) {
+	b.openedAt = time.Now().Add(-b.config.CoolDown)
+}
+
+func TestBreakerTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []bool
+		want    BreakerState
+	}{
+		{"below min requests", []bool{false, false, false}, StateClosed},
+		{"failure rate below threshold", []bool{true, true, true, false}, StateClosed},
+		{"failure rate at threshold", []bool{true, false, true, false}, StateOpen},
+		{"all failures", []bool{false, false, false, false}, StateOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(testBreakerConfig())
+			for _, success := range tt.results {
+				g, err := b.allow()
+				if err != nil {
+					t.Fatalf("allow: %v", err)
+				}
+				b.record(g, success)
+			}
+			if b.state != tt.want {
+				t.Errorf("state = %v, want %v", b.state, tt.want)
+			}
+		})
+	}
+
+	// 统计窗口过期后重新计数
+	b := newCircuitBreaker(testBreakerConfig())
+	for i := 0; i < 3; i++ {
+		g, _ := b.allow()
+		b.record(g, false)
+	}
+	b.windowStart = time.Now().Add(-2 * b.config.Window)
+	g, _ := b.allow()
+	b.record(g, false)
+	if b.state != StateClosed || b.requests != 1 {
+		t.Errorf("state = %v, requests = %d after window expiry; want closed, 1", b.state, b.requests)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b := newCircuitBreaker(testBreakerConfig())
+	tripBreaker(t, b)
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open = %v, want ErrCircuitOpen", err)
+	}
+
+	coolDown(b)
+	probe, err := b.allow()
+	if err != nil {
+		t.Fatalf("allow after cool down: %v", err)
+	}
+	if b.state != StateHalfOpen {
+		t.Fatalf("state = %v after cool down, want half-open", b.state)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second probe allowed beyond HalfOpenMaxRequests: %v", err)
+	}
+
+	// 取消的探测释放名额
+	b.release(probe)
+	probe, err = b.allow()
+	if err != nil {
+		t.Fatalf("allow after release: %v", err)
+	}
+
+	// 探测失败重新打开
+	b.record(probe, false)
+	if b.state != StateOpen {
+		t.Fatalf("state = %v after failed probe, want open", b.state)
+	}
+
+	// 探测成功关闭并清空统计
+	coolDown(b)
+	probe, _ = b.allow()
+	b.record(probe, true)
+	if b.state != StateClosed || b.requests != 0 || b.failures != 0 {
+		t.Fatalf("state = %v, requests = %d, failures = %d after successful probe; want closed with no stats",
+			b.state, b.requests, b.failures)
+	}
+}
+
+func TestBreakerGeneration(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, b *circuitBreaker)
+		want BreakerState
+	}{
+		{
+			// 打开之前放行的慢请求在半开状态下返回，不能当作探测结果关闭熔断器
+			name: "pre-trip success is not a probe",
+			run: func(t *testing.T, b *circuitBreaker) {
+				slow, _ := b.allow()
+				tripBreaker(t, b)
+				coolDown(b)
+				b.record(slow, true)
+			},
+			want: StateHalfOpen,
+		},
+		{
+			// 迟到的旧请求不能占用或释放探测名额
+			name: "pre-trip release keeps probe slot",
+			run: func(t *testing.T, b *circuitBreaker) {
+				slow, _ := b.allow()
+				tripBreaker(t, b)
+				coolDown(b)
+				if _, err := b.allow(); err != nil {
+					t.Fatalf("probe: %v", err)
+				}
+				b.release(slow)
+				if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+					t.Fatalf("stale release freed a probe slot: %v", err)
+				}
+			},
+			want: StateHalfOpen,
+		},
+		{
+			// 探测成功关闭后，迟到的探测失败不会再次打开熔断器
+			name: "late probe failure after reset",
+			run: func(t *testing.T, b *circuitBreaker) {
+				b.config.HalfOpenMaxRequests = 2
+				tripBreaker(t, b)
+				coolDown(b)
+				first, _ := b.allow()
+				second, _ := b.allow()
+				b.record(first, true)
+				b.record(second, false)
+			},
+			want: StateClosed,
+		},
+		{
+			// 关闭状态下放行的请求在熔断器打开后返回，不计入新的统计
+			name: "pre-trip failure after reset",
+			run: func(t *testing.T, b *circuitBreaker) {
+				slow, _ := b.allow()
+				tripBreaker(t, b)
+				coolDown(b)
+				probe, _ := b.allow()
+				b.record(probe, true)
+				b.record(slow, false)
+				if b.requests != 0 {
+					t.Fatalf("stale result counted: requests = %d", b.requests)
+				}
+			},
+			want: StateClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(testBreakerConfig())
+			tt.run(t, b)
+			if b.state != tt.want {
+				t.Errorf("state = %v, want %v", b.state, tt.want)
+			}
+		})
+	}
+}
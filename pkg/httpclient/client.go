@@ -2,129 +2,438 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
+	"MicroService/pkg/logging"
+	"MicroService/pkg/metrics"
 	"MicroService/pkg/model" // 假设你的 model 包路径
+	"MicroService/pkg/tracing"
+)
+
+var (
+	retriesTotal = metrics.NewCounterVec("httpclient_retries_total",
+		"Total number of retried outbound HTTP requests, by target host.", "host")
+	timeoutsTotal = metrics.NewCounterVec("httpclient_timeouts_total",
+		"Total number of outbound HTTP request attempts that timed out, by target host.", "host")
+	circuitOpenTotal = metrics.NewCounterVec("httpclient_circuit_open_rejections_total",
+		"Total number of outbound HTTP requests rejected by an open circuit breaker, by target host.", "host")
 )
 
 // Client 是一个通用的 HTTP 客户端，用于服务之间的通信
 type Client struct {
 	httpClient *http.Client
+	config     Config // 创建时的配置，用于 context 版本的请求方法
+
+	budget *retryBudget // 客户端级别的重试预算
+
+	breakerConfig BreakerConfig
+	breakers      map[string]*circuitBreaker // 按主机（host:port）划分的熔断器
+	breakersMu    sync.Mutex
 }
 
 // Config 定义 HTTP 客户端的配置
 type Config struct {
 	Timeout    time.Duration // 请求超时时间
-	MaxRetries int           // 最大重试次数
-	RetryDelay time.Duration // 重试间隔
+	MaxRetries int           // 最大重试次数，未设置 RetryPolicy 时生效
+	RetryDelay time.Duration // 首次重试的退避上限，未设置 RetryPolicy 时生效
+	Breaker    BreakerConfig // 熔断器配置，仅在 NewClient 时生效
+
+	RetryPolicy RetryPolicy       // 重试策略，MaxAttempts 为 0 时由 MaxRetries 和 RetryDelay 生成
+	RetryBudget RetryBudgetConfig // 重试预算，仅在 NewClient 时生效
+
+	TLS *tls.Config // https 请求使用的 TLS 配置（CA、客户端证书），为 nil 时使用默认传输层
+}
+
+var (
+	defaultTLSMu sync.RWMutex
+	defaultTLS   *tls.Config
+)
+
+// SetDefaultTLSConfig 设置 DefaultConfig 使用的 TLS 配置，服务启动时根据 TLS 环境变量调用一次
+func SetDefaultTLSConfig(config *tls.Config) {
+	defaultTLSMu.Lock()
+	defer defaultTLSMu.Unlock()
+	defaultTLS = config
 }
 
 // DefaultConfig 返回默认的 HTTP 客户端配置
 func DefaultConfig() Config {
+	defaultTLSMu.RLock()
+	defer defaultTLSMu.RUnlock()
 	return Config{
 		Timeout:    5 * time.Second,        // 默认超时 5 秒
 		MaxRetries: 2,                      // 默认重试 2 次
 		RetryDelay: 500 * time.Millisecond, // 默认重试间隔 500 毫秒
+		Breaker:    DefaultBreakerConfig(),
+
+		RetryBudget: DefaultRetryBudgetConfig(),
+		TLS:         defaultTLS,
 	}
 }
 
 // NewClient 创建一个新的 HTTP 客户端
 func NewClient(config Config) *Client {
+	httpClient := &http.Client{
+		Timeout: config.Timeout,
+	}
+	if config.TLS != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config.TLS.Clone()
+		httpClient.Transport = transport
+	}
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: config.Timeout,
-		},
+		httpClient:    httpClient,
+		config:        config,
+		budget:        newRetryBudget(config.RetryBudget),
+		breakerConfig: config.Breaker,
+		breakers:      make(map[string]*circuitBreaker),
+	}
+}
+
+// Request 描述一次 HTTP 请求
+type Request struct {
+	Method string
+	URL    string
+	Query  url.Values  // 追加到 URL 上的查询参数
+	Header http.Header // 自定义请求头
+	Body   interface{} // 非 nil 时编码为 JSON 请求体
+
+	// Idempotent 标记 POST、PATCH 等请求可以安全重试（重复执行不会产生副作用）
+	// 带有 Idempotency-Key 头的请求同样视为幂等
+	Idempotent bool
+}
+
+// Response 是一次成功（2xx）请求的响应
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// DecodeJSON 将响应体解码为 JSON
+func (r *Response) DecodeJSON(v interface{}) error {
+	if v == nil || len(r.Body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// RequestOption 用于定制单次请求
+type RequestOption func(*Request)
+
+// WithHeader 设置请求头
+func WithHeader(key, value string) RequestOption {
+	return func(r *Request) {
+		if r.Header == nil {
+			r.Header = http.Header{}
+		}
+		r.Header.Set(key, value)
+	}
+}
+
+// WithBearerToken 设置 Authorization: Bearer 头，token 为空时不设置
+func WithBearerToken(token string) RequestOption {
+	return func(r *Request) {
+		if token != "" {
+			WithHeader("Authorization", "Bearer "+token)(r)
+		}
 	}
 }
 
+// WithQuery 追加查询参数
+func WithQuery(key, value string) RequestOption {
+	return func(r *Request) {
+		if r.Query == nil {
+			r.Query = url.Values{}
+		}
+		r.Query.Add(key, value)
+	}
+}
+
+// WithIdempotent 标记请求为幂等，允许在服务端可能已处理后重试
+func WithIdempotent() RequestOption {
+	return func(r *Request) {
+		r.Idempotent = true
+	}
+}
+
+// Do 发送请求，失败时按客户端配置重试；ctx 取消或超时后立即停止重试
+// 非 2xx 响应和请求失败均返回 *Error，可通过 errors.As 获取状态码等信息
+func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+	return c.do(ctx, req, c.config)
+}
+
+// GetJSON 发送 GET 请求，响应体为 JSON 格式
+func (c *Client) GetJSON(ctx context.Context, url string, response interface{}, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodGet, url, nil, response, opts)
+}
+
+// PostJSON 发送 POST 请求，请求体和响应体为 JSON 格式
+func (c *Client) PostJSON(ctx context.Context, url string, request, response interface{}, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodPost, url, request, response, opts)
+}
+
+// PutJSON 发送 PUT 请求，请求体和响应体为 JSON 格式
+func (c *Client) PutJSON(ctx context.Context, url string, request, response interface{}, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodPut, url, request, response, opts)
+}
+
+// PatchJSON 发送 PATCH 请求，请求体和响应体为 JSON 格式
+func (c *Client) PatchJSON(ctx context.Context, url string, request, response interface{}, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodPatch, url, request, response, opts)
+}
+
+// DeleteJSON 发送 DELETE 请求，响应体为 JSON 格式
+func (c *Client) DeleteJSON(ctx context.Context, url string, response interface{}, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodDelete, url, nil, response, opts)
+}
+
 // Post 发送 POST 请求，请求体和响应体为 JSON 格式
+// Deprecated: 使用 PostJSON，以便传递 context
 func (c *Client) Post(url string, request interface{}, response interface{}, config Config) error {
-	reqBody, err := json.Marshal(request)
+	resp, err := c.do(context.Background(), &Request{Method: http.MethodPost, URL: url, Body: request}, config)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %v", err)
+		return err
 	}
+	return resp.DecodeJSON(response)
+}
+
+// Get 发送 GET 请求，响应体为 JSON 格式
+// Deprecated: 使用 GetJSON，以便传递 context
+func (c *Client) Get(url string, response interface{}, config Config) error {
+	resp, err := c.do(context.Background(), &Request{Method: http.MethodGet, URL: url}, config)
+	if err != nil {
+		return err
+	}
+	return resp.DecodeJSON(response)
+}
+
+// doJSON 构造请求并将响应解码到 response
+func (c *Client) doJSON(ctx context.Context, method, url string, request, response interface{}, opts []RequestOption) error {
+	req := &Request{Method: method, URL: url, Body: request}
+	for _, opt := range opts {
+		opt(req)
+	}
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	return resp.DecodeJSON(response)
+}
 
-	var lastErr error
-	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+// do 按给定配置发送请求，并根据重试策略和重试预算决定是否重试
+func (c *Client) do(ctx context.Context, req *Request, config Config) (*Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = json.Marshal(req.Body)
 		if err != nil {
-			return fmt.Errorf("failed to create request: %v", err)
+			return nil, fmt.Errorf("failed to marshal request: %v", err)
 		}
+	}
+
+	target, err := buildURL(req.URL, req.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	host := hostOf(target)
+
+	policy := config.retryPolicy()
+	if retriesDisabled(ctx) {
+		policy.MaxAttempts = 1
+	}
+	idempotent := req.Idempotent || isIdempotentMethod(req.Method) || req.Header.Get("Idempotency-Key") != ""
+	start := time.Now()
+	c.budget.deposit()
+
+	for attempt := 1; ; attempt++ {
+		resp, failure := c.tracedAttempt(ctx, req.Method, target, host, attempt, req.Header, reqBody)
+		if failure == nil {
+			return resp, nil
+		}
+		if failure.Timeout {
+			timeoutsTotal.Inc(host)
+		}
+
+		if errors.Is(failure, ErrCircuitOpen) {
+			circuitOpenTotal.Inc(host)
+			return nil, failure // 熔断器打开时不再重试
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			failure.Timeout = failure.Timeout || errors.Is(ctxErr, context.DeadlineExceeded)
+			return nil, failure
+		}
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(failure, idempotent) {
+			return nil, failure
+		}
+
+		delay := policy.backoff(attempt)
+		if policy.RespectRetryAfter && failure.retryAfter > delay {
+			delay = failure.retryAfter
+		}
+		if policy.MaxElapsedTime > 0 && time.Since(start)+delay > policy.MaxElapsedTime {
+			return nil, failure
+		}
+		if !c.budget.withdraw() {
+			return nil, fmt.Errorf("retry budget exhausted: %w", failure)
+		}
+		retriesTotal.Inc(host)
+		if err := sleepContext(ctx, delay); err != nil {
+			failure.Err = fmt.Errorf("%w while waiting to retry (last error: %v)", err, failure.Err)
+			failure.Timeout = failure.Timeout || errors.Is(err, context.DeadlineExceeded)
+			return nil, failure
+		}
+	}
+}
+
+// tracedAttempt 为单次请求创建 client span，每次重试都是独立的 span
+func (c *Client) tracedAttempt(ctx context.Context, method, target, host string, attempt int, header http.Header, body []byte) (*Response, *Error) {
+	ctx, span := tracing.Start(ctx, method+" "+host, tracing.SpanKindClient)
+	defer span.End()
+	span.SetAttribute("http.request.method", method)
+	span.SetAttribute("url.full", target)
+	span.SetAttribute("server.address", host)
+	if attempt > 1 {
+		span.SetAttribute("http.request.resend_count", attempt-1)
+	}
+
+	resp, failure := c.attempt(ctx, method, target, header, body)
+	if failure == nil {
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+		return resp, nil
+	}
+	failure.Method, failure.URL, failure.Attempts = method, target, attempt
+	if failure.StatusCode != 0 {
+		span.SetAttribute("http.response.status_code", failure.StatusCode)
+	}
+	span.RecordError(failure)
+	return nil, failure
+}
+
+// attempt 发送单次请求，读取并关闭响应体；失败时返回分类后的 *Error
+func (c *Client) attempt(ctx context.Context, method, target string, header http.Header, body []byte) (*Response, *Error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bodyReader)
+	if err != nil {
+		return nil, &Error{Err: fmt.Errorf("failed to create request: %w", err)}
+	}
+	for key, values := range header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+	tracing.Inject(ctx, req.Header)
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" && req.Header.Get(logging.RequestIDHeader) == "" {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", "application/json")
+	}
 
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("request failed: %v", err)
-			if attempt < config.MaxRetries {
-				time.Sleep(config.RetryDelay)
-				continue
-			}
-			return lastErr
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			var errorResp model.ErrorResponse
-			if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
-				lastErr = fmt.Errorf("server returned error: %s (status: %d)", errorResp.Error, resp.StatusCode)
-			} else {
-				lastErr = fmt.Errorf("request failed with status: %d", resp.StatusCode)
-			}
-			if attempt < config.MaxRetries {
-				time.Sleep(config.RetryDelay)
-				continue
-			}
-			return lastErr
-		}
-
-		if response != nil {
-			if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
-				return fmt.Errorf("failed to decode response: %v", err)
-			}
+	resp, err := c.execute(req)
+	if err != nil {
+		if errors.Is(err, ErrCircuitOpen) {
+			return nil, &Error{Err: err}
 		}
-		return nil
+		return nil, classifyNetworkError(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, classifyNetworkError(fmt.Errorf("failed to read response: %w", err))
 	}
-	return lastErr
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		failure := &Error{
+			StatusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+		var errorResp model.ErrorResponse
+		if err := json.Unmarshal(data, &errorResp); err == nil && errorResp.Error != "" {
+			failure.Response = &errorResp
+		}
+		return nil, failure
+	}
+
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       data,
+	}, nil
 }
 
-// Get 发送 GET 请求，响应体为 JSON 格式
-func (c *Client) Get(url string, response interface{}, config Config) error {
-	var lastErr error
-	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
-		resp, err := c.httpClient.Get(url)
-		if err != nil {
-			lastErr = fmt.Errorf("request failed: %v", err)
-			if attempt < config.MaxRetries {
-				time.Sleep(config.RetryDelay)
-				continue
-			}
-			return lastErr
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			var errorResp model.ErrorResponse
-			if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
-				lastErr = fmt.Errorf("server returned error: %s (status: %d)", errorResp.Error, resp.StatusCode)
-			} else {
-				lastErr = fmt.Errorf("request failed with status: %d", resp.StatusCode)
-			}
-			if attempt < config.MaxRetries {
-				time.Sleep(config.RetryDelay)
-				continue
-			}
-			return lastErr
-		}
-
-		if response != nil {
-			if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
-				return fmt.Errorf("failed to decode response: %v", err)
-			}
+// execute 发送单次请求，并在启用熔断时检查和记录目标主机的熔断器
+// 网络错误和 5xx 响应计为失败，调用方取消的请求不计入
+func (c *Client) execute(req *http.Request) (*http.Response, error) {
+	if !c.breakerConfig.Enabled {
+		return c.httpClient.Do(req)
+	}
+	breaker := c.breakerFor(req.URL.Host)
+	generation, err := breaker.allow()
+	if err != nil {
+		return nil, fmt.Errorf("%w for %s", err, req.URL.Host)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil && req.Context().Err() != nil {
+		breaker.release(generation)
+		return resp, err
+	}
+	breaker.record(generation, err == nil && resp.StatusCode < http.StatusInternalServerError)
+	return resp, err
+}
+
+// buildURL 将查询参数追加到 URL 上
+func buildURL(rawURL string, query url.Values) (string, error) {
+	if len(query) == 0 {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for key, values := range query {
+		for _, v := range values {
+			q.Add(key, v)
 		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// hostOf 返回 URL 中的 host:port，用作指标标签
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Host
+}
+
+// sleepContext 等待指定时间，ctx 结束时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
 		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return lastErr
 }
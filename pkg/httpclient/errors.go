@@ -0,0 +1,91 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"MicroService/pkg/model"
+)
+
+// Error 是 HTTP 客户端返回的结构化错误，可通过 errors.As 获取
+type Error struct {
+	Method     string               // 请求方法
+	URL        string               // 请求地址
+	StatusCode int                  // 服务端返回的状态码，未收到响应时为 0
+	Response   *model.ErrorResponse // 解码后的错误响应体，无法解码时为 nil
+	Attempts   int                  // 已尝试的次数
+	Network    bool                 // 网络错误（未收到响应）
+	Timeout    bool                 // 请求超时
+	Err        error                // 底层错误
+
+	retryAfter time.Duration // 响应中的 Retry-After
+	notSent    bool          // 连接未建立，请求一定未被发送
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	var msg string
+	switch {
+	case e.Response != nil && e.Response.Error != "":
+		msg = fmt.Sprintf("server returned error: %s (status: %d)", e.Response.Error, e.StatusCode)
+	case e.StatusCode != 0:
+		msg = fmt.Sprintf("request failed with status: %d", e.StatusCode)
+	default:
+		msg = fmt.Sprintf("request failed: %v", e.Err)
+	}
+	if e.Attempts > 1 {
+		msg = fmt.Sprintf("%s after %d attempts", msg, e.Attempts)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, msg)
+}
+
+// Unwrap 返回底层错误，支持 errors.Is(err, context.Canceled)、errors.Is(err, ErrCircuitOpen) 等判断
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode 返回错误中的 HTTP 状态码，不是服务端响应错误时返回 0
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound 判断错误是否为服务端返回的 404
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsTimeout 判断错误是否为请求超时（包括 context 超时）
+func IsTimeout(err error) bool {
+	var e *Error
+	if errors.As(err, &e) && e.Timeout {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// IsNotSent 判断请求是否一定没有发送到目标：连接未建立或熔断器打开
+// 非幂等请求只有在这种情况下才能安全地发送到其他节点
+func IsNotSent(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.notSent || errors.Is(err, ErrCircuitOpen)
+}
+
+// IsUnavailable 判断目标是否不可用：网络错误、超时、熔断或 5xx 响应
+// 调用方可据此决定是否故障转移到其他节点
+func IsUnavailable(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Network || e.Timeout || errors.Is(err, ErrCircuitOpen) || e.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,217 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy 定义请求失败后的重试策略
+type RetryPolicy struct {
+	MaxAttempts          int           // 最大尝试次数（包括首次请求）
+	RetryableStatusCodes []int         // 可重试的响应状态码
+	RetryOnNetworkError  bool          // 连接失败、连接重置等网络错误是否重试
+	RetryOnTimeout       bool          // 超时是否重试
+	InitialBackoff       time.Duration // 首次重试的退避上限
+	MaxBackoff           time.Duration // 单次退避的最大值
+	Multiplier           float64       // 退避上限的增长倍数
+	MaxElapsedTime       time.Duration // 从首次请求开始允许的最长总耗时，0 表示不限制
+	RespectRetryAfter    bool          // 是否遵循响应中的 Retry-After 头
+	RetryNonIdempotent   bool          // 非幂等请求（POST、PATCH）在可能已被服务端处理后是否仍然重试
+}
+
+// DefaultRetryPolicy 返回默认的重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryOnNetworkError: true,
+		RetryOnTimeout:      true,
+		InitialBackoff:      500 * time.Millisecond,
+		MaxBackoff:          5 * time.Second,
+		Multiplier:          2,
+		MaxElapsedTime:      15 * time.Second,
+		RespectRetryAfter:   true,
+	}
+}
+
+// retryPolicy 返回配置中的重试策略；未显式设置时根据 MaxRetries 和 RetryDelay 生成
+func (c Config) retryPolicy() RetryPolicy {
+	if c.RetryPolicy.MaxAttempts > 0 {
+		return c.RetryPolicy
+	}
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = c.MaxRetries + 1
+	if c.RetryDelay > 0 {
+		policy.InitialBackoff = c.RetryDelay
+		if policy.MaxBackoff < c.RetryDelay {
+			policy.MaxBackoff = c.RetryDelay
+		}
+	}
+	return policy
+}
+
+// noRetryKey 是标记 context 中的请求不重试的键
+type noRetryKey struct{}
+
+// WithoutRetries 返回的 context 发起的请求只尝试一次，不受客户端重试策略影响
+// 用于对冲请求等调用方自行处理失败的场景
+func WithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// retriesDisabled 判断 context 是否禁止重试
+func retriesDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRetryKey{}).(bool)
+	return disabled
+}
+
+// backoff 计算第 retry 次重试前的等待时间（指数退避 + 全抖动）
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	ceiling := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && ceiling > float64(p.MaxBackoff) {
+		ceiling = float64(p.MaxBackoff)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// retryableStatus 判断状态码是否可重试
+func (p RetryPolicy) retryableStatus(code int) bool {
+	for _, c := range p.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRetry 根据错误分类和请求是否幂等判断是否重试
+func (p RetryPolicy) shouldRetry(failure *Error, idempotent bool) bool {
+	switch {
+	case failure.StatusCode != 0:
+		if !p.retryableStatus(failure.StatusCode) {
+			return false
+		}
+		// 429 和 503 表示服务端未处理请求，非幂等请求也可以安全重试
+		if failure.StatusCode == http.StatusTooManyRequests || failure.StatusCode == http.StatusServiceUnavailable {
+			return true
+		}
+		return idempotent || p.RetryNonIdempotent
+	case failure.Timeout:
+		return p.RetryOnTimeout && (idempotent || p.RetryNonIdempotent)
+	case failure.Network:
+		if !p.RetryOnNetworkError {
+			return false
+		}
+		// 连接未建立时请求一定没有被发送，总是可以安全重试
+		return failure.notSent || idempotent || p.RetryNonIdempotent
+	default:
+		return false
+	}
+}
+
+// classifyNetworkError 对传输层错误进行分类
+func classifyNetworkError(err error) *Error {
+	failure := &Error{Err: err, Network: true}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		failure.Timeout = true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		failure.notSent = true
+	}
+	return failure
+}
+
+// parseRetryAfter 解析 Retry-After 头（秒数或 HTTP 日期）
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// isIdempotentMethod 判断 HTTP 方法是否幂等
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryBudgetConfig 定义客户端级别的重试预算
+// 每个请求存入 TokenRatio 个令牌，每次重试消耗 1 个令牌，令牌上限为 MaxTokens
+// 下游故障时重试次数最多约为请求数的 TokenRatio 倍，避免重试风暴放大故障
+type RetryBudgetConfig struct {
+	MaxTokens  float64
+	TokenRatio float64
+}
+
+// DefaultRetryBudgetConfig 返回默认的重试预算配置
+func DefaultRetryBudgetConfig() RetryBudgetConfig {
+	return RetryBudgetConfig{
+		MaxTokens:  10,
+		TokenRatio: 0.2,
+	}
+}
+
+// retryBudget 是重试预算的令牌桶
+type retryBudget struct {
+	config RetryBudgetConfig
+	mu     sync.Mutex
+	tokens float64
+}
+
+func newRetryBudget(config RetryBudgetConfig) *retryBudget {
+	if config.MaxTokens <= 0 {
+		config = DefaultRetryBudgetConfig()
+	}
+	return &retryBudget{config: config, tokens: config.MaxTokens}
+}
+
+// deposit 每次发起新请求时存入令牌
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.config.MaxTokens, b.tokens+b.config.TokenRatio)
+}
+
+// withdraw 尝试为一次重试消耗令牌，预算不足时返回 false
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,202 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		retry   int
+		ceiling time.Duration
+	}{
+		{"first retry", RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2}, 1, 100 * time.Millisecond},
+		{"grows by multiplier", RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2}, 3, 400 * time.Millisecond},
+		{"capped by max backoff", RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 250 * time.Millisecond, Multiplier: 2}, 5, 250 * time.Millisecond},
+		{"multiplier below one is constant", RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 0.5}, 4, 100 * time.Millisecond},
+		{"zero initial backoff", RetryPolicy{Multiplier: 2}, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 200; i++ {
+				if d := tt.policy.backoff(tt.retry); d < 0 || d > tt.ceiling {
+					t.Fatalf("backoff(%d) = %v, want within [0, %v]", tt.retry, d, tt.ceiling)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		min, max time.Duration
+	}{
+		{"empty", "", 0, 0},
+		{"seconds", "3", 3 * time.Second, 3 * time.Second},
+		{"zero seconds", "0", 0, 0},
+		{"negative seconds", "-5", 0, 0},
+		{"invalid", "soon", 0, 0},
+		{"http date", time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{"past http date", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := parseRetryAfter(tt.value); d < tt.min || d > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %v, want within [%v, %v]", tt.value, d, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	noNetwork := policy
+	noNetwork.RetryOnNetworkError = false
+	nonIdempotent := policy
+	nonIdempotent.RetryNonIdempotent = true
+
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		failure    *Error
+		idempotent bool
+		want       bool
+	}{
+		{"retryable status", policy, &Error{StatusCode: http.StatusBadGateway}, true, true},
+		{"non-retryable status", policy, &Error{StatusCode: http.StatusInternalServerError}, true, false},
+		{"client error", policy, &Error{StatusCode: http.StatusBadRequest}, true, false},
+		{"502 non-idempotent", policy, &Error{StatusCode: http.StatusBadGateway}, false, false},
+		{"502 non-idempotent allowed", nonIdempotent, &Error{StatusCode: http.StatusBadGateway}, false, true},
+		{"429 non-idempotent", policy, &Error{StatusCode: http.StatusTooManyRequests}, false, true},
+		{"503 non-idempotent", policy, &Error{StatusCode: http.StatusServiceUnavailable}, false, true},
+		{"timeout idempotent", policy, &Error{Network: true, Timeout: true}, true, true},
+		{"timeout non-idempotent", policy, &Error{Network: true, Timeout: true}, false, false},
+		{"network idempotent", policy, &Error{Network: true}, true, true},
+		{"network non-idempotent", policy, &Error{Network: true}, false, false},
+		{"connection refused non-idempotent", policy, &Error{Network: true, notSent: true}, false, true},
+		{"network retries disabled", noNetwork, &Error{Network: true, notSent: true}, true, false},
+		{"unclassified error", policy, &Error{Err: errors.New("boom")}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.shouldRetry(tt.failure, tt.idempotent); got != tt.want {
+				t.Errorf("shouldRetry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := newRetryBudget(RetryBudgetConfig{MaxTokens: 2, TokenRatio: 0.5})
+	for i := 0; i < 2; i++ {
+		if !b.withdraw() {
+			t.Fatalf("withdraw %d failed with a full budget", i+1)
+		}
+	}
+	if b.withdraw() {
+		t.Fatal("withdraw succeeded with an empty budget")
+	}
+	// 两个请求存入一个令牌
+	b.deposit()
+	if b.withdraw() {
+		t.Fatal("withdraw succeeded with half a token")
+	}
+	b.deposit()
+	if !b.withdraw() {
+		t.Fatal("withdraw failed after two deposits")
+	}
+	// 存入的令牌不超过上限
+	for i := 0; i < 10; i++ {
+		b.deposit()
+	}
+	if b.tokens != 2 {
+		t.Errorf("tokens = %v, want capped at 2", b.tokens)
+	}
+
+	if b := newRetryBudget(RetryBudgetConfig{}); b.config != DefaultRetryBudgetConfig() || b.tokens != b.config.MaxTokens {
+		t.Errorf("zero config budget = %+v, tokens %v; want the default budget, full", b.config, b.tokens)
+	}
+}
+
+// newTestClient 创建不启用熔断、退避很短的测试客户端
+func newTestClient(policy RetryPolicy, budget RetryBudgetConfig) *Client {
+	policy.RetryableStatusCodes = DefaultRetryPolicy().RetryableStatusCodes
+	policy.InitialBackoff = time.Millisecond
+	policy.Multiplier = 1
+	return NewClient(Config{Timeout: 5 * time.Second, RetryPolicy: policy, RetryBudget: budget})
+}
+
+func TestDoRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/retry-after":
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		path      string
+		policy    RetryPolicy
+		budget    RetryBudgetConfig
+		wantCalls int32
+		wantErr   string
+		minDelay  time.Duration
+	}{
+		{"retries until max attempts", context.Background(), "/fail",
+			RetryPolicy{MaxAttempts: 3}, RetryBudgetConfig{}, 3, "502", 0},
+		{"without retries", WithoutRetries(context.Background()), "/fail",
+			RetryPolicy{MaxAttempts: 3}, RetryBudgetConfig{}, 1, "502", 0},
+		{"retry after respected", context.Background(), "/retry-after",
+			RetryPolicy{MaxAttempts: 2, RespectRetryAfter: true}, RetryBudgetConfig{}, 2, "", time.Second},
+		{"retry after ignored", context.Background(), "/retry-after",
+			RetryPolicy{MaxAttempts: 2}, RetryBudgetConfig{}, 2, "", 0},
+		{"retry after exceeds max elapsed time", context.Background(), "/retry-after",
+			RetryPolicy{MaxAttempts: 2, RespectRetryAfter: true, MaxElapsedTime: 500 * time.Millisecond}, RetryBudgetConfig{}, 1, "503", 0},
+		{"budget exhausted", context.Background(), "/fail",
+			RetryPolicy{MaxAttempts: 3}, RetryBudgetConfig{MaxTokens: 1}, 2, "retry budget exhausted", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			client := newTestClient(tt.policy, tt.budget)
+			start := time.Now()
+			_, err := client.Do(tt.ctx, &Request{Method: http.MethodGet, URL: server.URL + tt.path})
+			elapsed := time.Since(start)
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Do error = %v, want containing %q", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("server received %d requests, want %d", got, tt.wantCalls)
+			}
+			if elapsed < tt.minDelay {
+				t.Errorf("Do returned after %v, want at least %v", elapsed, tt.minDelay)
+			}
+		})
+	}
+}
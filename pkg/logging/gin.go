@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"MicroService/pkg/model"
+	"MicroService/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader 是用于关联跨服务日志的请求头
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLen = 128 // 上游传入的请求 ID 超过该长度时重新生成
+
+type requestIDKey struct{}
+type routeKey struct{}
+
+// ContextWithRequestID 将请求 ID 放入 ctx
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext 返回 ctx 中的请求 ID，不存在时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RouteFromContext 返回 ctx 中的路由模板，不存在时返回空字符串
+func RouteFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// validRequestID 只接受长度合适的可打印 ASCII 字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID 读取或生成 X-Request-ID，写回响应头，并与路由一起放入 c.Request 的 context
+// 处理函数通过 logrus.WithContext(c.Request.Context()) 输出的日志会自动带上请求 ID 和路由，
+// 通过同一 ctx 发起的 httpclient 请求会将请求 ID 传给下游服务
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = util.GenerateUUID()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := ContextWithRequestID(c.Request.Context(), requestID)
+		if route := c.FullPath(); route != "" {
+			ctx = context.WithValue(ctx, routeKey{}, route)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// AccessLog 以结构化日志记录每个请求，替代 gin 默认的文本日志
+// 5xx 记为 error，4xx 记为 warn，其余记为 info
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := logrus.WithContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":    c.Request.Method,
+			"path":      c.Request.URL.Path,
+			"status":    status,
+			"latencyMs": float64(time.Since(start).Microseconds()) / 1000,
+			"clientIp":  c.ClientIP(),
+		})
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("errors", c.Errors.String())
+		}
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("Request completed")
+		case status >= http.StatusBadRequest:
+			entry.Warn("Request completed")
+		default:
+			entry.Info("Request completed")
+		}
+	}
+}
+
+// Recovery 捕获处理函数中的 panic，记录带堆栈的结构化日志并返回 500
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered interface{}) {
+		logrus.WithContext(c.Request.Context()).
+			WithField("stack", string(debug.Stack())).
+			Errorf("Panic recovered: %v", recovered)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:  http.StatusInternalServerError,
+			Error: "Internal server error.",
+		})
+	})
+}
+
+// NewRouter 创建带请求 ID、结构化访问日志和 panic 恢复中间件的 gin 路由
+func NewRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(RequestID(), AccessLog(), Recovery())
+	return router
+}
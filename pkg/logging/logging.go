@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"MicroService/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 支持的日志格式
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config 定义日志配置
+type Config struct {
+	Level  logrus.Level
+	Format string // json 或 text
+}
+
+// DefaultConfig 返回默认日志配置：info 级别、JSON 格式
+func DefaultConfig() Config {
+	return Config{
+		Level:  logrus.InfoLevel,
+		Format: FormatJSON,
+	}
+}
+
+// LoadConfig 从环境变量 LOG_LEVEL 和 LOG_FORMAT 加载日志配置，三个服务共用
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if levelStr := os.Getenv("LOG_LEVEL"); levelStr != "" {
+		if level, err := logrus.ParseLevel(levelStr); err == nil {
+			config.Level = level
+		} else {
+			logrus.Warnf("Invalid LOG_LEVEL: %s, using default: %s", levelStr, config.Level)
+		}
+	}
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		switch format = strings.ToLower(format); format {
+		case FormatJSON, FormatText:
+			config.Format = format
+		default:
+			logrus.Warnf("Invalid LOG_FORMAT: %s, using default: %s", format, config.Format)
+		}
+	}
+	return config
+}
+
+// serviceHook 为每条日志添加服务信息，以及 context 中的请求 ID、路由和链路 ID
+type serviceHook struct {
+	serviceName string
+	serviceID   atomic.Value // string，服务注册成功后才能确定
+}
+
+var hook = &serviceHook{}
+
+func init() {
+	hook.serviceID.Store("")
+}
+
+func (h *serviceHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *serviceHook) Fire(entry *logrus.Entry) error {
+	entry.Data["service"] = h.serviceName
+	if id := h.serviceID.Load().(string); id != "" {
+		entry.Data["serviceId"] = id
+	}
+	if entry.Context == nil {
+		return nil
+	}
+	if id := RequestIDFromContext(entry.Context); id != "" {
+		entry.Data["requestId"] = id
+	}
+	if route := RouteFromContext(entry.Context); route != "" {
+		entry.Data["route"] = route
+	}
+	if span := tracing.SpanFromContext(entry.Context); span != nil {
+		entry.Data["traceId"] = span.SpanContext().TraceID.String()
+	}
+	return nil
+}
+
+// Setup 配置全局 logrus：日志级别、格式，以及附加服务名的 hook
+// 非 debug 级别时将 gin 切换为 release 模式（除非设置了 GIN_MODE），避免输出非结构化的调试日志
+func Setup(config Config, serviceName string) {
+	logrus.SetLevel(config.Level)
+	if config.Format == FormatText {
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		logrus.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	}
+	hook.serviceName = serviceName
+	logrus.AddHook(hook)
+
+	if config.Level < logrus.DebugLevel && os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+}
+
+// SetServiceID 设置服务实例 ID，之后的日志均携带该 ID
+func SetServiceID(serviceID string) {
+	hook.serviceID.Store(serviceID)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	httpRequestsTotal = NewCounterVec("http_requests_total",
+		"Total number of HTTP requests handled, by method, route and status code.",
+		"method", "route", "status")
+	httpRequestDuration = NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency in seconds, by method and route.",
+		DefaultBuckets, "method", "route")
+)
+
+// GinMiddleware 记录每个路由的请求数和延迟
+// 路由使用 gin 注册时的路径模板，未匹配的请求记为 "unmatched"，避免标签基数失控
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequestsTotal.Inc(method, route, strconv.Itoa(c.Writer.Status()))
+		httpRequestDuration.Observe(time.Since(start).Seconds(), method, route)
+	}
+}
+
+// Handler 返回输出默认注册表的 /metrics 处理函数
+func Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", ContentType)
+		c.Status(200)
+		if err := Default.WriteText(c.Writer); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
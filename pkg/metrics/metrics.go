@@ -0,0 +1,364 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 指标类型，对应 Prometheus 文本格式中的 TYPE
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefaultBuckets 是默认的延迟直方图分桶（单位：秒）
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// sample 是一条带标签的指标样本
+type sample struct {
+	suffix      string // 指标名后缀，例如 "_bucket"
+	labelNames  []string
+	labelValues []string
+	value       float64
+}
+
+// metric 是注册表中的一个指标族
+type metric interface {
+	name() string
+	help() string
+	kind() string
+	collect() []sample
+}
+
+// Registry 保存所有指标并输出 Prometheus 文本格式
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry 创建指标注册表
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// Default 是进程级别的默认注册表
+var Default = NewRegistry()
+
+// getOrRegister 返回同名指标；不存在时注册新指标，类型不一致时 panic
+func (r *Registry) getOrRegister(m metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.metrics[m.name()]; ok {
+		if existing.kind() != m.kind() {
+			panic(fmt.Sprintf("metric %s already registered as %s", m.name(), existing.kind()))
+		}
+		return existing
+	}
+	r.metrics[m.name()] = m
+	return m
+}
+
+// snapshot 返回按名称排序的指标列表
+func (r *Registry) snapshot() []metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name() < list[j].name() })
+	return list
+}
+
+// desc 是指标族的公共描述
+type desc struct {
+	metricName string
+	metricHelp string
+	labelNames []string
+}
+
+func (d desc) name() string { return d.metricName }
+func (d desc) help() string { return d.metricHelp }
+
+// labelKey 将标签值拼接为 map 键
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// checkLabels 校验标签值数量
+func (d desc) checkLabels(values []string) {
+	if len(values) != len(d.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", d.metricName, len(d.labelNames), len(values)))
+	}
+}
+
+// CounterVec 是带标签的计数器
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*valueWithLabels
+}
+
+// valueWithLabels 是某组标签值对应的数值
+type valueWithLabels struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec 在注册表中创建（或获取已存在的）计数器
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return r.getOrRegister(&CounterVec{
+		desc:   desc{metricName: name, metricHelp: help, labelNames: labelNames},
+		values: make(map[string]*valueWithLabels),
+	}).(*CounterVec)
+}
+
+func (c *CounterVec) kind() string { return typeCounter }
+
+// Add 为指定标签值的计数器增加 delta（delta 必须非负）
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.checkLabels(labelValues)
+	key := labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &valueWithLabels{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+// Inc 为指定标签值的计数器加 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) collect() []sample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	samples := make([]sample, 0, len(c.values))
+	for _, v := range c.values {
+		samples = append(samples, sample{labelNames: c.labelNames, labelValues: v.labelValues, value: v.value})
+	}
+	return samples
+}
+
+// GaugeVec 是带标签的仪表盘
+type GaugeVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*valueWithLabels
+}
+
+// NewGaugeVec 在注册表中创建（或获取已存在的）仪表盘
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return r.getOrRegister(&GaugeVec{
+		desc:   desc{metricName: name, metricHelp: help, labelNames: labelNames},
+		values: make(map[string]*valueWithLabels),
+	}).(*GaugeVec)
+}
+
+func (g *GaugeVec) kind() string { return typeGauge }
+
+// Set 设置指定标签值的数值
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.checkLabels(labelValues)
+	key := labelKey(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	v, ok := g.values[key]
+	if !ok {
+		v = &valueWithLabels{labelValues: append([]string(nil), labelValues...)}
+		g.values[key] = v
+	}
+	v.value = value
+}
+
+// Delete 删除指定标签值的样本
+func (g *GaugeVec) Delete(labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.values, labelKey(labelValues))
+}
+
+func (g *GaugeVec) collect() []sample {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	samples := make([]sample, 0, len(g.values))
+	for _, v := range g.values {
+		samples = append(samples, sample{labelNames: g.labelNames, labelValues: v.labelValues, value: v.value})
+	}
+	return samples
+}
+
+// FuncMetric 是抓取时通过回调计算数值的指标，适合实例数等由其他数据结构派生的指标
+type FuncMetric struct {
+	desc
+	metricKind string
+	fn         func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc 在注册表中创建回调仪表盘；同名指标已存在时返回已有指标
+func (r *Registry) NewGaugeFunc(name, help string, labelNames []string, fn func(emit func(value float64, labelValues ...string))) *FuncMetric {
+	return r.getOrRegister(&FuncMetric{
+		desc:       desc{metricName: name, metricHelp: help, labelNames: labelNames},
+		metricKind: typeGauge,
+		fn:         fn,
+	}).(*FuncMetric)
+}
+
+// NewCounterFunc 在注册表中创建回调计数器，回调返回的数值必须单调递增
+func (r *Registry) NewCounterFunc(name, help string, labelNames []string, fn func(emit func(value float64, labelValues ...string))) *FuncMetric {
+	return r.getOrRegister(&FuncMetric{
+		desc:       desc{metricName: name, metricHelp: help, labelNames: labelNames},
+		metricKind: typeCounter,
+		fn:         fn,
+	}).(*FuncMetric)
+}
+
+func (f *FuncMetric) kind() string { return f.metricKind }
+
+func (f *FuncMetric) collect() []sample {
+	var samples []sample
+	f.fn(func(value float64, labelValues ...string) {
+		f.checkLabels(labelValues)
+		samples = append(samples, sample{
+			labelNames:  f.labelNames,
+			labelValues: append([]string(nil), labelValues...),
+			value:       value,
+		})
+	})
+	return samples
+}
+
+// HistogramVec 是带标签的直方图
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// histogramValue 是某组标签值对应的直方图数据
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64 // 每个分桶的累计计数
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec 在注册表中创建（或获取已存在的）直方图，buckets 为空时使用 DefaultBuckets
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return r.getOrRegister(&HistogramVec{
+		desc:    desc{metricName: name, metricHelp: help, labelNames: labelNames},
+		buckets: sorted,
+		values:  make(map[string]*histogramValue),
+	}).(*HistogramVec)
+}
+
+func (h *HistogramVec) kind() string { return typeHistogram }
+
+// Observe 记录一次观测值
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+	key := labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = v
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *HistogramVec) collect() []sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var samples []sample
+	bucketLabels := append(append([]string(nil), h.labelNames...), "le")
+	// 按标签组排序，组内保持 le 递增、+Inf、_sum、_count 的顺序
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := h.values[key]
+		for i, upper := range h.buckets {
+			samples = append(samples, sample{
+				suffix:      "_bucket",
+				labelNames:  bucketLabels,
+				labelValues: append(append([]string(nil), v.labelValues...), formatFloat(upper)),
+				value:       float64(v.counts[i]),
+			})
+		}
+		samples = append(samples,
+			sample{suffix: "_bucket", labelNames: bucketLabels, labelValues: append(append([]string(nil), v.labelValues...), "+Inf"), value: float64(v.count)},
+			sample{suffix: "_sum", labelNames: h.labelNames, labelValues: v.labelValues, value: v.sum},
+			sample{suffix: "_count", labelNames: h.labelNames, labelValues: v.labelValues, value: float64(v.count)},
+		)
+	}
+	return samples
+}
+
+// formatFloat 按 Prometheus 文本格式输出浮点数
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return fmt.Sprintf("%g", v)
+	}
+}
+
+// NewCounterVec 在默认注册表中创建计数器
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labelNames...)
+}
+
+// NewGaugeVec 在默认注册表中创建仪表盘
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return Default.NewGaugeVec(name, help, labelNames...)
+}
+
+// NewGaugeFunc 在默认注册表中创建回调仪表盘
+func NewGaugeFunc(name, help string, labelNames []string, fn func(emit func(value float64, labelValues ...string))) *FuncMetric {
+	return Default.NewGaugeFunc(name, help, labelNames, fn)
+}
+
+// NewCounterFunc 在默认注册表中创建回调计数器
+func NewCounterFunc(name, help string, labelNames []string, fn func(emit func(value float64, labelValues ...string))) *FuncMetric {
+	return Default.NewCounterFunc(name, help, labelNames, fn)
+}
+
+// NewHistogramVec 在默认注册表中创建直方图
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labelNames...)
+}
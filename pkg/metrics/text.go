@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ContentType 是 Prometheus 文本格式的 Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText 以 Prometheus 文本格式输出注册表中的所有指标
+func (r *Registry) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range r.snapshot() {
+		samples := m.collect()
+		// 直方图的样本已按标签组排好序，重新排序会打乱 le 的顺序
+		if m.kind() != typeHistogram {
+			sort.SliceStable(samples, func(i, j int) bool {
+				return labelKey(samples[i].labelValues) < labelKey(samples[j].labelValues)
+			})
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name(), escapeHelp(m.help()))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name(), m.kind())
+		for _, s := range samples {
+			bw.WriteString(m.name())
+			bw.WriteString(s.suffix)
+			writeLabels(bw, s.labelNames, s.labelValues)
+			bw.WriteByte(' ')
+			bw.WriteString(formatFloat(s.value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// writeLabels 输出 {name="value",...}
+func writeLabels(w *bufio.Writer, names, values []string) {
+	if len(names) == 0 {
+		return
+	}
+	w.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(name)
+		w.WriteString(`="`)
+		w.WriteString(escapeLabelValue(values[i]))
+		w.WriteByte('"')
+	}
+	w.WriteByte('}')
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "Total requests.", "method", "code")
+	requests.Inc("POST", "500")
+	requests.Add(2, "GET", "200")
+	r.NewGaugeVec("test_temperature", "Current \"temperature\"\nin C.", "room").Set(21.5, `a"b`)
+	latency := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{1, 0.1, 10}, "route")
+	latency.Observe(0.05, "/b")
+	latency.Observe(2, "/b")
+	latency.Observe(0.5, "/a")
+
+	var buf strings.Builder
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/a",le="0.1"} 0
+test_latency_seconds_bucket{route="/a",le="1"} 1
+test_latency_seconds_bucket{route="/a",le="10"} 1
+test_latency_seconds_bucket{route="/a",le="+Inf"} 1
+test_latency_seconds_sum{route="/a"} 0.5
+test_latency_seconds_count{route="/a"} 1
+test_latency_seconds_bucket{route="/b",le="0.1"} 1
+test_latency_seconds_bucket{route="/b",le="1"} 1
+test_latency_seconds_bucket{route="/b",le="10"} 2
+test_latency_seconds_bucket{route="/b",le="+Inf"} 2
+test_latency_seconds_sum{route="/b"} 2.05
+test_latency_seconds_count{route="/b"} 2
+# HELP test_requests_total Total requests.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",code="200"} 2
+test_requests_total{method="POST",code="500"} 1
+# HELP test_temperature Current "temperature"\nin C.
+# TYPE test_temperature gauge
+test_temperature{room="a\"b"} 21.5
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteText output mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// 注册中心管理接口的响应，注册中心和 registryctl 共用
+
+// NodeStatus 是单个注册中心节点的状态
+type NodeStatus struct {
+	Node             string    `json:"node"`
+	StartedAt        time.Time `json:"startedAt"`
+	Peers            []string  `json:"peers"`
+	Instances        int       `json:"instances"`
+	HealthyInstances int       `json:"healthyInstances"`
+	Services         int       `json:"services"` // 按 命名空间/服务名 计数
+	Maintenance      int       `json:"maintenance"`
+	Webhooks         int       `json:"webhooks"`
+	FiringAlerts     int       `json:"firingAlerts"`
+}
+
+// PeerStatus 是从应答节点查询到的对等节点状态，Reachable 为 false 时 Error 说明原因
+type PeerStatus struct {
+	Address   string      `json:"address"`
+	Reachable bool        `json:"reachable"`
+	LatencyMs float64     `json:"latencyMs,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Status    *NodeStatus `json:"status,omitempty"`
+}
+
+// InstanceChange 是目录导入引起的一项实例变更，更新时 Fields 列出变化的字段
+type InstanceChange struct {
+	Namespace   string   `json:"namespace"`
+	ServiceName string   `json:"serviceName"`
+	ServiceId   string   `json:"serviceId"`
+	Fields      []string `json:"fields,omitempty"`
+	LeaseToken  string   `json:"leaseToken,omitempty"` // 为快照中没有租约的新实例生成的租约
+}
+
+// ImportRejection 是目录导入中被准入策略或命名空间配额拒绝的实例
+type ImportRejection struct {
+	Namespace   string `json:"namespace"`
+	ServiceName string `json:"serviceName"`
+	ServiceId   string `json:"serviceId"`
+	Reason      string `json:"reason"`
+	Error       string `json:"error"`
+}
+
+// ImportResult 描述目录导入的变更，dryRun 时描述将要发生的变更
+type ImportResult struct {
+	Mode               string            `json:"mode"`
+	DryRun             bool              `json:"dryRun"`
+	Added              []InstanceChange  `json:"added"`
+	Updated            []InstanceChange  `json:"updated"`
+	Removed            []InstanceChange  `json:"removed"`
+	Unchanged          int               `json:"unchanged"`
+	Rejected           []ImportRejection `json:"rejected"`
+	MaintenanceStarted []string          `json:"maintenanceStarted"` // 命名空间/服务名
+	MaintenanceEnded   []string          `json:"maintenanceEnded"`
+}
@@ -2,15 +2,56 @@ package model
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"strconv"
 	"time"
 )
 
+// 实例状态
+const (
+	StatusUp           = "UP"             // 正常提供服务
+	StatusDown         = "DOWN"           // 实例自身报告不可用
+	StatusOutOfService = "OUT_OF_SERVICE" // 暂停接收流量（例如发布或排障）
+)
+
+// ValidStatus 判断实例状态是否合法
+func ValidStatus(status string) bool {
+	return status == StatusUp || status == StatusDown || status == StatusOutOfService
+}
+
+// 元数据限制
+const (
+	MaxMetadataEntries  = 64
+	MaxMetadataKeyLen   = 63
+	MaxMetadataValueLen = 256
+)
+
 type Service struct {
-	ServiceName   string    `json:"serviceName"` // 服务名称，例如 "time-service"
-	ServiceId     string    `json:"serviceId"`   // 服务实例唯一标识，建议使用 UUID
-	IpAddress     string    `json:"ipAddress"`   // 服务实例的 IP 地址
-	Port          int       `json:"port"`        // 服务实例的端口号
-	LastHeartbeat time.Time `json:"-"`           // 最后一次心跳时间，仅用于内部管理
+	Namespace     string            `json:"namespace,omitempty"` // 所属命名空间，空值视为 "default"
+	ServiceName   string            `json:"serviceName"`         // 服务名称，例如 "time-service"
+	ServiceId     string            `json:"serviceId"`           // 服务实例唯一标识，建议使用 UUID
+	IpAddress     string            `json:"ipAddress"`           // 服务实例的 IP 地址
+	Port          int               `json:"port"`                // 服务实例的端口号
+	Scheme        string            `json:"scheme,omitempty"`    // 访问协议，"http" 或 "https"，空值视为 http
+	Status        string            `json:"status,omitempty"`    // 实例状态，空值视为 UP
+	Metadata      map[string]string `json:"metadata,omitempty"`  // 实例元数据，例如版本、可用区
+	LastHeartbeat time.Time         `json:"-"`                   // 最后一次心跳时间，仅用于内部管理
+	LeaseToken    string            `json:"-"`                   // 注册时签发的租约令牌，心跳、状态变更和注销时必须携带
+}
+
+// IsUp 判断实例状态是否为 UP
+func (s Service) IsUp() bool {
+	return s.Status == "" || s.Status == StatusUp
+}
+
+// URL 根据实例的访问协议、地址和端口拼接请求地址，path 以 "/" 开头
+func (s Service) URL(path string) string {
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + net.JoinHostPort(s.IpAddress, strconv.Itoa(s.Port)) + path
 }
 
 func (s *Service) Validate() error {
@@ -23,8 +64,19 @@ func (s *Service) Validate() error {
 	if s.IpAddress == "" {
 		return errors.New("ipAddress is required")
 	}
-	if s.Port <= 0 {
-		return errors.New("port must be greater than 0")
+	if s.Port <= 0 || s.Port > 65535 {
+		return errors.New("port must be between 1 and 65535")
+	}
+	if len(s.Metadata) > MaxMetadataEntries {
+		return fmt.Errorf("metadata must not have more than %d entries", MaxMetadataEntries)
+	}
+	for k, v := range s.Metadata {
+		if k == "" || len(k) > MaxMetadataKeyLen || len(v) > MaxMetadataValueLen {
+			return fmt.Errorf("metadata key must be 1-%d bytes and value at most %d bytes: %q", MaxMetadataKeyLen, MaxMetadataValueLen, k)
+		}
+	}
+	if s.Scheme != "" && s.Scheme != "http" && s.Scheme != "https" {
+		return errors.New("scheme must be http or https")
 	}
 	return nil
 }
@@ -34,34 +86,50 @@ func (r *RegisterServiceRequest) ToService() Service {
 		ServiceId:   r.ServiceId,
 		IpAddress:   r.IpAddress,
 		Port:        r.Port,
+		Scheme:      r.Scheme,
+		Metadata:    r.Metadata,
 	}
 }
 
 // 通用的错误响应
 type ErrorResponse struct {
-	Code  int    `json:"code"`
-	Error string `json:"error"`
+	Code   int    `json:"code"`
+	Error  string `json:"error"`
+	Reason string `json:"reason,omitempty"` // 机器可读的原因码，例如注册准入被拒绝的原因
 }
 
 // 注册服务请求
+// 首次注册时 LeaseToken 可以为空（由注册中心生成）或由实例提议；重复注册和注销时必须与已签发的租约一致
 type RegisterServiceRequest struct {
-	ServiceName string `json:"serviceName" binding:"required"`
-	ServiceId   string `json:"serviceId" binding:"required"`
-	IpAddress   string `json:"ipAddress" binding:"required"`
-	Port        int    `json:"port" binding:"required"`
+	ServiceName string            `json:"serviceName" binding:"required"`
+	ServiceId   string            `json:"serviceId" binding:"required"`
+	IpAddress   string            `json:"ipAddress" binding:"required"`
+	Port        int               `json:"port" binding:"required"`
+	Scheme      string            `json:"scheme,omitempty"` // 实例以 HTTPS 监听时为 "https"
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	LeaseToken  string            `json:"leaseToken,omitempty"`
 }
 
 // 注册服务响应
 type RegisterServiceResponse struct {
-	Message string  `json:"message"`
-	Service Service `json:"service"`
+	Message    string  `json:"message"`
+	Service    Service `json:"service"`
+	LeaseToken string  `json:"leaseToken,omitempty"` // 仅注册成功时返回
 }
 
 // 心跳请求
 type HeartbeatRequest struct {
-	ServiceId string `json:"serviceId" binding:"required"`
-	IpAddress string `json:"ipAddress" binding:"required"`
-	Port      int    `json:"port" binding:"required"`
+	ServiceId  string `json:"serviceId" binding:"required"`
+	IpAddress  string `json:"ipAddress" binding:"required"`
+	Port       int    `json:"port" binding:"required"`
+	LeaseToken string `json:"leaseToken,omitempty"`
+}
+
+// 实例状态变更请求
+type StatusRequest struct {
+	ServiceId  string `json:"serviceId" binding:"required"`
+	Status     string `json:"status" binding:"required"`
+	LeaseToken string `json:"leaseToken,omitempty"`
 }
 
 // 心跳响应
@@ -72,10 +140,13 @@ type HeartbeatResponse struct {
 
 // 服务发现响应（单个实例）
 type DiscoveryResponse struct {
-	ServiceName string `json:"serviceName"`
-	ServiceId   string `json:"serviceId"`
-	IpAddress   string `json:"ipAddress"`
-	Port        int    `json:"port"`
+	Namespace   string            `json:"namespace,omitempty"`
+	ServiceName string            `json:"serviceName"`
+	ServiceId   string            `json:"serviceId"`
+	IpAddress   string            `json:"ipAddress"`
+	Port        int               `json:"port"`
+	Scheme      string            `json:"scheme,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
 // 服务发现响应（所有实例）
@@ -93,4 +164,29 @@ type GetDateTimeResponse struct {
 type GetInfoResponse struct {
 	Error  *string     `json:"error"`
 	Result interface{} `json:"result"`
+	Stale  bool        `json:"stale,omitempty"` // 为 true 表示依赖实例来自最后已知目录（注册中心不可达）
+}
+
+// 服务实例视图，用于实例列表查询
+type InstanceView struct {
+	Namespace     string            `json:"namespace"`
+	ServiceName   string            `json:"serviceName"`
+	ServiceId     string            `json:"serviceId"`
+	IpAddress     string            `json:"ipAddress"`
+	Port          int               `json:"port"`
+	Scheme        string            `json:"scheme"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Status        string            `json:"status"`
+	Healthy       bool              `json:"healthy"`
+	LastHeartbeat time.Time         `json:"lastHeartbeat"`
+}
+
+// 服务发现响应（实例列表，支持分页）
+type DiscoveryInstancesResponse struct {
+	ServiceName string                   `json:"serviceName,omitempty"`
+	Total       int                      `json:"total"`
+	Page        int                      `json:"page"`
+	PageSize    int                      `json:"pageSize"`
+	Instances   []map[string]interface{} `json:"instances"`
+	At          *time.Time               `json:"at,omitempty"` // 按 ?at= 重建的历史目录的时刻
 }
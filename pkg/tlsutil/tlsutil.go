@@ -0,0 +1,189 @@
+// Package tlsutil 提供三个服务共用的 TLS / mTLS 配置
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"MicroService/pkg/httpclient"
+)
+
+// 客户端证书校验模式
+const (
+	ClientAuthNone     = "none"     // 不要求客户端证书
+	ClientAuthOptional = "optional" // 客户端提供证书时校验
+	ClientAuthRequire  = "require"  // 必须提供由 ClientCAFile 签发的证书（mTLS）
+)
+
+// 服务实例的访问协议
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+)
+
+// Config 定义 TLS 配置
+// 服务端：配置 CertFile 和 KeyFile 后以 HTTPS 监听；配置 ClientCAFile 后校验客户端证书
+// 客户端：CAFile 用于校验服务端证书（为空时使用系统根证书），ClientCertFile 和 ClientKeyFile 用于 mTLS
+type Config struct {
+	CertFile     string // 服务端证书
+	KeyFile      string // 服务端私钥
+	ClientCAFile string // 校验客户端证书的 CA
+	ClientAuth   string // 客户端证书校验模式：none、optional、require
+
+	CAFile         string // 校验服务端证书的 CA
+	ClientCertFile string // 出站请求使用的客户端证书，为空时使用服务端证书
+	ClientKeyFile  string // 出站请求使用的客户端私钥，为空时使用服务端私钥
+}
+
+// LoadConfig 从环境变量加载 TLS 配置，三个服务共用同一组环境变量
+func LoadConfig() Config {
+	config := Config{
+		CertFile:       os.Getenv("TLS_CERT_FILE"),
+		KeyFile:        os.Getenv("TLS_KEY_FILE"),
+		ClientCAFile:   os.Getenv("TLS_CLIENT_CA_FILE"),
+		CAFile:         os.Getenv("TLS_CA_FILE"),
+		ClientCertFile: os.Getenv("TLS_CLIENT_CERT_FILE"),
+		ClientKeyFile:  os.Getenv("TLS_CLIENT_KEY_FILE"),
+	}
+
+	// 配置了客户端 CA 时默认要求客户端证书
+	config.ClientAuth = ClientAuthNone
+	if config.ClientCAFile != "" {
+		config.ClientAuth = ClientAuthRequire
+	}
+	if mode := os.Getenv("TLS_CLIENT_AUTH"); mode != "" {
+		switch mode = strings.ToLower(mode); mode {
+		case ClientAuthNone, ClientAuthOptional, ClientAuthRequire:
+			config.ClientAuth = mode
+		default:
+			logrus.Warnf("Invalid TLS_CLIENT_AUTH: %s, using default: %s", mode, config.ClientAuth)
+		}
+	}
+	return config
+}
+
+// ServerEnabled 判断服务端是否以 HTTPS 监听
+func (c Config) ServerEnabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// Scheme 返回服务端的访问协议，注册到注册中心后供服务发现的调用方使用
+func (c Config) Scheme() string {
+	if c.ServerEnabled() {
+		return SchemeHTTPS
+	}
+	return SchemeHTTP
+}
+
+// ServerTLSConfig 创建服务端 TLS 配置，未配置证书时返回 nil
+func (c Config) ServerTLSConfig() (*tls.Config, error) {
+	if c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+	if !c.ServerEnabled() {
+		return nil, errors.New("both TLS_CERT_FILE and TLS_KEY_FILE must be set")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %v", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	switch c.ClientAuth {
+	case "", ClientAuthNone:
+		config.ClientAuth = tls.NoClientCert
+	case ClientAuthOptional, ClientAuthRequire:
+		if c.ClientCAFile == "" {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE is required when client auth is %q", c.ClientAuth)
+		}
+		pool, err := loadCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if c.ClientAuth == ClientAuthOptional {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", c.ClientAuth)
+	}
+	return config, nil
+}
+
+// ClientTLSConfig 创建出站请求使用的 TLS 配置，没有任何 TLS 配置时返回 nil（使用默认传输层）
+func (c Config) ClientTLSConfig() (*tls.Config, error) {
+	certFile, keyFile := c.ClientCertFile, c.ClientKeyFile
+	if certFile == "" && keyFile == "" {
+		certFile, keyFile = c.CertFile, c.KeyFile
+	}
+	if c.CAFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("both TLS_CLIENT_CERT_FILE and TLS_CLIENT_KEY_FILE must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// loadCertPool 从 PEM 文件加载 CA 证书池
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file %s: %v", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid certificates found in CA file %s", path)
+	}
+	return pool, nil
+}
+
+// ListenAndServe 启动 HTTP 服务，srv.TLSConfig 不为 nil 时以 HTTPS 监听（证书已在 TLSConfig 中）
+func ListenAndServe(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+// Setup 根据配置创建服务端 TLS 配置，并将出站请求的 TLS 配置设置为 httpclient 的默认配置
+// 返回的服务端配置为 nil 时表示以明文 HTTP 监听
+func Setup(config Config) (*tls.Config, error) {
+	serverTLS, err := config.ServerTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientTLS, err := config.ClientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	httpclient.SetDefaultTLSConfig(clientTLS)
+	return serverTLS, nil
+}
@@ -0,0 +1,182 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert 是测试用的证书和私钥
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert 生成证书，parent 为 nil 时生成自签名 CA
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("generate serial: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// write 将证书和私钥写入 PEM 文件，返回文件路径
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+// setupCerts 生成 CA、服务端证书和客户端证书，并通过环境变量配置 mTLS
+func setupCerts(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, 0)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverCert, serverKey := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth).write(t, dir, "server")
+	clientCert, clientKey := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth).write(t, dir, "client")
+
+	t.Setenv("TLS_CERT_FILE", serverCert)
+	t.Setenv("TLS_KEY_FILE", serverKey)
+	t.Setenv("TLS_CLIENT_CA_FILE", caFile)
+	t.Setenv("TLS_CA_FILE", caFile)
+	t.Setenv("TLS_CLIENT_CERT_FILE", clientCert)
+	t.Setenv("TLS_CLIENT_KEY_FILE", clientKey)
+	t.Setenv("TLS_CLIENT_AUTH", "")
+}
+
+func TestLoadConfig(t *testing.T) {
+	setupCerts(t)
+	config := LoadConfig()
+	if config.ClientAuth != ClientAuthRequire {
+		t.Errorf("ClientAuth = %q, want %q when a client CA is configured", config.ClientAuth, ClientAuthRequire)
+	}
+	if !config.ServerEnabled() || config.Scheme() != SchemeHTTPS {
+		t.Errorf("server TLS not enabled: %+v", config)
+	}
+
+	t.Setenv("TLS_CLIENT_AUTH", "Optional")
+	if config := LoadConfig(); config.ClientAuth != ClientAuthOptional {
+		t.Errorf("ClientAuth = %q, want %q", config.ClientAuth, ClientAuthOptional)
+	}
+	t.Setenv("TLS_CLIENT_AUTH", "bogus")
+	if config := LoadConfig(); config.ClientAuth != ClientAuthRequire {
+		t.Errorf("ClientAuth = %q, want default %q for an invalid mode", config.ClientAuth, ClientAuthRequire)
+	}
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	if config, err := (Config{}).ClientTLSConfig(); config != nil || err != nil {
+		t.Fatalf("ClientTLSConfig() without TLS settings = %v, %v; want nil, nil", config, err)
+	}
+
+	setupCerts(t)
+	config, err := LoadConfig().ClientTLSConfig()
+	if err != nil {
+		t.Fatalf("ClientTLSConfig: %v", err)
+	}
+	if config.RootCAs == nil || len(config.Certificates) != 1 {
+		t.Fatalf("client config missing CA or certificate: %+v", config)
+	}
+
+	if _, err := (Config{CAFile: "/nonexistent/ca.crt"}).ClientTLSConfig(); err == nil {
+		t.Error("ClientTLSConfig succeeded with a missing CA file")
+	}
+	if _, err := (Config{ClientCertFile: os.Getenv("TLS_CLIENT_CERT_FILE")}).ClientTLSConfig(); err == nil {
+		t.Error("ClientTLSConfig succeeded with a certificate but no key")
+	}
+}
+
+func TestMutualTLSHandshake(t *testing.T) {
+	setupCerts(t)
+	config := LoadConfig()
+	serverTLS, err := config.ServerTLSConfig()
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: %v", err)
+	}
+	clientTLS, err := config.ClientTLSConfig()
+	if err != nil {
+		t.Fatalf("ClientTLSConfig: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if len(req.TLS.PeerCertificates) == 0 || req.TLS.PeerCertificates[0].Subject.CommonName != "client" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = serverTLS
+	server.StartTLS()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("mTLS request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	// 不带客户端证书的请求在握手阶段被拒绝
+	noCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: clientTLS.RootCAs}}}
+	if resp, err := noCert.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("request without a client certificate succeeded")
+	}
+}
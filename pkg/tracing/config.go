@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 支持的导出器类型
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Config 定义链路追踪的配置
+type Config struct {
+	Exporter      string            // 导出器类型：none、stdout、file、otlp
+	FilePath      string            // file 导出器的输出文件（JSON Lines）
+	OTLPEndpoint  string            // otlp 导出器的 OTLP/HTTP 地址
+	OTLPHeaders   map[string]string // 附加到 OTLP 导出请求上的请求头
+	SampleRatio   float64           // 根 span 的采样率（0-1），非根 span 跟随上游的采样决定
+	BatchSize     int               // 每批导出的最大 span 数
+	FlushInterval time.Duration     // 未攒满一批时的最长导出间隔
+}
+
+// DefaultConfig 返回默认的链路追踪配置（默认不导出，只传播链路上下文）
+func DefaultConfig() Config {
+	return Config{
+		Exporter:      ExporterNone,
+		FilePath:      "traces.jsonl",
+		OTLPEndpoint:  "http://localhost:4318/v1/traces",
+		SampleRatio:   1,
+		BatchSize:     256,
+		FlushInterval: 5 * time.Second,
+	}
+}
+
+// LoadConfig 从环境变量加载链路追踪配置，三个服务共用同一组环境变量
+func LoadConfig() Config {
+	config := DefaultConfig()
+
+	if exporter := os.Getenv("TRACING_EXPORTER"); exporter != "" {
+		switch exporter = strings.ToLower(exporter); exporter {
+		case ExporterNone, ExporterStdout, ExporterFile, ExporterOTLP:
+			config.Exporter = exporter
+		default:
+			logrus.Warnf("Invalid TRACING_EXPORTER: %s, using default: %s", exporter, config.Exporter)
+		}
+	}
+	if path := os.Getenv("TRACING_FILE_PATH"); path != "" {
+		config.FilePath = path
+	}
+	if endpoint := os.Getenv("TRACING_OTLP_ENDPOINT"); endpoint != "" {
+		config.OTLPEndpoint = endpoint
+	}
+	// 格式：key1=value1,key2=value2
+	if headersStr := os.Getenv("TRACING_OTLP_HEADERS"); headersStr != "" {
+		config.OTLPHeaders = make(map[string]string)
+		for _, pair := range strings.Split(headersStr, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				logrus.Warnf("Invalid TRACING_OTLP_HEADERS entry: %s, ignoring", pair)
+				continue
+			}
+			config.OTLPHeaders[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if ratioStr := os.Getenv("TRACING_SAMPLE_RATIO"); ratioStr != "" {
+		if ratio, err := strconv.ParseFloat(ratioStr, 64); err == nil && ratio >= 0 && ratio <= 1 {
+			config.SampleRatio = ratio
+		} else {
+			logrus.Warnf("Invalid TRACING_SAMPLE_RATIO: %s, using default: %v", ratioStr, config.SampleRatio)
+		}
+	}
+	if sizeStr := os.Getenv("TRACING_BATCH_SIZE"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil && size > 0 {
+			config.BatchSize = size
+		} else {
+			logrus.Warnf("Invalid TRACING_BATCH_SIZE: %s, using default: %d", sizeStr, config.BatchSize)
+		}
+	}
+	if intervalStr := os.Getenv("TRACING_FLUSH_INTERVAL_SECONDS"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			config.FlushInterval = time.Duration(interval) * time.Second
+		} else {
+			logrus.Warnf("Invalid TRACING_FLUSH_INTERVAL_SECONDS: %s, using default: %v", intervalStr, config.FlushInterval)
+		}
+	}
+	return config
+}
+
+// NewExporter 根据配置创建导出器，Exporter 为 none 时返回 nil
+func NewExporter(config Config) (Exporter, error) {
+	switch config.Exporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return NewStdoutExporter(), nil
+	case ExporterFile:
+		return NewFileExporter(config.FilePath)
+	case ExporterOTLP:
+		return NewOTLPExporter(config.OTLPEndpoint, config.OTLPHeaders), nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+	}
+}
+
+// Setup 根据配置创建 Tracer 并设置为默认 Tracer
+func Setup(config Config, serviceName string) (*Tracer, error) {
+	exporter, err := NewExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	tracer := NewTracer(serviceName, exporter, config)
+	SetDefault(tracer)
+	return tracer, nil
+}
@@ -0,0 +1,182 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"MicroService/pkg/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	spansExportedTotal = metrics.NewCounterVec("tracing_spans_exported_total",
+		"Total number of spans successfully exported.")
+	spansDroppedTotal = metrics.NewCounterVec("tracing_spans_dropped_total",
+		"Total number of spans dropped because the export queue was full or the export failed.")
+)
+
+// Exporter 将已结束的 span 输出到外部系统
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// JSONExporter 将 span 以 JSON Lines 格式写入 io.Writer（标准输出或文件）
+type JSONExporter struct {
+	mu     sync.Mutex
+	writer io.Writer
+	closer io.Closer // 文件导出器关闭时需要关闭文件
+}
+
+// NewStdoutExporter 创建输出到标准输出的导出器
+func NewStdoutExporter() *JSONExporter {
+	return &JSONExporter{writer: os.Stdout}
+}
+
+// NewFileExporter 创建追加写入文件的导出器
+func NewFileExporter(path string) (*JSONExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file %s: %w", path, err)
+	}
+	return &JSONExporter{writer: f, closer: f}, nil
+}
+
+// Export 每个 span 输出一行 JSON
+func (e *JSONExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.writer)
+	for _, span := range spans {
+		if err := enc.Encode(span); err != nil {
+			return fmt.Errorf("failed to write span: %w", err)
+		}
+	}
+	return nil
+}
+
+// Shutdown 关闭文件
+func (e *JSONExporter) Shutdown(context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// batchProcessor 在后台按批次导出 span，队列满时丢弃新的 span，不阻塞业务请求
+type batchProcessor struct {
+	exporter      Exporter
+	batchSize     int
+	flushInterval time.Duration
+
+	mu     sync.RWMutex // 保护 queue 的关闭
+	closed bool
+	queue  chan SpanData
+	done   chan struct{}
+}
+
+const queueSize = 2048 // 等待导出的最大 span 数
+
+func newBatchProcessor(exporter Exporter, batchSize int, flushInterval time.Duration) *batchProcessor {
+	p := &batchProcessor{exporter: exporter, batchSize: batchSize, flushInterval: flushInterval}
+	if exporter == nil {
+		return p
+	}
+	if p.batchSize <= 0 {
+		p.batchSize = DefaultConfig().BatchSize
+	}
+	if p.flushInterval <= 0 {
+		p.flushInterval = DefaultConfig().FlushInterval
+	}
+	p.queue = make(chan SpanData, queueSize)
+	p.done = make(chan struct{})
+	go p.run()
+	return p
+}
+
+// enqueue 将 span 放入导出队列
+func (p *batchProcessor) enqueue(span SpanData) {
+	if p.queue == nil {
+		return
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		// 关闭后结束的 span 直接丢弃
+		spansDroppedTotal.Inc()
+		return
+	}
+	select {
+	case p.queue <- span:
+	default:
+		spansDroppedTotal.Inc()
+	}
+}
+
+// run 攒够一批或到达刷新间隔时导出
+func (p *batchProcessor) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, p.batchSize)
+	for {
+		select {
+		case span, ok := <-p.queue:
+			if !ok {
+				p.export(batch)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= p.batchSize {
+				p.export(batch)
+				batch = make([]SpanData, 0, p.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.export(batch)
+				batch = make([]SpanData, 0, p.batchSize)
+			}
+		}
+	}
+}
+
+// export 导出一批 span，失败时记录日志并计入丢弃数
+func (p *batchProcessor) export(batch []SpanData) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.exporter.Export(ctx, batch); err != nil {
+		spansDroppedTotal.Add(float64(len(batch)))
+		logrus.Warnf("Failed to export %d spans: %v", len(batch), err)
+		return
+	}
+	spansExportedTotal.Add(float64(len(batch)))
+}
+
+// shutdown 导出剩余的 span 并关闭导出器
+func (p *batchProcessor) shutdown(ctx context.Context) error {
+	if p.queue == nil {
+		return nil
+	}
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.exporter.Shutdown(ctx)
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GinMiddleware 为每个请求创建 server span
+// 请求带有有效的 traceparent 时加入上游链路，否则开启新链路；span 放入 c.Request 的 context，
+// 处理函数通过 c.Request.Context() 发起的 httpclient 请求会自动成为其子 span
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if sc, ok := Extract(c.Request.Header); ok {
+			ctx = ContextWithRemoteSpanContext(ctx, sc)
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := Start(ctx, c.Request.Method+" "+route, SpanKindServer)
+		defer span.End()
+		span.SetAttribute("http.request.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("url.path", c.Request.URL.Path)
+		span.SetAttribute("client.address", c.ClientIP())
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.response.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(StatusError, "HTTP "+strconv.Itoa(status))
+		}
+		if len(c.Errors) > 0 {
+			span.SetAttribute("error.message", c.Errors.String())
+		}
+	}
+}
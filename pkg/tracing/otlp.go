@@ -0,0 +1,201 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const instrumentationScope = "MicroService/pkg/tracing"
+
+// OTLPExporter 通过 OTLP/HTTP（JSON 编码）将 span 发送到 Collector，例如 http://localhost:4318/v1/traces
+// 使用独立的 http.Client 而不是 pkg/httpclient，避免导出请求本身再产生 span
+type OTLPExporter struct {
+	endpoint   string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// NewOTLPExporter 创建 OTLP/HTTP 导出器，headers 会附加到每个导出请求上（例如认证头）
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint:   endpoint,
+		headers:    headers,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// OTLP JSON 编码的数据结构，字段名遵循 OTLP protobuf 的 JSON 映射
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 在 JSON 映射中编码为字符串
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+// Export 按服务实例分组后发送一次导出请求
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	type resourceKey struct{ name, id string }
+	groups := make(map[resourceKey][]otlpSpan)
+	var order []resourceKey
+	for _, span := range spans {
+		key := resourceKey{span.ServiceName, span.ServiceID}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], toOTLPSpan(span))
+	}
+
+	var payload otlpRequest
+	for _, key := range order {
+		attrs := []otlpKeyValue{stringKeyValue("service.name", key.name)}
+		if key.id != "" {
+			attrs = append(attrs, stringKeyValue("service.instance.id", key.id))
+		}
+		payload.ResourceSpans = append(payload.ResourceSpans, otlpResourceSpans{
+			Resource: otlpResource{Attributes: attrs},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: instrumentationScope},
+				Spans: groups[key],
+			}},
+		})
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal OTLP request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send spans to %s: %w", e.endpoint, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP endpoint %s returned status %d", e.endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+// Shutdown 关闭空闲连接
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	e.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// toOTLPSpan 将 SpanData 转换为 OTLP span
+func toOTLPSpan(span SpanData) otlpSpan {
+	out := otlpSpan{
+		TraceID:           span.TraceID,
+		SpanID:            span.SpanID,
+		ParentSpanID:      span.ParentSpanID,
+		TraceState:        span.TraceState,
+		Name:              span.Name,
+		Kind:              otlpKind(span.kind),
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		Status:            otlpStatus{Code: int(span.status), Message: span.StatusMessage},
+	}
+	if span.ServiceID != "" {
+		out.Attributes = append(out.Attributes, stringKeyValue("serviceId", span.ServiceID))
+	}
+	for k, v := range span.Attributes {
+		out.Attributes = append(out.Attributes, otlpKeyValue{Key: k, Value: toOTLPValue(v)})
+	}
+	return out
+}
+
+// otlpKind 将 SpanKind 转换为 OTLP 枚举值（INTERNAL=1, SERVER=2, CLIENT=3）
+func otlpKind(kind SpanKind) int {
+	switch kind {
+	case SpanKindServer:
+		return 2
+	case SpanKindClient:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// toOTLPValue 将属性值转换为 OTLP AnyValue
+func toOTLPValue(v interface{}) otlpValue {
+	switch val := v.(type) {
+	case string:
+		return otlpValue{StringValue: &val}
+	case bool:
+		return otlpValue{BoolValue: &val}
+	case int:
+		s := strconv.Itoa(val)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &val}
+	default:
+		s := fmt.Sprint(val)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+func stringKeyValue(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpValue{StringValue: &value}}
+}
@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// W3C Trace Context 请求头
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+const maxTracestateLen = 512 // tracestate 超过该长度时丢弃
+
+// TraceID 是 16 字节的链路 ID
+type TraceID [16]byte
+
+// SpanID 是 8 字节的 span ID
+type SpanID [8]byte
+
+// String 返回小写十六进制表示
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid 判断链路 ID 是否非全零
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// String 返回小写十六进制表示
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid 判断 span ID 是否非全零
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext 是需要在进程间传播的链路上下文
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool   // trace-flags 中的 sampled 位
+	TraceState string // 原样透传的 tracestate
+}
+
+// IsValid 判断链路上下文是否有效
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent 返回 version 00 格式的 traceparent 头
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent 解析 traceparent 头
+// 兼容更高版本：只解析前四个字段，version ff 视为无效
+func ParseTraceparent(value string) (SpanContext, error) {
+	value = strings.TrimSpace(value)
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", value)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return SpanContext{}, fmt.Errorf("invalid traceparent version %q", version)
+	}
+	if version == "00" && len(parts) != 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) || len(spanID) != 16 || !isLowerHex(spanID) ||
+		len(flags) != 2 || !isLowerHex(flags) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", value)
+	}
+
+	var sc SpanContext
+	hex.Decode(sc.TraceID[:], []byte(traceID))
+	hex.Decode(sc.SpanID[:], []byte(spanID))
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q: all-zero id", value)
+	}
+	var flagByte [1]byte
+	hex.Decode(flagByte[:], []byte(flags))
+	sc.Sampled = flagByte[0]&0x01 == 0x01
+	return sc, nil
+}
+
+// isLowerHex 判断字符串是否只包含小写十六进制字符
+func isLowerHex(s string) bool {
+	for _, ch := range s {
+		if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Extract 从请求头解析上游链路上下文；无效或缺失时返回 false
+func Extract(header http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	// 多个 tracestate 头等价于用逗号拼接
+	if state := strings.Join(header.Values(TracestateHeader), ","); len(state) <= maxTracestateLen {
+		sc.TraceState = state
+	}
+	return sc, true
+}
+
+// Inject 将 ctx 中当前 span 的链路上下文写入请求头
+func Inject(ctx context.Context, header http.Header) {
+	sc, ok := spanContextFromContext(ctx)
+	if !ok {
+		return
+	}
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+type remoteKey struct{}
+
+// ContextWithRemoteSpanContext 将上游传入的链路上下文放入 ctx，后续创建的 span 以其为父 span
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// spanContextFromContext 返回 ctx 中当前 span 或上游传入的链路上下文
+func spanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context, true
+	}
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok && sc.IsValid() {
+		return sc, true
+	}
+	return SpanContext{}, false
+}
+
+// newTraceID 生成随机链路 ID
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// newSpanID 生成随机 span ID
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,254 @@
+package tracing
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind 描述 span 在调用关系中的角色
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota + 1 // 进程内操作
+	SpanKindServer                       // 处理入站请求
+	SpanKindClient                       // 发起出站请求
+)
+
+// String 返回 span 类型名称
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// StatusCode 是 span 的结束状态
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// String 返回状态名称
+func (s StatusCode) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusError:
+		return "error"
+	default:
+		return "unset"
+	}
+}
+
+// SpanData 是已结束 span 的只读数据，交给导出器输出
+type SpanData struct {
+	ServiceName   string                 `json:"serviceName"`
+	ServiceID     string                 `json:"serviceId,omitempty"`
+	TraceID       string                 `json:"traceId"`
+	SpanID        string                 `json:"spanId"`
+	ParentSpanID  string                 `json:"parentSpanId,omitempty"`
+	TraceState    string                 `json:"traceState,omitempty"`
+	Name          string                 `json:"name"`
+	Kind          string                 `json:"kind"`
+	StartTime     time.Time              `json:"startTime"`
+	EndTime       time.Time              `json:"endTime"`
+	DurationMs    float64                `json:"durationMs"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Status        string                 `json:"status"`
+	StatusMessage string                 `json:"statusMessage,omitempty"`
+
+	kind   SpanKind
+	status StatusCode
+}
+
+// Span 是一次被追踪的操作，方法可以并发调用
+type Span struct {
+	tracer  *Tracer
+	context SpanContext
+	parent  SpanID
+	name    string
+	kind    SpanKind
+	start   time.Time
+
+	mu         sync.Mutex
+	attributes map[string]interface{}
+	status     StatusCode
+	statusMsg  string
+	ended      bool
+}
+
+// SpanContext 返回 span 的链路上下文
+func (s *Span) SpanContext() SpanContext {
+	return s.context
+}
+
+// SetAttribute 设置 span 属性
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+}
+
+// SetStatus 设置 span 状态
+func (s *Span) SetStatus(code StatusCode, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+	s.statusMsg = message
+}
+
+// RecordError 将 span 标记为失败并记录错误信息，err 为 nil 时不做任何操作
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End 结束 span；采样的 span 交给导出器，重复调用无效
+func (s *Span) End() {
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		TraceID:       s.context.TraceID.String(),
+		SpanID:        s.context.SpanID.String(),
+		TraceState:    s.context.TraceState,
+		Name:          s.name,
+		Kind:          s.kind.String(),
+		StartTime:     s.start,
+		EndTime:       end,
+		DurationMs:    float64(end.Sub(s.start).Microseconds()) / 1000,
+		Attributes:    make(map[string]interface{}, len(s.attributes)),
+		Status:        s.status.String(),
+		StatusMessage: s.statusMsg,
+		kind:          s.kind,
+		status:        s.status,
+	}
+	for k, v := range s.attributes {
+		data.Attributes[k] = v
+	}
+	s.mu.Unlock()
+
+	if !s.context.Sampled {
+		return
+	}
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	data.ServiceName = s.tracer.serviceName
+	data.ServiceID = s.tracer.ServiceID()
+	s.tracer.processor.enqueue(data)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan 将 span 放入 ctx
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext 返回 ctx 中的当前 span，不存在时返回 nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Tracer 创建 span 并将其交给导出器
+type Tracer struct {
+	serviceName string
+	serviceID   atomic.Value // string，服务注册成功后才能确定
+	sampleRatio float64
+	processor   *batchProcessor
+}
+
+// NewTracer 创建 Tracer；exporter 为 nil 时 span 仍会生成和传播，但不会被导出
+func NewTracer(serviceName string, exporter Exporter, config Config) *Tracer {
+	t := &Tracer{
+		serviceName: serviceName,
+		sampleRatio: config.SampleRatio,
+		processor:   newBatchProcessor(exporter, config.BatchSize, config.FlushInterval),
+	}
+	t.serviceID.Store("")
+	return t
+}
+
+// SetServiceID 设置服务实例 ID，之后导出的 span 均携带该 ID
+func (t *Tracer) SetServiceID(serviceID string) {
+	t.serviceID.Store(serviceID)
+}
+
+// ServiceID 返回服务实例 ID
+func (t *Tracer) ServiceID() string {
+	return t.serviceID.Load().(string)
+}
+
+// Start 以 ctx 中的 span（或上游传入的链路上下文）为父 span 创建新 span，并返回携带新 span 的 ctx
+// 调用方必须调用 span.End()
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+	if parent, ok := spanContextFromContext(ctx); ok {
+		span.context = SpanContext{
+			TraceID:    parent.TraceID,
+			SpanID:     newSpanID(),
+			Sampled:    parent.Sampled, // 跟随上游的采样决定
+			TraceState: parent.TraceState,
+		}
+		span.parent = parent.SpanID
+	} else {
+		span.context = SpanContext{
+			TraceID: newTraceID(),
+			SpanID:  newSpanID(),
+			Sampled: t.sampleRatio >= 1 || rand.Float64() < t.sampleRatio,
+		}
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Shutdown 导出缓冲中的 span 并关闭导出器
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.processor.shutdown(ctx)
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer("", nil, DefaultConfig()))
+}
+
+// SetDefault 设置进程级别的默认 Tracer
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Default 返回进程级别的默认 Tracer；未调用 Setup 时只传播链路上下文，不导出 span
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// Start 使用默认 Tracer 创建 span
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind)
+}
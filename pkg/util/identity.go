@@ -0,0 +1,66 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// InstanceIdentity 是实例在注册中心的身份：ServiceId 和租约令牌
+// 持久化后重启的实例沿用同一身份重新注册，注册中心视为同一实例而不是新增实例
+type InstanceIdentity struct {
+	ServiceId  string `json:"serviceId"`
+	LeaseToken string `json:"leaseToken"`
+}
+
+// NewInstanceIdentity 生成新的实例身份
+func NewInstanceIdentity() InstanceIdentity {
+	return InstanceIdentity{ServiceId: GenerateUUID(), LeaseToken: GenerateToken()}
+}
+
+// LoadInstanceIdentity 从文件读取实例身份，文件不存在时生成新身份并写入文件
+// path 为空时不持久化，每次启动使用新身份（与之前的行为一致）
+// 文件中包含租约令牌，以 0600 权限写入
+func LoadInstanceIdentity(path string) (InstanceIdentity, error) {
+	if path == "" {
+		return NewInstanceIdentity(), nil
+	}
+
+	var identity InstanceIdentity
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		identity = NewInstanceIdentity()
+	case err != nil:
+		return InstanceIdentity{}, fmt.Errorf("failed to read instance identity file %s: %v", path, err)
+	default:
+		if err := json.Unmarshal(data, &identity); err != nil {
+			return InstanceIdentity{}, fmt.Errorf("failed to parse instance identity file %s: %v", path, err)
+		}
+		if identity.ServiceId == "" {
+			return InstanceIdentity{}, fmt.Errorf("instance identity file %s has no serviceId", path)
+		}
+		if identity.LeaseToken != "" {
+			return identity, nil
+		}
+		// 只配置了 ServiceId 的文件补充租约令牌
+		identity.LeaseToken = GenerateToken()
+	}
+
+	if err := saveInstanceIdentity(path, identity); err != nil {
+		return InstanceIdentity{}, err
+	}
+	return identity, nil
+}
+
+// saveInstanceIdentity 原子地写入实例身份文件
+func saveInstanceIdentity(path string, identity InstanceIdentity) error {
+	data, err := json.MarshalIndent(identity, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write instance identity file %s: %v", path, err)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"github.com/google/uuid"
 	"net"
@@ -11,6 +13,15 @@ func GenerateUUID() string {
 	return uuid.New().String()
 }
 
+// GenerateToken 生成 32 字节随机数的十六进制字符串，用作租约令牌等不可猜测的凭据
+func GenerateToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
 // GetLocalIP 获取一个非环回且非 APIPA 的 IPv4 地址
 func GetLocalIP() (string, error) {
 	addrs, err := net.InterfaceAddrs()
@@ -31,3 +42,19 @@ func GetLocalIP() (string, error) {
 	}
 	return "", errors.New("cannot find a non-loopback and non-APIPA IPv4 address")
 }
+
+// ParseKeyValues 解析 "k1=v1,k2=v2" 格式的字符串，用于从环境变量读取实例元数据等键值对
+func ParseKeyValues(s string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, errors.New("invalid key=value pair: " + pair)
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return result, nil
+}